@@ -0,0 +1,38 @@
+// Package audit records sensitive actions in the audit log collection.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"backend/config"
+	"backend/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Record stores an audit entry, stamping its time
+func Record(ctx context.Context, entry models.AuditLog) error {
+	entry.CreatedAt = time.Now()
+	_, err := config.AuditLogCollectionRef.InsertOne(ctx, entry)
+	if err != nil {
+		fmt.Println("Error writing audit log:", err)
+	}
+	return err
+}
+
+// RecordRequest stores an audit entry for the current request, filling in
+// method, path, client IP and, unless entry.Status is set, the response status
+func RecordRequest(c *fiber.Ctx, entry models.AuditLog) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	entry.Method = c.Method()
+	entry.Path = c.OriginalURL()
+	if entry.Status == 0 {
+		entry.Status = c.Response().StatusCode()
+	}
+	entry.IP = c.IP()
+	return Record(ctx, entry)
+}
@@ -0,0 +1,118 @@
+// Command hashbench measures how long password hashing takes with the current
+// PASSWORD_HASH_ALGORITHM / ARGON2_* / BCRYPT_COST settings and checks the result
+// against a latency budget, so parameters can be tuned for the hardware they run on.
+//
+//	go run ./cmd/hashbench -n 20 -concurrency 4 -budget 250ms
+//
+// It exits with status 1 when the p95 of hashing or verifying is over budget.
+// For plain numbers, see BenchmarkHashPassword in utils/hash_test.go.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"backend/utils"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	iterations := flag.Int("n", 20, "operations per measurement")
+	concurrency := flag.Int("concurrency", 1, "operations running at the same time, like concurrent logins")
+	budget := flag.Duration("budget", 250*time.Millisecond, "acceptable p95 latency of one operation")
+	compare := flag.Bool("compare", true, "also measure bcrypt at the legacy cost 14")
+	flag.Parse()
+
+	_ = godotenv.Load()
+
+	fmt.Printf("algorithm=%s", utils.PasswordHashAlgorithm())
+	if utils.PasswordHashAlgorithm() == utils.HashAlgorithmArgon2id {
+		p := utils.Argon2ParamsFromEnv()
+		fmt.Printf(" m=%dKiB t=%d p=%d", p.MemoryKiB, p.Iterations, p.Parallelism)
+	} else {
+		fmt.Printf(" cost=%d", utils.BcryptCost())
+	}
+	fmt.Printf(" n=%d concurrency=%d budget=%s\n\n", *iterations, *concurrency, *budget)
+
+	hash, err := utils.HashPassword("benchmark-password")
+	if err != nil {
+		fmt.Println("hash failed:", err)
+		os.Exit(1)
+	}
+
+	hashOver := report("hash", measure(*iterations, *concurrency, func() {
+		utils.HashPassword("benchmark-password")
+	}), *budget)
+	verifyOver := report("verify", measure(*iterations, *concurrency, func() {
+		utils.CheckPasswordHash("benchmark-password", hash)
+	}), *budget)
+
+	if *compare {
+		os.Setenv("PASSWORD_HASH_ALGORITHM", utils.HashAlgorithmBcrypt)
+		os.Setenv("BCRYPT_COST", "14")
+		legacy, _ := utils.HashPassword("benchmark-password")
+		fmt.Println()
+		report("bcrypt-14 verify (legacy)", measure(*iterations, *concurrency, func() {
+			utils.CheckPasswordHash("benchmark-password", legacy)
+		}), *budget)
+	}
+
+	if hashOver || verifyOver {
+		os.Exit(1)
+	}
+}
+
+// measure runs op n times with the given concurrency and returns every latency, sorted
+func measure(n, concurrency int, op func()) []time.Duration {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	latencies := make([]time.Duration, n)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				start := time.Now()
+				op()
+				latencies[i] = time.Since(start)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies
+}
+
+// report prints percentiles and reports whether the p95 is over budget
+func report(name string, latencies []time.Duration, budget time.Duration) bool {
+	if len(latencies) == 0 {
+		return false
+	}
+	percentile := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))]
+	}
+
+	p95 := percentile(0.95)
+	verdict := "ok"
+	if p95 > budget {
+		verdict = "OVER BUDGET"
+	}
+	fmt.Printf("%-26s p50=%-12s p95=%-12s max=%-12s %s\n",
+		name, percentile(0.5).Round(time.Microsecond), p95.Round(time.Microsecond),
+		latencies[len(latencies)-1].Round(time.Microsecond), verdict)
+	return p95 > budget
+}
@@ -0,0 +1,96 @@
+// Command importusers creates or invites users in bulk from a CSV with the columns
+// name, email, role, bio and team, the same import as POST /api/admin/users/import.
+//
+//	go run ./cmd/importusers -file users.csv -dry-run
+//	go run ./cmd/importusers -file users.csv -mode password -on-duplicate update
+//
+// The per-row report is written to stdout as JSON; logs go to stderr. It exits with
+// status 1 when the import could not run or any row failed.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"backend/config"
+	"backend/controllers"
+	"backend/mailer"
+	"backend/middleware"
+	"backend/models"
+	"backend/utils"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	failed, err := run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// run imports the file and reports whether any row failed. It returns instead of
+// exiting so that deferred cleanup, like closing the file, always happens.
+func run() (failed bool, err error) {
+	file := flag.String("file", "-", "CSV file to import, - for stdin")
+	dryRun := flag.Bool("dry-run", false, "only validate and report, write nothing")
+	mode := flag.String("mode", controllers.ImportModeInvite, "how new accounts are set up: invite or password")
+	onDuplicate := flag.String("on-duplicate", controllers.ImportDuplicateSkip, "what to do with registered emails: skip or update")
+	workspace := flag.String("workspace", models.DefaultWorkspaceID, "workspace to import into")
+	actor := flag.String("actor", "", "user ID recorded as the inviter")
+	flag.Parse()
+
+	_ = godotenv.Load()
+
+	var input io.Reader = os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return false, fmt.Errorf("open failed: %w", err)
+		}
+		defer f.Close()
+		input = f
+	}
+
+	// Invitation links are signed like any other token
+	if err := utils.LoadJWTKeys(); err != nil {
+		return false, fmt.Errorf("failed to load JWT keys: %w", err)
+	}
+	middleware.LoadRolePermissions()
+	mailer.Init()
+	config.ConnectDB()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	// Whoever runs this has shell access to the server, so any role may be assigned
+	report, err := controllers.RunUserImport(ctx, input, controllers.ImportOptions{
+		DryRun:       *dryRun,
+		Mode:         *mode,
+		OnDuplicate:  *onDuplicate,
+		WorkspaceID:  *workspace,
+		ActorID:      *actor,
+		AllowRoles:   true,
+		AllowUpdates: true,
+	})
+	if err != nil {
+		return false, fmt.Errorf("import failed: %w", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+
+	fmt.Fprintf(os.Stderr, "total=%d created=%d invited=%d updated=%d skipped=%d failed=%d dry-run=%t\n",
+		report.Total, report.Created, report.Invited, report.Updated, report.Skipped, report.Failed, report.DryRun)
+	return report.Failed > 0, nil
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// AppURL is the base URL of the frontend, used to build links in emails
+func AppURL() string {
+	url := os.Getenv("APP_URL")
+	if url == "" {
+		url = "http://localhost:5173"
+	}
+	return strings.TrimRight(url, "/")
+}
+
+// OpenRegistration reports whether anyone may sign up. With OPEN_REGISTRATION=false
+// new accounts can only be created from an invitation or by an administrator.
+func OpenRegistration() bool {
+	open, err := strconv.ParseBool(os.Getenv("OPEN_REGISTRATION"))
+	if err != nil {
+		return true
+	}
+	return open
+}
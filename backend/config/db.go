@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -14,6 +15,19 @@ import (
 var DB *mongo.Database
 var UserCollectionRef *mongo.Collection
 var MeetingCollectionRef *mongo.Collection // Add this line
+var RefreshTokenCollectionRef *mongo.Collection
+var RevokedTokenCollectionRef *mongo.Collection
+var OneTimeTokenCollectionRef *mongo.Collection
+var SettingsCollectionRef *mongo.Collection
+var LoginAttemptCollectionRef *mongo.Collection
+var SessionCollectionRef *mongo.Collection
+var OIDCStateCollectionRef *mongo.Collection
+var AccessTokenCollectionRef *mongo.Collection
+var InvitationCollectionRef *mongo.Collection
+var AuditLogCollectionRef *mongo.Collection
+var WebAuthnSessionCollectionRef *mongo.Collection
+var TeamCollectionRef *mongo.Collection
+var DeletedUserCollectionRef *mongo.Collection
 
 // Connect to MongoDB
 func ConnectDB() {
@@ -25,8 +39,6 @@ func ConnectDB() {
 
 	mongoString := os.Getenv("MONGOSTRING")
 	dbName := os.Getenv("DB_NAME")
-	userCollection := os.Getenv("USER_COLLECTION")
-	meetingCollection := os.Getenv("MEETING_COLLECTION") // Add this line
 
 	// Log what we're getting from environment
 	log.Printf("🔍 MONGOSTRING from env: %s", mongoString)
@@ -42,16 +54,6 @@ func ConnectDB() {
 		log.Println("⚠️ DB_NAME not set, using default:", dbName)
 	}
 
-	if userCollection == "" {
-		userCollection = "users"
-		log.Println("⚠️ USER_COLLECTION not set, using default:", userCollection)
-	}
-
-	if meetingCollection == "" {
-		meetingCollection = "meetings"
-		log.Println("⚠️ MEETING_COLLECTION not set, using default:", meetingCollection)
-	}
-
 	// Set a shorter timeout for quicker feedback during development
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -72,9 +74,8 @@ func ConnectDB() {
 		log.Fatal("❌ Failed to connect to MongoDB. Is MongoDB running? Error:", err)
 	}
 
-	DB = client.Database(dbName)
-	UserCollectionRef = DB.Collection(userCollection)
-	MeetingCollectionRef = DB.Collection(meetingCollection) // Add this line
+	UseDatabase(client.Database(dbName))
+	ensureIndexes(ctx)
 
 	log.Println("✅ MongoDB connected to database:", dbName)
 
@@ -84,3 +85,82 @@ func ConnectDB() {
 		log.Println("🌐 Successfully connected to MongoDB Atlas cluster")
 	}
 }
+
+// UseDatabase points every collection at db. ConnectDB calls it; tests use it
+// to run against a throwaway database.
+func UseDatabase(db *mongo.Database) {
+	DB = db
+	UserCollectionRef = collectionFromEnv("USER_COLLECTION", "users")
+	MeetingCollectionRef = collectionFromEnv("MEETING_COLLECTION", "meetings")
+	RefreshTokenCollectionRef = collectionFromEnv("REFRESH_TOKEN_COLLECTION", "refresh_tokens")
+	RevokedTokenCollectionRef = collectionFromEnv("REVOKED_TOKEN_COLLECTION", "revoked_tokens")
+	OneTimeTokenCollectionRef = collectionFromEnv("ONE_TIME_TOKEN_COLLECTION", "one_time_tokens")
+	SettingsCollectionRef = collectionFromEnv("SETTINGS_COLLECTION", "settings")
+	LoginAttemptCollectionRef = collectionFromEnv("LOGIN_ATTEMPT_COLLECTION", "login_attempts")
+	SessionCollectionRef = collectionFromEnv("SESSION_COLLECTION", "sessions")
+	OIDCStateCollectionRef = collectionFromEnv("OIDC_STATE_COLLECTION", "oidc_states")
+	AccessTokenCollectionRef = collectionFromEnv("ACCESS_TOKEN_COLLECTION", "personal_access_tokens")
+	InvitationCollectionRef = collectionFromEnv("INVITATION_COLLECTION", "invitations")
+	AuditLogCollectionRef = collectionFromEnv("AUDIT_LOG_COLLECTION", "audit_logs")
+	WebAuthnSessionCollectionRef = collectionFromEnv("WEBAUTHN_SESSION_COLLECTION", "webauthn_sessions")
+	TeamCollectionRef = collectionFromEnv("TEAM_COLLECTION", "teams")
+	DeletedUserCollectionRef = collectionFromEnv("DELETED_USER_COLLECTION", "deleted_users")
+}
+
+// collectionFromEnv returns the collection named by envKey, falling back to a default name
+func collectionFromEnv(envKey, fallback string) *mongo.Collection {
+	name := os.Getenv(envKey)
+	if name == "" {
+		name = fallback
+	}
+	return DB.Collection(name)
+}
+
+// UserListCollation makes sorting and searching user listings case-insensitive
+var UserListCollation = &options.Collation{Locale: "en", Strength: 2}
+
+// ensureIndexes creates the indexes the auth collections rely on.
+// Failures are logged rather than fatal so the API can still start against a read-only replica.
+func ensureIndexes(ctx context.Context) {
+	indexes := []struct {
+		collection *mongo.Collection
+		model      mongo.IndexModel
+	}{
+		{RefreshTokenCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "tokenHash", Value: 1}}, Options: options.Index().SetUnique(true)}},
+		{RefreshTokenCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}}}},
+		// TTL indexes let MongoDB drop expired tokens on its own
+		{RefreshTokenCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)}},
+		{RevokedTokenCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)}},
+		{OneTimeTokenCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "tokenHash", Value: 1}}, Options: options.Index().SetUnique(true)}},
+		{OneTimeTokenCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)}},
+		{LoginAttemptCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)}},
+		{SessionCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}}}},
+		{SessionCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)}},
+		{OIDCStateCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)}},
+		{AccessTokenCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "tokenHash", Value: 1}}, Options: options.Index().SetUnique(true)}},
+		{AccessTokenCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}}}},
+		{InvitationCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "email", Value: 1}, {Key: "status", Value: 1}}}},
+		{AuditLogCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "actorId", Value: 1}, {Key: "createdAt", Value: -1}}}},
+		{AuditLogCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "subjectId", Value: 1}, {Key: "createdAt", Value: -1}}}},
+		{WebAuthnSessionCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)}},
+		{UserCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "passkeys.id", Value: 1}}, Options: options.Index().SetSparse(true)}},
+		{UserCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "oidcIssuer", Value: 1}, {Key: "oidcSubject", Value: 1}}, Options: options.Index().SetSparse(true)}},
+		{TeamCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "members", Value: 1}}}},
+		{MeetingCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "teamId", Value: 1}}, Options: options.Index().SetSparse(true)}},
+		{UserCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "workspaceId", Value: 1}}}},
+		{MeetingCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "workspaceId", Value: 1}, {Key: "date", Value: 1}}}},
+		{TeamCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "workspaceId", Value: 1}, {Key: "members", Value: 1}}}},
+		// The purge looks for deleted accounts past their retention window
+		{UserCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "accountStatus", Value: 1}, {Key: "deletedAt", Value: 1}}}},
+		// User listings sort on these with _id as tie-breaker, under the listing collation
+		{UserCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "nama", Value: 1}, {Key: "_id", Value: 1}}, Options: options.Index().SetCollation(UserListCollation)}},
+		{UserCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "email", Value: 1}, {Key: "_id", Value: 1}}, Options: options.Index().SetCollation(UserListCollation)}},
+		{UserCollectionRef, mongo.IndexModel{Keys: bson.D{{Key: "lastActive", Value: 1}, {Key: "_id", Value: 1}}, Options: options.Index().SetCollation(UserListCollation)}},
+	}
+
+	for _, idx := range indexes {
+		if _, err := idx.collection.Indexes().CreateOne(ctx, idx.model); err != nil {
+			log.Printf("⚠️ Failed to create index on %s: %v", idx.collection.Name(), err)
+		}
+	}
+}
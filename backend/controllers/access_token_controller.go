@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend/config"
+	"backend/middleware"
+	"backend/models"
+	"backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultAccessTokenDays is the lifetime of a token created without expiresInDays
+const defaultAccessTokenDays = 30
+
+// accessTokenPrefixLength is how much of the token is kept in clear so users can recognise it
+const accessTokenPrefixLength = len(utils.PersonalAccessTokenPrefix) + 4
+
+// CreateAccessTokenInput is the body of POST /api/tokens
+type CreateAccessTokenInput struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expiresInDays"`
+}
+
+// CreateAccessToken godoc
+//
+//	@Summary		Create a personal access token
+//	@Description	Create a named, scoped, expiring token for scripts. Scopes are permission names (e.g. meetings:create) and must be granted by the caller's role. The token is only shown in this response.
+//	@Tags			Access Tokens
+//	@Accept			json
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			token	body		CreateAccessTokenInput				true	"Token name, scopes and lifetime in days"
+//	@Success		201		{object}	models.CreatedPersonalAccessToken	"Token created"
+//	@Failure		400		{object}	map[string]string					"Invalid input"
+//	@Failure		403		{object}	map[string]string					"Scope not granted by the caller's role"
+//	@Failure		500		{object}	map[string]string					"Internal server error"
+//	@Router			/api/tokens [post]
+func CreateAccessToken(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var input CreateAccessTokenInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" || len(input.Name) > 100 {
+		return c.Status(400).JSON(fiber.Map{"error": "Name is required and must be at most 100 characters"})
+	}
+	if len(input.Scopes) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "At least one scope is required"})
+	}
+	for _, scope := range input.Scopes {
+		if !middleware.IsKnownPermission(scope) {
+			return c.Status(400).JSON(fiber.Map{"error": "Unknown scope " + scope})
+		}
+		if !middleware.Can(c, scope) {
+			return c.Status(403).JSON(fiber.Map{"error": "Your role does not grant scope " + scope})
+		}
+	}
+
+	maxDays := utils.IntFromEnv("ACCESS_TOKEN_MAX_DAYS", 365)
+	if input.ExpiresInDays == 0 {
+		input.ExpiresInDays = defaultAccessTokenDays
+	}
+	if input.ExpiresInDays < 1 || input.ExpiresInDays > maxDays {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("expiresInDays must be between 1 and %d", maxDays)})
+	}
+
+	token, hash, err := utils.GeneratePersonalAccessToken()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate token"})
+	}
+
+	now := time.Now()
+	pat := models.PersonalAccessToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    middleware.CurrentUserID(c),
+		Name:      input.Name,
+		Prefix:    token[:accessTokenPrefixLength],
+		TokenHash: hash,
+		Scopes:    input.Scopes,
+		CreatedAt: now,
+		ExpiresAt: now.AddDate(0, 0, input.ExpiresInDays),
+	}
+	if _, err := config.AccessTokenCollectionRef.InsertOne(ctx, pat); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save token"})
+	}
+
+	return c.Status(201).JSON(models.CreatedPersonalAccessToken{PersonalAccessToken: pat, Token: token})
+}
+
+// GetAccessTokens godoc
+//
+//	@Summary		List personal access tokens
+//	@Description	List the current user's personal access tokens, including revoked and expired ones, newest first
+//	@Tags			Access Tokens
+//	@Produce		json
+//	@Security		Bearer
+//	@Success		200	{array}		models.PersonalAccessToken	"Tokens"
+//	@Failure		500	{object}	map[string]string			"Internal server error"
+//	@Router			/api/tokens [get]
+func GetAccessTokens(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := config.AccessTokenCollectionRef.Find(ctx,
+		bson.M{"userId": middleware.CurrentUserID(c)},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}),
+	)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch tokens"})
+	}
+	defer cursor.Close(ctx)
+
+	tokens := []models.PersonalAccessToken{}
+	if err := cursor.All(ctx, &tokens); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to decode tokens"})
+	}
+
+	return c.JSON(tokens)
+}
+
+// RevokeAccessToken godoc
+//
+//	@Summary		Revoke a personal access token
+//	@Description	Revoke one of the current user's personal access tokens. It stops working immediately.
+//	@Tags			Access Tokens
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			id	path		string				true	"Token ID"
+//	@Success		200	{object}	map[string]string	"Token revoked"
+//	@Failure		404	{object}	map[string]string	"Token not found"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Router			/api/tokens/{id} [delete]
+func RevokeAccessToken(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Token not found"})
+	}
+
+	result, err := config.AccessTokenCollectionRef.UpdateOne(ctx,
+		bson.M{"_id": objectID, "userId": middleware.CurrentUserID(c), "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to revoke token"})
+	}
+	if result.MatchedCount == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "Token not found"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Token revoked"})
+}
+
+// revokeUserAccessTokens revokes every personal access token of the user
+func revokeUserAccessTokens(ctx context.Context, userID string) error {
+	_, err := config.AccessTokenCollectionRef.UpdateMany(ctx,
+		bson.M{"userId": userID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	return err
+}
@@ -2,68 +2,97 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 
 	"backend/config"
+	"backend/middleware"
 	"backend/models"
 	"backend/utils"
 )
 
 // Register godoc
 //	@Summary		Register a new user
-//	@Description	Register a new user with email and password
+//	@Description	Register a new user with email and password. The account stays pending until the emailed verification link is used.
 //	@Tags			Auth
 //	@Accept			json
 //	@Produce		json
-//	@Param			user	body		models.User				true	"User registration data"
+//	@Param			user	body		object{nama=string,email=string,password=string}	true	"User registration data"
 //	@Success		201		{object}	map[string]interface{}	"Registration successful"
 //	@Failure		400		{object}	map[string]string		"Invalid request"
 //	@Failure		409		{object}	map[string]string		"Email already registered"
+//	@Failure		422		{object}	map[string]interface{}	"Password violates the password policy"
 //	@Failure		500		{object}	map[string]string		"Internal server error"
 //	@Router			/register [post]
 func Register(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	var user models.User
-	if err := c.BodyParser(&user); err != nil {
+	// Only what a person signing up may choose; role, workspace, status and
+	// everything else on the account are the server's to set
+	var input struct {
+		Nama     string `json:"nama"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := c.BodyParser(&input); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Request tidak valid"})
 	}
+	user := models.User{
+		Nama:        input.Nama,
+		Email:       input.Email,
+		WorkspaceID: models.DefaultWorkspaceID,
+	}
 
 	// Check if email already exists
 	var existingUser models.User
-	err := config.UserCollectionRef.FindOne(ctx, bson.M{"email": user.Email}).Decode(&existingUser)
+	err := config.UserCollectionRef.FindOne(ctx, emailFilter(user.Email)).Decode(&existingUser)
 	if err == nil {
 		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Email sudah terdaftar"})
 	}
 
-	// Hash password using our utility function
-	hashedPassword, err := utils.HashPassword(user.Password)
-	if err != nil {
+	// Check the password against the policy, then hash it
+	if handled, err := validatePassword(c, input.Password, user); handled {
+		return err
+	}
+	if _, err := applyNewPassword(&user, input.Password); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal hash password"})
 	}
-	user.Password = hashedPassword
 
-	_, err = config.UserCollectionRef.InsertOne(ctx, user)
+	// Self-registered accounts always start with the default role and an unverified email
+	user.Role = middleware.DefaultRole
+	user.AccountStatus = models.AccountStatusPendingVerification
+
+	result, err := config.UserCollectionRef.InsertOne(ctx, user)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal menyimpan user"})
 	}
+	user.ID = insertedIDString(result.InsertedID)
+
+	// The account exists either way; the user can ask for a new link if this one fails
+	if err := sendVerificationEmail(ctx, user); err != nil {
+		fmt.Println("Error sending verification email:", err)
+	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message": "Registrasi berhasil",
+		"message": "Registrasi berhasil, silakan cek email untuk verifikasi akun",
 		"user": fiber.Map{
-			"nama":  user.Nama,
-			"email": user.Email,
+			"nama":          user.Nama,
+			"email":         user.Email,
+			"accountStatus": user.AccountStatus,
 		},
 	})
 }
 
 // Login godoc
 //	@Summary		User login
-//	@Description	Authenticate user with email and password
+//	@Description	Authenticate user with email and password. Accounts with 2FA get a challengeToken to complete at /auth/login/2fa instead of tokens.
 //	@Tags			Auth
 //	@Accept			json
 //	@Produce		json
@@ -71,6 +100,8 @@ func Register(c *fiber.Ctx) error {
 //	@Success		200			{object}	map[string]interface{}					"Login successful"
 //	@Failure		400			{object}	map[string]string						"Invalid request"
 //	@Failure		401			{object}	map[string]string						"Authentication failed"
+//	@Failure		403			{object}	map[string]string						"Email not verified"
+//	@Failure		429			{object}	map[string]string						"Too many failed attempts, temporarily locked"
 //	@Failure		500			{object}	map[string]string						"Internal server error"
 //	@Router			/login [post]
 func Login(c *fiber.Ctx) error {
@@ -86,32 +117,299 @@ func Login(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Request tidak valid"})
 	}
 
+	// Refuse early while the IP or the account is locked out
+	if handled, err := rejectIfLoginLocked(ctx, c, input.Email); handled {
+		return err
+	}
+
 	var user models.User
-	err := config.UserCollectionRef.FindOne(ctx, bson.M{"email": input.Email}).Decode(&user)
+	err := config.UserCollectionRef.FindOne(ctx, emailFilter(input.Email)).Decode(&user)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Email tidak ditemukan"})
+		// Same work and same answer as a wrong password
+		burnPasswordCheck(input.Password)
+		recordFailedLogin(ctx, c, input.Email)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": invalidCredentialsError})
 	}
 
 	// Check password using our utility function
 	if !utils.CheckPasswordHash(input.Password, user.Password) {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Password salah"})
+		recordFailedLogin(ctx, c, input.Email)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": invalidCredentialsError})
 	}
 
-	// Generate JWT using our utility function
-	tokenString, err := utils.GenerateJWT(user.ID, user.Email, user.Nama)
+	if err := clearAccountLoginFailures(ctx, user.Email); err != nil {
+		fmt.Println("Error clearing login failures:", err)
+	}
+
+	// Old bcrypt hashes (or weaker parameters) are upgraded while we know the password
+	upgradePasswordHash(ctx, user, input.Password)
+
+	// Checked after the password so it doesn't reveal anything about unverified accounts
+	if user.IsPendingVerification() {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Email belum diverifikasi, silakan cek email Anda",
+			"code":  "email_not_verified",
+		})
+	}
+
+	if user.IsDisabled() {
+		return rejectDisabledAccount(c)
+	}
+
+	// Users with 2FA (or whose role requires it) get a challenge instead of tokens
+	step, err := twoFactorLoginStep(ctx, user)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memeriksa 2FA"})
+	}
+	if step != nil {
+		return c.JSON(step)
+	}
+
+	return respondWithTokens(c, ctx, user, "Login berhasil")
+}
+
+// RefreshToken godoc
+//	@Summary		Refresh access token
+//	@Description	Exchange a refresh token for a new access token. The refresh token is rotated: the old one stops working and a new one is returned.
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		object{refreshToken=string}	true	"Refresh token"
+//	@Success		200		{object}	map[string]interface{}		"New token pair"
+//	@Failure		400		{object}	map[string]string			"Invalid request"
+//	@Failure		401		{object}	map[string]string			"Invalid, expired or revoked refresh token"
+//	@Failure		500		{object}	map[string]string			"Internal server error"
+//	@Router			/auth/refresh [post]
+func RefreshToken(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var input struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := c.BodyParser(&input); err != nil || input.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Request tidak valid"})
+	}
+
+	var stored models.RefreshToken
+	err := config.RefreshTokenCollectionRef.FindOne(ctx, bson.M{"tokenHash": utils.HashToken(input.RefreshToken)}).Decode(&stored)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Refresh token tidak valid"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memeriksa refresh token"})
+	}
+
+	// A rotated token being presented again means it was copied somewhere.
+	// End every session of the user so the stolen chain dies too. Tokens ended by a
+	// logout or a session revocation are just refused: an old tab retrying is no theft.
+	if stored.RevokedAt != nil {
+		if stored.RevokedReason != models.RefreshTokenRevoked {
+			fmt.Println("Refresh token reuse detected for user:", stored.UserID)
+			if err := revokeUserSessions(ctx, stored.UserID); err != nil {
+				fmt.Println("Error revoking sessions:", err)
+			}
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Refresh token sudah tidak berlaku"})
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Refresh token kedaluwarsa"})
+	}
+
+	// Mark the old token as used. Matching on revokedAt makes concurrent refreshes race-safe.
+	result, err := config.RefreshTokenCollectionRef.UpdateOne(ctx,
+		bson.M{"_id": stored.ID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now(), "revokedReason": models.RefreshTokenRotated}},
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memperbarui refresh token"})
+	}
+	if result.ModifiedCount == 0 {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Refresh token sudah tidak berlaku"})
+	}
+
+	user, err := findUserByID(ctx, stored.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "User tidak ditemukan"})
+	}
+	if user.IsDisabled() {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": disabledAccountError, "code": "account_disabled"})
+	}
+
+	// Keep the session the token belongs to; tokens issued before sessions existed get a new one
+	sessionID := stored.SessionID
+	if sessionID == "" {
+		session, err := startSession(ctx, c, user)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal membuat sesi"})
+		}
+		sessionID = session.ID.Hex()
+	} else if err := touchSessionOnRefresh(ctx, c, sessionID); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Sesi sudah berakhir"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memperbarui sesi"})
+	}
+
+	tokenString, refreshToken, err := issueTokens(ctx, user, sessionID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal membuat token"})
 	}
 
 	return c.JSON(fiber.Map{
-		"message": "Login berhasil",
-		"token":   tokenString,
+		"token":        tokenString,
+		"refreshToken": refreshToken,
+		"expiresIn":    int(utils.AccessTokenTTL().Seconds()),
+	})
+}
+
+// Logout godoc
+//	@Summary		Logout
+//	@Description	End the current session: revoke its access and refresh tokens
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			body	body		object{refreshToken=string}	false	"Refresh token to revoke"
+//	@Success		200		{object}	map[string]string			"Logout successful"
+//	@Failure		401		{object}	map[string]string			"Unauthorized"
+//	@Failure		500		{object}	map[string]string			"Internal server error"
+//	@Router			/auth/logout [post]
+func Logout(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userClaims, ok := c.Locals("user").(jwt.MapClaims)
+	if !ok {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to parse user claims"})
+	}
+	userID, _ := userClaims["id"].(string)
+	jti, _ := userClaims["jti"].(string)
+	sessionID, _ := userClaims["sid"].(string)
+
+	expiresAt := time.Now().Add(utils.AccessTokenTTL())
+	if exp, err := userClaims.GetExpirationTime(); err == nil && exp != nil {
+		expiresAt = exp.Time
+	}
+
+	_, err := config.RevokedTokenCollectionRef.InsertOne(ctx, models.RevokedToken{
+		JTI:       jti,
+		UserID:    userID,
+		RevokedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal logout"})
+	}
+
+	if sessionID != "" {
+		if err := revokeSession(ctx, userID, sessionID); err != nil && err != mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal logout"})
+		}
+	}
+
+	// The refresh token is optional; clients that only hold an access token can still log out
+	var input struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := c.BodyParser(&input); err == nil && input.RefreshToken != "" {
+		_, err = config.RefreshTokenCollectionRef.UpdateOne(ctx,
+			bson.M{"tokenHash": utils.HashToken(input.RefreshToken), "userId": userID, "revokedAt": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"revokedAt": time.Now(), "revokedReason": models.RefreshTokenRevoked}},
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal logout"})
+		}
+	}
+
+	return c.JSON(fiber.Map{"message": "Logout berhasil"})
+}
+
+// respondWithTokens starts a session, issues a token pair for it and writes the login response
+func respondWithTokens(c *fiber.Ctx, ctx context.Context, user models.User, message string) error {
+	accessToken, refreshToken, err := startSessionWithTokens(ctx, c, user)
+	if errors.Is(err, errAccountDisabled) {
+		return rejectDisabledAccount(c)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal membuat token"})
+	}
+	return c.JSON(loginPayload(user, accessToken, refreshToken, message))
+}
+
+// loginPayload is the body every successful login returns
+func loginPayload(user models.User, accessToken, refreshToken, message string) fiber.Map {
+	return fiber.Map{
+		"message":      message,
+		"token":        accessToken,
+		"refreshToken": refreshToken,
+		"expiresIn":    int(utils.AccessTokenTTL().Seconds()),
+		// Temporary passwords have to be replaced before anything else
+		"mustChangePassword": user.MustChangePassword,
 		"user": fiber.Map{
 			"id":           user.ID, // Pastikan field ini ada
 			"nama":         user.Nama,
 			"email":        user.Email,
 			"role":         user.Role,
 			"profileImage": user.ProfileImage,
+			"avatars":      user.Avatars,
 		},
+	}
+}
+
+// startSessionWithTokens records a new login session and issues its first token pair.
+// Deactivated and deleted accounts get errAccountDisabled, whichever way they signed in.
+func startSessionWithTokens(ctx context.Context, c *fiber.Ctx, user models.User) (string, string, error) {
+	if user.IsDisabled() {
+		return "", "", errAccountDisabled
+	}
+	session, err := startSession(ctx, c, user)
+	if err != nil {
+		return "", "", err
+	}
+	return issueTokens(ctx, user, session.ID.Hex())
+}
+
+// issueTokens creates an access token and a new stored refresh token for a session of the user
+func issueTokens(ctx context.Context, user models.User, sessionID string) (string, string, error) {
+	accessToken, err := utils.GenerateJWT(utils.AccessClaims{
+		UserID:      user.ID,
+		Email:       user.Email,
+		Nama:        user.Nama,
+		Role:        user.Role,
+		SessionID:   sessionID,
+		WorkspaceID: user.Workspace(),
 	})
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, refreshHash, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	_, err = config.RefreshTokenCollectionRef.InsertOne(ctx, models.RefreshToken{
+		UserID:    user.ID,
+		SessionID: sessionID,
+		TokenHash: refreshHash,
+		CreatedAt: now,
+		ExpiresAt: now.Add(utils.RefreshTokenTTL()),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// revokeUserRefreshTokens invalidates every outstanding refresh token of a user
+func revokeUserRefreshTokens(ctx context.Context, userID string) error {
+	_, err := config.RefreshTokenCollectionRef.UpdateMany(ctx,
+		bson.M{"userId": userID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now(), "revokedReason": models.RefreshTokenRevoked}},
+	)
+	return err
 }
@@ -0,0 +1,28 @@
+package controllers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"backend/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Emails are compared ignoring case everywhere, so an address can't be registered twice
+func TestEmailsIgnoreCase(t *testing.T) {
+	app := newTestApp(t)
+	seedUser(t, models.User{Nama: "Bob", Email: "bob@example.com"})
+
+	status, _ := call(t, app, http.MethodPost, "/register", "", map[string]string{
+		"nama": "Bob", "email": "Bob@Example.com", "password": testPassword,
+	})
+	if status != fiber.StatusConflict {
+		t.Fatalf("register: status %d, want 409", status)
+	}
+
+	status, body := callJSON(t, app, http.MethodPost, "/login", "", map[string]string{"email": "BOB@example.com", "password": testPassword})
+	if status != fiber.StatusOK || body["token"] == nil {
+		t.Fatalf("login: status %d, body %v", status, body)
+	}
+}
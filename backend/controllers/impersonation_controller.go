@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"backend/audit"
+	"backend/middleware"
+	"backend/models"
+	"backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ImpersonateUser godoc
+//
+//	@Summary		Impersonate a user
+//	@Description	Issue a short-lived access token that acts as another user so support staff see exactly what they see. The token carries the admin's ID in its "act" claim, cannot be refreshed, and every request made with it is written to the audit log. While impersonating, the email, password, role, 2FA, passkeys, sessions and access tokens of the account can't be changed, and users, meetings and teams can't be deleted. End it with /auth/logout.
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			id		path		string					true	"User ID to impersonate"
+//	@Param			body	body		object{reason=string}	false	"Why the user is impersonated, kept in the audit log"
+//	@Success		200		{object}	map[string]interface{}	"Impersonation token"
+//	@Failure		400		{object}	map[string]string		"Invalid request body or cannot impersonate yourself"
+//	@Failure		403		{object}	map[string]string		"Target is an administrator"
+//	@Failure		404		{object}	map[string]string		"User not found"
+//	@Failure		500		{object}	map[string]string		"Internal server error"
+//	@Router			/api/admin/impersonate/{id} [post]
+func ImpersonateUser(c *fiber.Ctx) error {
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	// The body is optional, but one that doesn't parse is a client bug
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	target, err := findWorkspaceUser(ctx, c, c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	adminID := middleware.CurrentUserID(c)
+	if target.ID == adminID {
+		return c.Status(400).JSON(fiber.Map{"error": "You cannot impersonate yourself"})
+	}
+	// Acting as another admin would hand out their privileges without a trace of consent
+	if middleware.HasPermission(target.Role, middleware.PermUsersImpersonate) {
+		return c.Status(403).JSON(fiber.Map{"error": "Administrators cannot be impersonated"})
+	}
+	// Their tokens would be refused anyway
+	if target.IsDisabled() {
+		return c.Status(409).JSON(fiber.Map{"error": "Deactivated or deleted users cannot be impersonated"})
+	}
+
+	ttl := utils.DurationFromEnv("IMPERSONATION_TTL", 30*time.Minute)
+	// The token belongs to the admin's login session: logging out or revoking it ends the impersonation
+	token, err := utils.GenerateJWT(utils.AccessClaims{
+		UserID:         target.ID,
+		Email:          target.Email,
+		Nama:           target.Nama,
+		Role:           target.Role,
+		WorkspaceID:    target.Workspace(),
+		ImpersonatorID: adminID,
+		SessionID:      currentSessionID(c),
+		TTL:            ttl,
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create token"})
+	}
+
+	err = audit.RecordRequest(c, models.AuditLog{
+		Action:    models.AuditImpersonationStart,
+		ActorID:   adminID,
+		SubjectID: target.ID,
+		Details:   map[string]interface{}{"reason": input.Reason, "expiresIn": int(ttl.Seconds())},
+	})
+	if err != nil {
+		// No audit trail, no impersonation
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to write audit log"})
+	}
+
+	return c.JSON(fiber.Map{
+		"token":          token,
+		"expiresIn":      int(ttl.Seconds()),
+		"impersonatorId": adminID,
+		"user": fiber.Map{
+			"id":           target.ID,
+			"nama":         target.Nama,
+			"email":        target.Email,
+			"role":         target.Role,
+			"profileImage": target.ProfileImage,
+			"avatars":      target.Avatars,
+		},
+	})
+}
@@ -0,0 +1,111 @@
+package controllers_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"backend/config"
+	"backend/middleware"
+	"backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// impersonate starts impersonating target as admin and returns the token
+func impersonate(t *testing.T, app *fiber.App, admin, target models.User) string {
+	t.Helper()
+
+	status, body := callJSON(t, app, http.MethodPost, "/api/admin/impersonate/"+target.ID, tokenFor(t, admin), map[string]string{"reason": "support ticket"})
+	token, _ := body["token"].(string)
+	if status != fiber.StatusOK || token == "" {
+		t.Fatalf("impersonate: status %d, body %v", status, body)
+	}
+	return token
+}
+
+func TestImpersonateUserRejectsAMalformedBody(t *testing.T) {
+	app := newTestApp(t)
+	admin := seedUser(t, models.User{Nama: "Admin", Email: "admin@example.com", Role: middleware.RoleAdmin})
+	target := seedUser(t, models.User{Nama: "Ana", Email: "ana@example.com"})
+
+	status, _ := call(t, app, http.MethodPost, "/api/admin/impersonate/"+target.ID, tokenFor(t, admin), []byte(`{"reason":`))
+	if status != fiber.StatusBadRequest {
+		t.Fatalf("status %d, want 400", status)
+	}
+
+	// No body at all is fine: the reason is optional
+	if status, body := callJSON(t, app, http.MethodPost, "/api/admin/impersonate/"+target.ID, tokenFor(t, admin), nil); status != fiber.StatusOK {
+		t.Fatalf("without a body: status %d, body %v", status, body)
+	}
+}
+
+func TestImpersonationBlocksAccountChanges(t *testing.T) {
+	app := newTestApp(t)
+	admin := seedUser(t, models.User{Nama: "Admin", Email: "admin@example.com", Role: middleware.RoleAdmin})
+	target := seedUser(t, models.User{Nama: "Ana", Email: "ana@example.com"})
+	token := impersonate(t, app, admin, target)
+	path := "/api/users/" + target.ID
+
+	blocked := map[string]map[string]string{
+		"email":    {"email": "attacker@example.com"},
+		"password": {"currentPassword": testPassword, "newPassword": "Another-Strong-Passw0rd"},
+		"role":     {"role": "Team Leader"},
+	}
+	for name, body := range blocked {
+		if status, _ := call(t, app, http.MethodPut, path, token, body); status != fiber.StatusForbidden {
+			t.Fatalf("changing the %s: status %d, want 403", name, status)
+		}
+	}
+	stored := findUser(t, target.ID)
+	if stored.Email != "ana@example.com" || stored.PendingEmail != "" || stored.Role != middleware.DefaultRole || stored.Password != target.Password {
+		t.Fatalf("account changed while impersonating: %+v", stored)
+	}
+
+	// Profile fields stay editable so support can fix them
+	if status, _ := call(t, app, http.MethodPut, path, token, map[string]string{"bio": "Fixed by support"}); status != fiber.StatusOK {
+		t.Fatalf("updating the bio: status %d", status)
+	}
+
+	// Every blocked attempt is in the audit log with the admin as actor
+	count, err := config.AuditLogCollectionRef.CountDocuments(context.Background(), bson.M{
+		"action":  models.AuditImpersonationBlocked,
+		"actorId": admin.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != int64(len(blocked)) {
+		t.Fatalf("%d blocked attempts audited, want %d", count, len(blocked))
+	}
+}
+
+// Impersonation tokens belong to the admin's login session and end with it
+func TestImpersonationEndsWithTheAdminSession(t *testing.T) {
+	app := newTestApp(t)
+	admin := seedUser(t, models.User{Nama: "Admin", Email: "admin@example.com", Role: middleware.RoleAdmin})
+	target := seedUser(t, models.User{Nama: "Ana", Email: "ana@example.com"})
+
+	status, body := callJSON(t, app, http.MethodPost, "/auth/login", "", map[string]string{"email": admin.Email, "password": testPassword})
+	adminToken, _ := body["token"].(string)
+	if status != fiber.StatusOK || adminToken == "" {
+		t.Fatalf("login: status %d, body %v", status, body)
+	}
+	status, body = callJSON(t, app, http.MethodPost, "/api/admin/impersonate/"+target.ID, adminToken, map[string]string{"reason": "support ticket"})
+	token, _ := body["token"].(string)
+	if status != fiber.StatusOK || token == "" {
+		t.Fatalf("impersonate: status %d, body %v", status, body)
+	}
+
+	path := "/api/users/" + target.ID
+	if status, _ := call(t, app, http.MethodGet, path, token, nil); status != fiber.StatusOK {
+		t.Fatalf("before logout: status %d", status)
+	}
+	if status, _ := call(t, app, http.MethodPost, "/auth/logout", adminToken, nil); status != fiber.StatusOK {
+		t.Fatalf("logout: status %d", status)
+	}
+	if status, _ := call(t, app, http.MethodGet, path, token, nil); status != fiber.StatusUnauthorized {
+		t.Fatalf("after logout: status %d, want 401", status)
+	}
+}
@@ -0,0 +1,392 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"backend/config"
+	"backend/mailer"
+	"backend/middleware"
+	"backend/models"
+	"backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// purposeInvitation is the purpose claim of invitation links
+const purposeInvitation = "invitation"
+
+// CreateInvitationInput is the body of POST /api/invitations
+type CreateInvitationInput struct {
+	Email          string `json:"email"`
+	Role           string `json:"role"`
+	WorkspaceID    string `json:"workspaceId"`
+	ExpiresInHours int    `json:"expiresInHours"`
+}
+
+// CreateInvitation godoc
+//
+//	@Summary		Invite a user
+//	@Description	Create an invitation for an email address with a role and email a signed link to accept it. Earlier pending invitations for the same address are revoked.
+//	@Tags			Invitations
+//	@Accept			json
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			invitation	body		CreateInvitationInput	true	"Email, role (default Team Member), workspace (default the caller's) and lifetime in hours (default INVITATION_TTL)"
+//	@Success		201			{object}	models.Invitation		"Invitation created"
+//	@Failure		400			{object}	map[string]string		"Invalid input or unknown role"
+//	@Failure		403			{object}	map[string]string		"Forbidden"
+//	@Failure		409			{object}	map[string]string		"Email already registered"
+//	@Failure		500			{object}	map[string]string		"Internal server error"
+//	@Router			/api/invitations [post]
+func CreateInvitation(c *fiber.Ctx) error {
+	var input CreateInvitationInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	input.Email = strings.ToLower(strings.TrimSpace(input.Email))
+	if !strings.Contains(input.Email, "@") {
+		return c.Status(400).JSON(fiber.Map{"error": "A valid email is required"})
+	}
+	if input.Role == "" {
+		input.Role = middleware.DefaultRole
+	}
+	if !middleware.IsKnownRole(input.Role) {
+		return c.Status(400).JSON(fiber.Map{"error": "Unknown role " + input.Role})
+	}
+	if input.Role != middleware.DefaultRole && !middleware.Can(c, middleware.PermUsersManageRoles) {
+		return c.Status(403).JSON(fiber.Map{"error": "Only administrators can invite with a role"})
+	}
+	if input.WorkspaceID == "" {
+		input.WorkspaceID = middleware.CurrentWorkspaceID(c)
+	}
+	if input.WorkspaceID != middleware.CurrentWorkspaceID(c) && !middleware.Can(c, middleware.PermWorkspacesManage) {
+		return c.Status(403).JSON(fiber.Map{"error": "You can only invite people to your own workspace"})
+	}
+
+	ttl := utils.DurationFromEnv("INVITATION_TTL", 72*time.Hour)
+	if input.ExpiresInHours < 0 || input.ExpiresInHours > 24*30 {
+		return c.Status(400).JSON(fiber.Map{"error": "expiresInHours must be between 1 and 720"})
+	}
+	if input.ExpiresInHours > 0 {
+		ttl = time.Duration(input.ExpiresInHours) * time.Hour
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	count, err := config.UserCollectionRef.CountDocuments(ctx, emailFilter(input.Email))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check email"})
+	}
+	if count > 0 {
+		return c.Status(409).JSON(fiber.Map{"error": "Email already registered"})
+	}
+
+	now := time.Now()
+	invitation := models.Invitation{
+		ID:          primitive.NewObjectID(),
+		Email:       input.Email,
+		Role:        input.Role,
+		WorkspaceID: input.WorkspaceID,
+		Status:      models.InvitationPending,
+		InvitedBy:   middleware.CurrentUserID(c),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+	if err := saveInvitation(ctx, invitation); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save invitation"})
+	}
+
+	if err := sendInvitationEmail(ctx, invitation, ttl); err != nil {
+		fmt.Println("Error sending invitation email:", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Invitation saved but the email could not be sent"})
+	}
+
+	return c.Status(201).JSON(invitation)
+}
+
+// GetInvitations godoc
+//
+//	@Summary		List invitations
+//	@Description	List invitations, newest first. Filter with ?status=pending|accepted|revoked.
+//	@Tags			Invitations
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			status	query		string				false	"Invitation status"
+//	@Success		200		{array}		models.Invitation	"Invitations"
+//	@Failure		500		{object}	map[string]string	"Internal server error"
+//	@Router			/api/invitations [get]
+func GetInvitations(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+
+	cursor, err := config.InvitationCollectionRef.Find(ctx, inWorkspace(c, filter),
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch invitations"})
+	}
+	defer cursor.Close(ctx)
+
+	invitations := []models.Invitation{}
+	if err := cursor.All(ctx, &invitations); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to decode invitations"})
+	}
+	return c.JSON(invitations)
+}
+
+// RevokeInvitation godoc
+//
+//	@Summary		Revoke an invitation
+//	@Description	Revoke a pending invitation so its link stops working
+//	@Tags			Invitations
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			id	path		string				true	"Invitation ID"
+//	@Success		200	{object}	map[string]string	"Invitation revoked"
+//	@Failure		404	{object}	map[string]string	"No pending invitation with this ID"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Router			/api/invitations/{id} [delete]
+func RevokeInvitation(c *fiber.Ctx) error {
+	objectID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Invitation not found"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := config.InvitationCollectionRef.UpdateOne(ctx,
+		inWorkspace(c, bson.M{"_id": objectID, "status": models.InvitationPending}),
+		bson.M{"$set": bson.M{"status": models.InvitationRevoked}},
+	)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to revoke invitation"})
+	}
+	if result.MatchedCount == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "Invitation not found"})
+	}
+	return c.JSON(fiber.Map{"message": "Invitation revoked"})
+}
+
+// GetInvitationByToken godoc
+//
+//	@Summary		Look up an invitation
+//	@Description	Show the email, suggested name and role of the invitation behind a link, so the accept page can display them
+//	@Tags			Invitations
+//	@Produce		json
+//	@Param			token	query		string					true	"Token from the invitation link"
+//	@Success		200		{object}	map[string]interface{}	"Invitation"
+//	@Failure		400		{object}	map[string]string		"Invalid or expired invitation"
+//	@Router			/auth/invitations [get]
+func GetInvitationByToken(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	invitation, err := findInvitationByToken(ctx, c.Query("token"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Undangan tidak valid atau sudah kedaluwarsa"})
+	}
+
+	return c.JSON(fiber.Map{
+		"email":     invitation.Email,
+		"nama":      invitation.Nama,
+		"role":      invitation.Role,
+		"expiresAt": invitation.ExpiresAt,
+	})
+}
+
+// AcceptInvitation godoc
+//
+//	@Summary		Accept an invitation
+//	@Description	Create the invited account with the invitation's email and role. The name defaults to the one on the invitation, if any. The email counts as verified because the link was sent to it.
+//	@Tags			Invitations
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		object{token=string,nama=string,password=string}	true	"Invitation token, name and password"
+//	@Success		201		{object}	map[string]interface{}								"Account created"
+//	@Failure		400		{object}	map[string]string									"Invalid or expired invitation"
+//	@Failure		409		{object}	map[string]string									"Email already registered"
+//	@Failure		422		{object}	map[string]interface{}								"Password violates the password policy"
+//	@Failure		500		{object}	map[string]string									"Internal server error"
+//	@Router			/auth/invitations/accept [post]
+func AcceptInvitation(c *fiber.Ctx) error {
+	var input struct {
+		Token    string `json:"token"`
+		Nama     string `json:"nama"`
+		Password string `json:"password"`
+	}
+	if err := c.BodyParser(&input); err != nil || input.Token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Request tidak valid"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	invitation, err := findInvitationByToken(ctx, input.Token)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Undangan tidak valid atau sudah kedaluwarsa"})
+	}
+
+	// Imported invitations carry the name from the CSV; the invitee may still change it
+	nama := strings.TrimSpace(input.Nama)
+	if nama == "" {
+		nama = invitation.Nama
+	}
+	if nama == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Request tidak valid"})
+	}
+
+	count, err := config.UserCollectionRef.CountDocuments(ctx, emailFilter(invitation.Email))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memeriksa email"})
+	}
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Email sudah terdaftar"})
+	}
+
+	now := time.Now()
+	user := models.User{
+		Nama:            nama,
+		Email:           invitation.Email,
+		Role:            invitation.Role,
+		Bio:             invitation.Bio,
+		WorkspaceID:     models.WorkspaceOrDefault(invitation.WorkspaceID),
+		AccountStatus:   models.AccountStatusActive,
+		EmailVerifiedAt: &now,
+	}
+	if handled, err := validatePassword(c, input.Password, user); handled {
+		return err
+	}
+	if _, err := applyNewPassword(&user, input.Password); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal hash password"})
+	}
+
+	// Claim the invitation first so two concurrent accepts can't both create an account
+	result, err := config.InvitationCollectionRef.UpdateOne(ctx,
+		bson.M{"_id": invitation.ID, "status": models.InvitationPending},
+		bson.M{"$set": bson.M{"status": models.InvitationAccepted, "acceptedAt": now}},
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal menerima undangan"})
+	}
+	if result.MatchedCount == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Undangan tidak valid atau sudah kedaluwarsa"})
+	}
+
+	inserted, err := config.UserCollectionRef.InsertOne(ctx, user)
+	if err != nil {
+		// Give the invitation back so the invitee can try again
+		config.InvitationCollectionRef.UpdateOne(ctx,
+			bson.M{"_id": invitation.ID},
+			bson.M{"$set": bson.M{"status": models.InvitationPending}, "$unset": bson.M{"acceptedAt": ""}},
+		)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal menyimpan user"})
+	}
+	user.ID = insertedIDString(inserted.InsertedID)
+
+	_, err = config.InvitationCollectionRef.UpdateOne(ctx,
+		bson.M{"_id": invitation.ID},
+		bson.M{"$set": bson.M{"userId": user.ID}},
+	)
+	if err != nil {
+		fmt.Println("Error linking invitation to user:", err)
+	}
+	if invitation.TeamID != nil {
+		if err := addUserToTeam(ctx, *invitation.TeamID, user.ID); err != nil {
+			fmt.Println("Error adding invited user to team:", err)
+		}
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Akun berhasil dibuat, silakan login",
+		"user": fiber.Map{
+			"id":    user.ID,
+			"nama":  user.Nama,
+			"email": user.Email,
+			"role":  user.Role,
+		},
+	})
+}
+
+// findInvitationByToken checks the link's signature and returns its invitation while it's still pending
+func findInvitationByToken(ctx context.Context, token string) (models.Invitation, error) {
+	var invitation models.Invitation
+
+	claims, err := utils.ParsePurposeToken(token, purposeInvitation)
+	if err != nil {
+		return invitation, err
+	}
+	invitationID, _ := claims["inv"].(string)
+	objectID, err := primitive.ObjectIDFromHex(invitationID)
+	if err != nil {
+		return invitation, err
+	}
+
+	err = config.InvitationCollectionRef.FindOne(ctx, bson.M{
+		"_id":       objectID,
+		"status":    models.InvitationPending,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}).Decode(&invitation)
+	if err != nil {
+		return invitation, err
+	}
+
+	// The link is only good for the address it was sent to
+	if email, _ := claims["email"].(string); email != invitation.Email {
+		return invitation, mongo.ErrNoDocuments
+	}
+	return invitation, nil
+}
+
+// saveInvitation stores a new invitation. Only the newest invitation for an
+// address stays usable in a workspace, so its earlier pending ones are revoked.
+func saveInvitation(ctx context.Context, invitation models.Invitation) error {
+	_, err := config.InvitationCollectionRef.UpdateMany(ctx,
+		scopeToWorkspace(invitation.WorkspaceID, bson.M{"email": invitation.Email, "status": models.InvitationPending}),
+		bson.M{"$set": bson.M{"status": models.InvitationRevoked}},
+	)
+	if err != nil {
+		return err
+	}
+	_, err = config.InvitationCollectionRef.InsertOne(ctx, invitation)
+	return err
+}
+
+// sendInvitationEmail mails the signed accept link
+func sendInvitationEmail(ctx context.Context, invitation models.Invitation, ttl time.Duration) error {
+	token, err := utils.GeneratePurposeToken(purposeInvitation, jwt.MapClaims{
+		"inv":   invitation.ID.Hex(),
+		"email": invitation.Email,
+	}, ttl)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/accept-invitation?token=%s", config.AppURL(), token)
+	return mailer.Send(ctx, mailer.Message{
+		To:      invitation.Email,
+		Subject: "Undangan bergabung ke CoEmotion",
+		Body: fmt.Sprintf("Halo,\n\nAnda diundang bergabung ke CoEmotion sebagai %s. Klik link berikut untuk membuat akun:\n%s\n\nLink ini berlaku selama %s.\n",
+			invitation.Role, link, ttl),
+	})
+}
+
+// emailFilter matches a user's email regardless of case
+func emailFilter(email string) bson.M {
+	return bson.M{"email": bson.M{"$regex": "^" + regexp.QuoteMeta(email) + "$", "$options": "i"}}
+}
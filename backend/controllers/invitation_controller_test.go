@@ -0,0 +1,27 @@
+package controllers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"backend/middleware"
+	"backend/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// The invitation's role becomes the account's role, so it must exist
+func TestCreateInvitationRejectsUnknownRoles(t *testing.T) {
+	app := newTestApp(t)
+	admin := seedUser(t, models.User{Nama: "Admin", Email: "admin@example.com", Role: middleware.RoleAdmin})
+	token := tokenFor(t, admin)
+
+	status, _ := call(t, app, http.MethodPost, "/api/invitations", token, map[string]string{"email": "new@example.com", "role": "admin"})
+	if status != fiber.StatusBadRequest {
+		t.Fatalf("unknown role: status %d, want 400", status)
+	}
+	if ids := listIDs(t, app, "/api/invitations", token); len(ids) != 0 {
+		t.Fatalf("invitation stored: %v", ids)
+	}
+	created(t, app, "/api/invitations", token, map[string]string{"email": "new@example.com", "role": "Team Leader"})
+}
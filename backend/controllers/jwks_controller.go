@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetJWKS godoc
+//
+//	@Summary		JSON Web Key Set
+//	@Description	Public keys that verify CoEmotion access tokens, selected by the token's kid header
+//	@Tags			Auth
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Key set"
+//	@Router			/.well-known/jwks.json [get]
+func GetJWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(utils.JWKS())
+}
@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/config"
+	"backend/models"
+	"backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// invalidCredentialsError is returned for every failed login, whatever the reason,
+// so responses don't reveal which emails are registered
+const invalidCredentialsError = "Email atau password salah"
+
+// loginGuardPolicy controls brute-force protection. Every failure beyond the free
+// attempts doubles the lockout, starting at LockoutBase and capped at LockoutMax.
+type loginGuardPolicy struct {
+	AccountFreeAttempts int
+	IPFreeAttempts      int
+	LockoutBase         time.Duration
+	LockoutMax          time.Duration
+	Window              time.Duration
+}
+
+func currentLoginGuardPolicy() loginGuardPolicy {
+	return loginGuardPolicy{
+		AccountFreeAttempts: utils.IntFromEnv("LOGIN_ACCOUNT_FREE_ATTEMPTS", 5),
+		IPFreeAttempts:      utils.IntFromEnv("LOGIN_IP_FREE_ATTEMPTS", 20),
+		LockoutBase:         utils.DurationFromEnv("LOGIN_LOCKOUT_BASE", 30*time.Second),
+		LockoutMax:          utils.DurationFromEnv("LOGIN_LOCKOUT_MAX", 15*time.Minute),
+		Window:              utils.DurationFromEnv("LOGIN_ATTEMPT_WINDOW", time.Hour),
+	}
+}
+
+// lockoutFor returns how long to lock a key after its nth failure
+func (p loginGuardPolicy) lockoutFor(failures, freeAttempts int) time.Duration {
+	over := failures - freeAttempts
+	if over <= 0 {
+		return 0
+	}
+	lockout := float64(p.LockoutBase) * math.Pow(2, float64(over-1))
+	if lockout > float64(p.LockoutMax) {
+		return p.LockoutMax
+	}
+	return time.Duration(lockout)
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+func accountAttemptKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// loginLockRemaining returns how long the longest active lock among keys still lasts
+func loginLockRemaining(ctx context.Context, keys ...string) (time.Duration, error) {
+	cursor, err := config.LoginAttemptCollectionRef.Find(ctx, bson.M{
+		"_id":         bson.M{"$in": keys},
+		"lockedUntil": bson.M{"$gt": time.Now()},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var remaining time.Duration
+	for cursor.Next(ctx) {
+		var attempt models.LoginAttempt
+		if err := cursor.Decode(&attempt); err != nil {
+			return 0, err
+		}
+		if d := time.Until(*attempt.LockedUntil); d > remaining {
+			remaining = d
+		}
+	}
+	return remaining, cursor.Err()
+}
+
+// recordLoginFailure counts a failure against the key and locks it once the free attempts are used up
+func recordLoginFailure(ctx context.Context, key string, freeAttempts int) error {
+	policy := currentLoginGuardPolicy()
+	now := time.Now()
+
+	var attempt models.LoginAttempt
+	err := config.LoginAttemptCollectionRef.FindOneAndUpdate(ctx,
+		bson.M{"_id": key},
+		bson.M{
+			"$inc": bson.M{"failures": 1},
+			"$set": bson.M{"lastFailureAt": now, "expiresAt": now.Add(policy.Window)},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&attempt)
+	if err != nil {
+		return err
+	}
+
+	lockout := policy.lockoutFor(attempt.Failures, freeAttempts)
+	if lockout == 0 {
+		return nil
+	}
+
+	lockedUntil := now.Add(lockout)
+	expiresAt := lockedUntil.Add(policy.Window)
+	_, err = config.LoginAttemptCollectionRef.UpdateOne(ctx,
+		bson.M{"_id": key},
+		bson.M{"$set": bson.M{"lockedUntil": lockedUntil, "expiresAt": expiresAt}},
+	)
+	fmt.Printf("Login key %s locked for %s after %d failures\n", key, lockout, attempt.Failures)
+	return err
+}
+
+// recordFailedLogin counts one failed attempt against both the client IP and the account
+func recordFailedLogin(ctx context.Context, c *fiber.Ctx, email string) {
+	policy := currentLoginGuardPolicy()
+	if err := recordLoginFailure(ctx, ipAttemptKey(c.IP()), policy.IPFreeAttempts); err != nil {
+		fmt.Println("Error recording login failure:", err)
+	}
+	if err := recordLoginFailure(ctx, accountAttemptKey(email), policy.AccountFreeAttempts); err != nil {
+		fmt.Println("Error recording login failure:", err)
+	}
+}
+
+// clearAccountLoginFailures resets the counter of an account after a successful login or an admin unlock.
+// IP counters are left alone so one valid account can't be used to reset them.
+func clearAccountLoginFailures(ctx context.Context, email string) error {
+	_, err := config.LoginAttemptCollectionRef.DeleteOne(ctx, bson.M{"_id": accountAttemptKey(email)})
+	return err
+}
+
+// rejectIfLoginLocked writes a 429 response when the IP or the account is locked.
+// It returns true when a response was written and the handler should return.
+func rejectIfLoginLocked(ctx context.Context, c *fiber.Ctx, email string) (bool, error) {
+	remaining, err := loginLockRemaining(ctx, ipAttemptKey(c.IP()), accountAttemptKey(email))
+	if err != nil {
+		fmt.Println("Error checking login lockout:", err)
+		return true, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memeriksa percobaan login"})
+	}
+	if remaining <= 0 {
+		return false, nil
+	}
+
+	retryAfter := int(math.Ceil(remaining.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, fmt.Sprint(retryAfter))
+	return true, c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"error":      "Terlalu banyak percobaan login, coba lagi nanti",
+		"retryAfter": retryAfter,
+	})
+}
+
+var (
+	dummyPasswordHash     string
+	dummyPasswordHashOnce sync.Once
+)
+
+// burnPasswordCheck runs a password comparison against a throwaway hash so that
+// unknown emails take as long to reject as wrong passwords
+func burnPasswordCheck(password string) {
+	dummyPasswordHashOnce.Do(func() {
+		hash, err := utils.HashPassword("coemotion-dummy-password")
+		if err != nil {
+			fmt.Println("Error creating dummy password hash:", err)
+		}
+		dummyPasswordHash = hash
+	})
+	utils.CheckPasswordHash(password, dummyPasswordHash)
+}
+
+// UnlockUser godoc
+//
+//	@Summary		Unlock a locked account
+//	@Description	Clear the failed login counter and any temporary lockout of a user account
+//	@Tags			Admin
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			id	path		string				true	"User ID"
+//	@Success		200	{object}	map[string]string	"Account unlocked"
+//	@Failure		403	{object}	map[string]string	"Forbidden"
+//	@Failure		404	{object}	map[string]string	"User not found"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Router			/api/admin/users/{id}/unlock [post]
+func UnlockUser(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := findWorkspaceUser(ctx, c, c.Params("id"))
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch user"})
+	}
+
+	if err := clearAccountLoginFailures(ctx, user.Email); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to unlock account"})
+	}
+
+	fmt.Println("Account unlocked:", user.ID)
+	return c.JSON(fiber.Map{"message": "Account unlocked"})
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend/config"
+	"backend/mailer"
+	"backend/models"
+	"backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// RequestMagicLink godoc
+//
+//	@Summary		Request a login link
+//	@Description	Email a single-use link that logs the user in without a password. The response is the same whether or not the email is registered.
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		object{email=string}	true	"Account email"
+//	@Success		200		{object}	map[string]string		"Login link sent if the account exists"
+//	@Failure		400		{object}	map[string]string		"Invalid request"
+//	@Failure		429		{object}	map[string]string		"Too many requests"
+//	@Router			/auth/magic-link [post]
+func RequestMagicLink(c *fiber.Ctx) error {
+	var input struct {
+		Email string `json:"email"`
+	}
+	if err := c.BodyParser(&input); err != nil || strings.TrimSpace(input.Email) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Request tidak valid"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Same answer either way, so this endpoint can't be used to find registered emails
+	response := fiber.Map{"message": "Jika email terdaftar, link login telah dikirim"}
+
+	var user models.User
+	err := config.UserCollectionRef.FindOne(ctx, emailFilter(strings.TrimSpace(input.Email))).Decode(&user)
+	if err != nil {
+		fmt.Println("Magic link requested for unknown email")
+		return c.JSON(response)
+	}
+
+	// Only the newest link should work
+	if err := invalidateOneTimeTokens(ctx, user.ID, models.TokenPurposeMagicLogin); err != nil {
+		fmt.Println("Error invalidating old login links:", err)
+	}
+
+	ttl := utils.DurationFromEnv("MAGIC_LINK_TTL", 15*time.Minute)
+	token, err := createOneTimeToken(ctx, user.ID, models.TokenPurposeMagicLogin, ttl)
+	if err != nil {
+		fmt.Println("Error creating login link token:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal membuat link login"})
+	}
+
+	link := fmt.Sprintf("%s/magic-login?token=%s", config.AppURL(), token)
+	err = mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Link login CoEmotion",
+		Body: fmt.Sprintf("Halo %s,\n\nKlik link berikut untuk masuk ke CoEmotion:\n%s\n\nLink ini berlaku selama %s dan hanya bisa dipakai sekali. Abaikan email ini jika Anda tidak meminta link login.\n",
+			user.Nama, link, ttl),
+	})
+	if err != nil {
+		fmt.Println("Error sending login link email:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal mengirim email"})
+	}
+
+	return c.JSON(response)
+}
+
+// MagicLinkLogin godoc
+//
+//	@Summary		Log in with a login link
+//	@Description	Exchange the token from a login link for the usual token pair. The link replaces the password only: accounts with 2FA get a challengeToken to complete at /auth/login/2fa. Following the link also confirms the email of a pending account.
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		object{token=string}	true	"Token from the login link"
+//	@Success		200		{object}	map[string]interface{}	"Login successful or 2FA challenge"
+//	@Failure		400		{object}	map[string]string		"Invalid, expired or used link"
+//	@Failure		500		{object}	map[string]string		"Internal server error"
+//	@Router			/auth/magic-link/login [post]
+func MagicLinkLogin(c *fiber.Ctx) error {
+	var input struct {
+		Token string `json:"token"`
+	}
+	if err := c.BodyParser(&input); err != nil || input.Token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Request tidak valid"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	loginToken, err := consumeOneTimeToken(ctx, input.Token, models.TokenPurposeMagicLogin)
+	if err != nil {
+		if errors.Is(err, errInvalidOneTimeToken) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Link login tidak valid atau sudah kedaluwarsa"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memeriksa link login"})
+	}
+
+	user, err := findUserByID(ctx, loginToken.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Link login tidak valid atau sudah kedaluwarsa"})
+	}
+
+	// The link went to the user's inbox, which is all email verification proves
+	if user.IsPendingVerification() {
+		err = updateUserFields(ctx, user.ID, bson.M{
+			"accountStatus":   models.AccountStatusActive,
+			"emailVerifiedAt": time.Now(),
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memverifikasi email"})
+		}
+		user.AccountStatus = models.AccountStatusActive
+	}
+
+	if err := clearAccountLoginFailures(ctx, user.Email); err != nil {
+		fmt.Println("Error clearing login failures:", err)
+	}
+
+	step, err := twoFactorLoginStep(ctx, user)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memeriksa 2FA"})
+	}
+	if step != nil {
+		return c.JSON(step)
+	}
+
+	return respondWithTokens(c, ctx, user, "Login berhasil")
+}
@@ -0,0 +1,193 @@
+package controllers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"backend/config"
+	"backend/middleware"
+	"backend/models"
+	"backend/routes"
+	"backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Controller tests run the real routes against MongoDB. Point MONGO_TEST_URI at a
+// server (e.g. mongodb://localhost:27017) to run them; every test gets its own
+// database, dropped afterwards. Without it they are skipped.
+
+const testPassword = "Correct-Horse-42-Battery"
+
+func TestMain(m *testing.M) {
+	// Fast hashes; the parameters don't matter here
+	os.Setenv("PASSWORD_HASH_ALGORITHM", utils.HashAlgorithmBcrypt)
+	os.Setenv("BCRYPT_COST", "4")
+	os.Setenv("WEBAUTHN_RP_ID", rpID)
+	os.Setenv("WEBAUTHN_RP_ORIGINS", rpOrigin)
+
+	if err := utils.LoadJWTKeys(); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(m.Run())
+}
+
+// newTestApp serves the API from a fresh database
+func newTestApp(t *testing.T) *fiber.App {
+	t.Helper()
+
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	db := client.Database("coemotion_test_" + primitive.NewObjectID().Hex())
+	config.UseDatabase(db)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		db.Drop(ctx)
+		client.Disconnect(ctx)
+	})
+
+	app := fiber.New()
+	routes.SetupRoutes(app)
+	return app
+}
+
+// seedUser stores an active user with testPassword and returns it with its ID
+func seedUser(t *testing.T, user models.User) models.User {
+	t.Helper()
+
+	if user.Role == "" {
+		user.Role = middleware.DefaultRole
+	}
+	if user.AccountStatus == "" {
+		user.AccountStatus = models.AccountStatusActive
+	}
+	hash, err := utils.HashPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.Password = hash
+
+	result, err := config.UserCollectionRef.InsertOne(context.Background(), user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.ID = result.InsertedID.(primitive.ObjectID).Hex()
+	return user
+}
+
+// tokenFor signs an access token for user, as a login would
+func tokenFor(t *testing.T, user models.User) string {
+	t.Helper()
+
+	token, err := utils.GenerateJWT(utils.AccessClaims{
+		UserID:      user.ID,
+		Email:       user.Email,
+		Nama:        user.Nama,
+		Role:        user.Role,
+		WorkspaceID: user.Workspace(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// call sends a request with an optional JSON body and bearer token, and returns
+// the status and the raw response body. A []byte body is sent as is.
+func call(t *testing.T, app *fiber.App, method, path, token string, body interface{}) (int, []byte) {
+	t.Helper()
+
+	var reader io.Reader
+	switch body := body.(type) {
+	case nil:
+	case []byte:
+		reader = bytes.NewReader(body)
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, data
+}
+
+// callJSON is call with the response decoded into a map
+func callJSON(t *testing.T, app *fiber.App, method, path, token string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+
+	status, data := call(t, app, method, path, token, body)
+	var decoded map[string]interface{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("%s %s: response is not a JSON object: %s", method, path, data)
+		}
+	}
+	return status, decoded
+}
+
+// findUser reads a user back from the database
+func findUser(t *testing.T, id string) models.User {
+	t.Helper()
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var user models.User
+	if err := config.UserCollectionRef.FindOne(context.Background(), map[string]interface{}{"_id": objectID}).Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func findUserByEmail(t *testing.T, email string) models.User {
+	t.Helper()
+
+	var user models.User
+	if err := config.UserCollectionRef.FindOne(context.Background(), map[string]interface{}{"email": email}).Decode(&user); err != nil {
+		t.Fatalf("%s: %v", email, err)
+	}
+	return user
+}
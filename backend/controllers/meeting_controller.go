@@ -2,9 +2,12 @@ package controllers
 
 import (
 	"backend/config"
+	"backend/middleware"
 	"backend/models"
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -16,7 +19,7 @@ import (
 
 // CreateMeeting godoc
 //	@Summary		Create a new meeting
-//	@Description	Create a new meeting with the provided details
+//	@Description	Create a new meeting with the provided details. Set teamId to invite every member of one of your teams instead of a participant list.
 //	@Tags			Meetings
 //	@Accept			json
 //	@Produce		json
@@ -50,6 +53,7 @@ func CreateMeeting(c *fiber.Ctx) error {
 	// Set meeting data
 	meeting.CreatedBy = creatorID
 	meeting.CreatedAt = time.Now()
+	meeting.WorkspaceID = middleware.CurrentWorkspaceID(c)
 
 	// Generate new ObjectID if not provided
 	if meeting.ID.IsZero() {
@@ -60,6 +64,17 @@ func CreateMeeting(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	// A team meeting invites the team, not a participant list
+	if meeting.TeamID != nil {
+		if handled, err := checkMeetingTeam(ctx, c, *meeting.TeamID); handled {
+			return err
+		}
+		meeting.AllMembers = false
+		meeting.Participants = nil
+	} else if handled, err := checkMeetingParticipants(ctx, c, meeting.Participants, nil); handled {
+		return err
+	}
+
 	_, err = config.MeetingCollectionRef.InsertOne(ctx, meeting)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create meeting"})
@@ -88,7 +103,7 @@ func GetMeetings(c *fiber.Ctx) error {
 	fmt.Println("Fetching all meetings")
 
 	// Find all meetings
-	cursor, err := config.MeetingCollectionRef.Find(ctx, bson.M{})
+	cursor, err := config.MeetingCollectionRef.Find(ctx, inWorkspace(c, bson.M{}))
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			// Return empty array if no meetings
@@ -141,7 +156,7 @@ func GetUpcomingMeetings(c *fiber.Ctx) error {
 		// 1. Today but start time is in the future
 		// 2. Future dates
 		{
-			"$match": bson.M{
+			"$match": inWorkspace(c, bson.M{
 				"$or": []bson.M{
 					{
 						"$and": []bson.M{
@@ -151,7 +166,7 @@ func GetUpcomingMeetings(c *fiber.Ctx) error {
 					},
 					{"date": bson.M{"$gt": today}},
 				},
-			},
+			}),
 		},
 		// Sort by date, then by time
 		{
@@ -207,7 +222,7 @@ func GetTodayMeetings(c *fiber.Ctx) error {
 	defer cancel()
 
 	// Find today's meetings
-	cursor, err := config.MeetingCollectionRef.Find(ctx, bson.M{"date": today})
+	cursor, err := config.MeetingCollectionRef.Find(ctx, inWorkspace(c, bson.M{"date": today}))
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			// Return empty array if no meetings
@@ -235,11 +250,14 @@ func GetTodayMeetings(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(meetingResponses)
 }
 
-// Helper function to populate meeting response with user details
+// Helper function to populate meeting response with user details.
+// Only users of the meeting's workspace are looked up.
 func populateMeetingResponse(meeting models.Meeting) models.MeetingResponse {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	workspaceID := models.WorkspaceOrDefault(meeting.WorkspaceID)
+
 	response := models.MeetingResponse{
 		ID:          meeting.ID,
 		Title:       meeting.Title,
@@ -249,11 +267,13 @@ func populateMeetingResponse(meeting models.Meeting) models.MeetingResponse {
 		Duration:    meeting.Duration,
 		CreatedAt:   meeting.CreatedAt,
 		AllMembers:  meeting.AllMembers,
+		TeamID:      meeting.TeamID,
+		WorkspaceID: workspaceID,
 	}
 
 	// Get creator details
 	var creator models.User
-	err := config.UserCollectionRef.FindOne(ctx, bson.M{"_id": meeting.CreatedBy}).Decode(&creator)
+	err := config.UserCollectionRef.FindOne(ctx, scopeToWorkspace(workspaceID, bson.M{"_id": meeting.CreatedBy})).Decode(&creator)
 	if err == nil {
 		// Fix profileImage URL if it exists
 		if creator.ProfileImage != "" && !IsAbsoluteURL(creator.ProfileImage) {
@@ -261,18 +281,39 @@ func populateMeetingResponse(meeting models.Meeting) models.MeetingResponse {
 		}
 		response.CreatedBy = creator
 	} else {
-		fmt.Printf("Error fetching creator: %v\n", err)
-		response.CreatedBy = models.User{
-			Nama: "Unknown User",
-		}
+		// Purged creators keep their name through the tombstone they left
+		response.CreatedBy = deletedUserStandIn(ctx, workspaceID, meeting.CreatedBy)
 	}
 
 	// Get participants details
-	if !meeting.AllMembers {
+	if meeting.TeamID != nil {
+		// Team meetings invite whoever is in the team now
+		var team models.Team
+		err := config.TeamCollectionRef.FindOne(ctx, scopeToWorkspace(workspaceID, bson.M{"_id": *meeting.TeamID})).Decode(&team)
+		if err == nil {
+			filter := bson.M{"$and": []bson.M{usersByIDFilter(team.Members), notDeletedFilter()}}
+			cursor, err := config.UserCollectionRef.Find(ctx, scopeToWorkspace(workspaceID, filter))
+			if err == nil {
+				var members []models.User
+				if err = cursor.All(ctx, &members); err == nil {
+					for i, member := range members {
+						if member.ProfileImage != "" && !IsAbsoluteURL(member.ProfileImage) {
+							members[i].ProfileImage = "/uploads/" + GetFilenameFromPath(member.ProfileImage)
+						}
+					}
+					response.Participants = members
+				}
+				cursor.Close(ctx)
+			}
+		}
+		if err != nil {
+			fmt.Printf("Error fetching team members: %v\n", err)
+		}
+	} else if !meeting.AllMembers {
 		var participants []models.User
 		for _, participantID := range meeting.Participants {
 			var participant models.User
-			err := config.UserCollectionRef.FindOne(ctx, bson.M{"_id": participantID}).Decode(&participant)
+			err := config.UserCollectionRef.FindOne(ctx, scopeToWorkspace(workspaceID, bson.M{"_id": participantID})).Decode(&participant)
 			if err == nil {
 				// Fix profileImage URL if it exists
 				if participant.ProfileImage != "" && !IsAbsoluteURL(participant.ProfileImage) {
@@ -280,13 +321,13 @@ func populateMeetingResponse(meeting models.Meeting) models.MeetingResponse {
 				}
 				participants = append(participants, participant)
 			} else {
-				fmt.Printf("Error fetching participant: %v\n", err)
+				participants = append(participants, deletedUserStandIn(ctx, workspaceID, participantID))
 			}
 		}
 		response.Participants = participants
 	} else {
-		// If all members are included, get all users
-		cursor, err := config.UserCollectionRef.Find(ctx, bson.M{})
+		// If all members are included, get all users of the workspace
+		cursor, err := config.UserCollectionRef.Find(ctx, scopeToWorkspace(workspaceID, notDeletedFilter()))
 		if err == nil {
 			var allUsers []models.User
 			if err = cursor.All(ctx, &allUsers); err == nil {
@@ -321,6 +362,62 @@ func GetFilenameFromPath(path string) string {
 	return path
 }
 
+// findMeetingByID looks a meeting of the caller's workspace up by ObjectID first, then by string ID
+func findMeetingByID(ctx context.Context, c *fiber.Ctx, meetingID string) (models.Meeting, error) {
+	var meeting models.Meeting
+
+	objectID, err := primitive.ObjectIDFromHex(meetingID)
+	if err == nil {
+		err = config.MeetingCollectionRef.FindOne(ctx, inWorkspace(c, bson.M{"_id": objectID})).Decode(&meeting)
+		if err == nil {
+			return meeting, nil
+		}
+	}
+
+	err = config.MeetingCollectionRef.FindOne(ctx, inWorkspace(c, bson.M{"_id": meetingID})).Decode(&meeting)
+	return meeting, err
+}
+
+// canModifyMeeting checks the caller against the meeting's creator.
+// The creator needs ownPerm, everyone else needs anyPerm.
+func canModifyMeeting(c *fiber.Ctx, meeting models.Meeting, ownPerm, anyPerm string) bool {
+	if meeting.CreatedBy.Hex() == middleware.CurrentUserID(c) {
+		return middleware.Can(c, ownPerm)
+	}
+	return middleware.Can(c, anyPerm)
+}
+
+// checkMeetingTeam makes sure a meeting can target the team: it must exist and the
+// caller must belong to it. handled is true when a response was written.
+func checkMeetingTeam(ctx context.Context, c *fiber.Ctx, teamID primitive.ObjectID) (bool, error) {
+	team, err := findTeamByID(ctx, c, teamID.Hex())
+	if err != nil && err != mongo.ErrNoDocuments {
+		return true, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch team"})
+	}
+	if err != nil || !canReadTeam(c, team) {
+		return true, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unknown team"})
+	}
+	return false, nil
+}
+
+// checkMeetingParticipants answers 400 unless every participant is a user of the caller's
+// workspace. Participants the meeting already has may stay even if they were deleted since.
+func checkMeetingParticipants(ctx context.Context, c *fiber.Ctx, participants, current []primitive.ObjectID) (bool, error) {
+	var added []string
+	for _, participant := range participants {
+		if !slices.Contains(current, participant) {
+			added = append(added, participant.Hex())
+		}
+	}
+	if err := checkTeamUsers(ctx, c, added); err != nil {
+		if errors.Is(err, errUnknownTeamUser) {
+			return true, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return true, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check participants"})
+	}
+	return false, nil
+}
+
 // GetMeetingById godoc
 //	@Summary		Get meeting by ID
 //	@Description	Get meeting information by meeting ID
@@ -344,12 +441,12 @@ func GetMeetingById(c *fiber.Ctx) error {
 	// Try with ObjectID first
 	objectID, err := primitive.ObjectIDFromHex(meetingID)
 	if err == nil {
-		err = config.MeetingCollectionRef.FindOne(ctx, bson.M{"_id": objectID}).Decode(&meeting)
+		err = config.MeetingCollectionRef.FindOne(ctx, inWorkspace(c, bson.M{"_id": objectID})).Decode(&meeting)
 	}
 
 	// Fall back to string ID if ObjectID fails
 	if err != nil {
-		err = config.MeetingCollectionRef.FindOne(ctx, bson.M{"_id": meetingID}).Decode(&meeting)
+		err = config.MeetingCollectionRef.FindOne(ctx, inWorkspace(c, bson.M{"_id": meetingID})).Decode(&meeting)
 	}
 
 	if err != nil {
@@ -376,6 +473,7 @@ func GetMeetingById(c *fiber.Ctx) error {
 //	@Param			meeting	body		models.Meeting		true	"Meeting update data"
 //	@Success		200		{object}	models.Meeting		"Meeting updated successfully"
 //	@Failure		400		{object}	map[string]string	"Invalid request"
+//	@Failure		403		{object}	map[string]string	"Forbidden - Not the meeting creator"
 //	@Failure		404		{object}	map[string]string	"Meeting not found"
 //	@Failure		500		{object}	map[string]string	"Internal server error"
 //	@Router			/api/meetings/{id} [put]
@@ -390,33 +488,55 @@ func UpdateMeeting(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	existing, err := findMeetingByID(ctx, c, meetingID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{"error": "Meeting not found"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch meeting"})
+	}
+	if !canModifyMeeting(c, existing, middleware.PermMeetingsUpdateOwn, middleware.PermMeetingsUpdateAny) {
+		return c.Status(403).JSON(fiber.Map{"error": "You can only update meetings you created"})
+	}
+
 	// Create update document
-	update := bson.M{
-		"$set": bson.M{
-			"title":        updateData.Title,
-			"description":  updateData.Description,
-			"date":         updateData.Date,
-			"time":         updateData.Time,
-			"duration":     updateData.Duration,
-			"allMembers":   updateData.AllMembers,
-			"participants": updateData.Participants,
-		},
+	fields := bson.M{
+		"title":        updateData.Title,
+		"description":  updateData.Description,
+		"date":         updateData.Date,
+		"time":         updateData.Time,
+		"duration":     updateData.Duration,
+		"allMembers":   updateData.AllMembers,
+		"participants": updateData.Participants,
+	}
+	update := bson.M{"$set": fields, "$unset": bson.M{"teamId": ""}}
+
+	// A team meeting invites the team, not a participant list
+	if updateData.TeamID != nil {
+		if handled, err := checkMeetingTeam(ctx, c, *updateData.TeamID); handled {
+			return err
+		}
+		fields["allMembers"] = false
+		fields["participants"] = nil
+		fields["teamId"] = updateData.TeamID
+		update = bson.M{"$set": fields}
+	} else if handled, err := checkMeetingParticipants(ctx, c, updateData.Participants, existing.Participants); handled {
+		return err
 	}
 
 	var updateResult *mongo.UpdateResult
-	var err error
 
 	// Try with ObjectID first
 	objectID, err := primitive.ObjectIDFromHex(meetingID)
 	if err == nil {
-		updateResult, err = config.MeetingCollectionRef.UpdateOne(ctx, bson.M{"_id": objectID}, update)
+		updateResult, err = config.MeetingCollectionRef.UpdateOne(ctx, inWorkspace(c, bson.M{"_id": objectID}), update)
 		if err == nil && updateResult.MatchedCount > 0 {
 			return c.JSON(fiber.Map{"message": "Meeting updated successfully"})
 		}
 	}
 
 	// Fall back to string ID
-	updateResult, err = config.MeetingCollectionRef.UpdateOne(ctx, bson.M{"_id": meetingID}, update)
+	updateResult, err = config.MeetingCollectionRef.UpdateOne(ctx, inWorkspace(c, bson.M{"_id": meetingID}), update)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update meeting"})
 	}
@@ -436,6 +556,7 @@ func UpdateMeeting(c *fiber.Ctx) error {
 //	@Security		Bearer
 //	@Param			id	path		string				true	"Meeting ID"
 //	@Success		200	{object}	map[string]string	"Meeting deleted successfully"
+//	@Failure		403	{object}	map[string]string	"Forbidden - Not the meeting creator"
 //	@Failure		404	{object}	map[string]string	"Meeting not found"
 //	@Failure		500	{object}	map[string]string	"Internal server error"
 //	@Router			/api/meetings/{id} [delete]
@@ -445,20 +566,30 @@ func DeleteMeeting(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	existing, err := findMeetingByID(ctx, c, meetingID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{"error": "Meeting not found"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch meeting"})
+	}
+	if !canModifyMeeting(c, existing, middleware.PermMeetingsDeleteOwn, middleware.PermMeetingsDeleteAny) {
+		return c.Status(403).JSON(fiber.Map{"error": "You can only delete meetings you created"})
+	}
+
 	var deleteResult *mongo.DeleteResult
-	var err error
 
 	// Try with ObjectID first
 	objectID, err := primitive.ObjectIDFromHex(meetingID)
 	if err == nil {
-		deleteResult, err = config.MeetingCollectionRef.DeleteOne(ctx, bson.M{"_id": objectID})
+		deleteResult, err = config.MeetingCollectionRef.DeleteOne(ctx, inWorkspace(c, bson.M{"_id": objectID}))
 		if err == nil && deleteResult.DeletedCount > 0 {
 			return c.JSON(fiber.Map{"message": "Meeting deleted successfully"})
 		}
 	}
 
 	// Fall back to string ID
-	deleteResult, err = config.MeetingCollectionRef.DeleteOne(ctx, bson.M{"_id": meetingID})
+	deleteResult, err = config.MeetingCollectionRef.DeleteOne(ctx, inWorkspace(c, bson.M{"_id": meetingID}))
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete meeting"})
 	}
@@ -0,0 +1,45 @@
+package controllers_test
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"backend/middleware"
+	"backend/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Meeting responses embed whole users, so none of their secrets may be serialized
+func TestMeetingResponsesHideSecrets(t *testing.T) {
+	app := newTestApp(t)
+	admin := seedUser(t, models.User{Nama: "Admin", Email: "admin@example.com", Role: middleware.RoleAdmin})
+	member := seedUser(t, models.User{Nama: "Ana", Email: "ana@example.com"})
+	token := tokenFor(t, admin)
+
+	team := created(t, app, "/api/teams", token, map[string]interface{}{"name": "Alpha", "members": []string{admin.ID, member.ID}})
+	meetings := map[string]map[string]interface{}{
+		"team":         {"teamId": team},
+		"participants": {"participants": []string{member.ID}},
+		"all members":  {"allMembers": true},
+	}
+	for name, invite := range meetings {
+		body := map[string]interface{}{"title": "Planning", "date": "2026-12-01", "time": "10:00", "duration": 30}
+		for k, v := range invite {
+			body[k] = v
+		}
+		id := created(t, app, "/api/meetings", token, body)
+
+		status, data := call(t, app, http.MethodGet, "/api/meetings/"+id, tokenFor(t, member), nil)
+		if status != fiber.StatusOK {
+			t.Fatalf("%s: status %d", name, status)
+		}
+		if !bytes.Contains(data, []byte(member.Email)) {
+			t.Fatalf("%s: participants missing from %s", name, data)
+		}
+		if bytes.Contains(data, []byte(`"password"`)) || bytes.Contains(data, []byte("$2a$")) {
+			t.Fatalf("%s: password hash in %s", name, data)
+		}
+	}
+}
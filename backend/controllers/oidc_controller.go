@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"backend/config"
+	"backend/middleware"
+	"backend/models"
+	"backend/oidc"
+	"backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// oidcStateTTL is how long the user has to finish logging in at the IdP
+const oidcStateTTL = 10 * time.Minute
+
+// OIDCLogin godoc
+//
+//	@Summary		Start single sign-on
+//	@Description	Redirect the browser to the OpenID Connect identity provider (authorization code flow with PKCE)
+//	@Tags			Auth
+//	@Success		302	"Redirect to the identity provider"
+//	@Failure		404	{object}	map[string]string	"Single sign-on is not configured"
+//	@Failure		502	{object}	map[string]string	"Identity provider unreachable"
+//	@Router			/auth/oidc/login [get]
+func OIDCLogin(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	provider, err := oidc.Default(ctx)
+	if err != nil {
+		return oidcProviderError(c, err)
+	}
+
+	state, err := utils.GenerateRandomToken(24)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memulai SSO"})
+	}
+	nonce, err := utils.GenerateRandomToken(24)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memulai SSO"})
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memulai SSO"})
+	}
+
+	now := time.Now()
+	_, err = config.OIDCStateCollectionRef.InsertOne(ctx, models.OIDCState{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(oidcStateTTL),
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memulai SSO"})
+	}
+
+	return c.Redirect(provider.AuthCodeURL(state, nonce, challenge), fiber.StatusFound)
+}
+
+// OIDCCallback godoc
+//
+//	@Summary		Finish single sign-on
+//	@Description	Redirect target of the identity provider. Links or creates the user by verified email, maps IdP groups to a role and logs the user in; accounts with 2FA get a challengeToken for /auth/login/2fa instead of tokens. The result is passed to OIDC_POST_LOGIN_REDIRECT in the URL fragment, or returned as JSON when it is not set.
+//	@Tags			Auth
+//	@Produce		json
+//	@Param			code	query		string					true	"Authorization code"
+//	@Param			state	query		string					true	"State from /auth/oidc/login"
+//	@Success		200		{object}	map[string]interface{}	"Login successful"
+//	@Success		302		"Redirect to the frontend with tokens"
+//	@Failure		400		{object}	map[string]string		"Invalid or expired login attempt"
+//	@Failure		401		{object}	map[string]string		"Identity provider rejected the login"
+//	@Failure		403		{object}	map[string]string		"Email not verified by the identity provider, or no account and registration is closed"
+//	@Router			/auth/oidc/callback [get]
+func OIDCCallback(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	provider, err := oidc.Default(ctx)
+	if err != nil {
+		return oidcProviderError(c, err)
+	}
+
+	if idpError := c.Query("error"); idpError != "" {
+		fmt.Println("OIDC login rejected by IdP:", idpError, c.Query("error_description"))
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Login SSO dibatalkan atau ditolak"})
+	}
+
+	// The state is single-use: deleting it is what consumes it
+	var state models.OIDCState
+	err = config.OIDCStateCollectionRef.FindOneAndDelete(ctx, bson.M{"_id": c.Query("state")}).Decode(&state)
+	if err != nil || time.Now().After(state.ExpiresAt) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Sesi login SSO tidak valid atau sudah kedaluwarsa"})
+	}
+
+	rawIDToken, err := provider.Exchange(ctx, c.Query("code"), state.CodeVerifier)
+	if err != nil {
+		fmt.Println("OIDC code exchange failed:", err)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Login SSO gagal"})
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, state.Nonce)
+	if err != nil {
+		fmt.Println("OIDC id_token rejected:", err)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Login SSO gagal"})
+	}
+
+	user, err := upsertOIDCUser(ctx, provider, claims)
+	if err != nil {
+		if errors.Is(err, errOIDCEmailUnverified) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Email belum diverifikasi oleh identity provider"})
+		}
+		if errors.Is(err, errOIDCRegistrationClosed) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Registrasi ditutup, gunakan undangan dari administrator",
+				"code":  "registration_closed",
+			})
+		}
+		fmt.Println("OIDC user provisioning failed:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal menyiapkan akun SSO"})
+	}
+
+	if user.IsDisabled() {
+		return rejectDisabledAccount(c)
+	}
+	if user.IsPendingVerification() {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Email belum diverifikasi, silakan cek email Anda",
+			"code":  "email_not_verified",
+		})
+	}
+
+	// SSO replaces the password, not the second factor of the linked account:
+	// users with 2FA finish at /auth/login/2fa like any other login
+	step, err := twoFactorLoginStep(ctx, user)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memeriksa 2FA"})
+	}
+	if step != nil {
+		return respondToOIDCLogin(c, provider, step)
+	}
+
+	accessToken, refreshToken, err := startSessionWithTokens(ctx, c, user)
+	if errors.Is(err, errAccountDisabled) {
+		return rejectDisabledAccount(c)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal membuat token"})
+	}
+
+	return respondToOIDCLogin(c, provider, loginPayload(user, accessToken, refreshToken, "Login SSO berhasil"))
+}
+
+// respondToOIDCLogin hands the login result to OIDC_POST_LOGIN_REDIRECT, or answers
+// with it as JSON when no redirect is configured
+func respondToOIDCLogin(c *fiber.Ctx, provider *oidc.Provider, payload fiber.Map) error {
+	if provider.Config.PostLoginRedirect == "" {
+		return c.JSON(payload)
+	}
+
+	// Tokens go in the fragment so they never reach server logs or Referer headers
+	fragment := url.Values{}
+	for _, key := range []string{"token", "refreshToken", "expiresIn", "twoFactorRequired", "challengeToken", "twoFactorSetupRequired", "enrollmentToken"} {
+		if value, ok := payload[key]; ok {
+			fragment.Set(key, fmt.Sprint(value))
+		}
+	}
+	return c.Redirect(provider.Config.PostLoginRedirect+"#"+fragment.Encode(), fiber.StatusFound)
+}
+
+var (
+	errOIDCEmailUnverified    = errors.New("email not verified by identity provider")
+	errOIDCRegistrationClosed = errors.New("open registration is off")
+)
+
+// upsertOIDCUser finds the account for an IdP identity, linking an existing account by email
+// or creating one on first login. The role follows the IdP groups whenever a mapping matches.
+func upsertOIDCUser(ctx context.Context, provider *oidc.Provider, claims jwt.MapClaims) (models.User, error) {
+	subject, _ := claims["sub"].(string)
+	email, _ := claims["email"].(string)
+	email = strings.TrimSpace(email)
+	if subject == "" || email == "" {
+		return models.User{}, errors.New("id_token has no sub or email")
+	}
+	// Only an address the IdP vouches for may be matched against local accounts;
+	// a missing claim counts as unverified
+	emailVerified, _ := claims["email_verified"].(bool)
+
+	name, _ := claims["name"].(string)
+	if name == "" {
+		name, _ = claims["preferred_username"].(string)
+	}
+	if name == "" {
+		name = email
+	}
+	mappedRole := provider.RoleForGroups(provider.Groups(claims))
+	issuer := provider.Config.Issuer
+	now := time.Now()
+
+	// An identity linked before keeps working; anything else needs a verified email,
+	// or an IdP account could take over the local account of that address
+	var user models.User
+	err := config.UserCollectionRef.FindOne(ctx, bson.M{"oidcIssuer": issuer, "oidcSubject": subject}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		if !emailVerified {
+			return models.User{}, errOIDCEmailUnverified
+		}
+		err = config.UserCollectionRef.FindOne(ctx, emailFilter(email)).Decode(&user)
+	}
+
+	switch {
+	case err == mongo.ErrNoDocuments:
+		// Just-in-time provisioning counts as sign-up
+		if !config.OpenRegistration() {
+			return models.User{}, errOIDCRegistrationClosed
+		}
+		user = models.User{
+			Nama:            name,
+			Email:           email,
+			Role:            mappedRole,
+			AccountStatus:   models.AccountStatusActive,
+			EmailVerifiedAt: &now,
+			OIDCIssuer:      issuer,
+			OIDCSubject:     subject,
+		}
+		if user.Role == "" {
+			user.Role = middleware.DefaultRole
+		}
+		result, err := config.UserCollectionRef.InsertOne(ctx, user)
+		if err != nil {
+			return models.User{}, err
+		}
+		user.ID = insertedIDString(result.InsertedID)
+		fmt.Println("Provisioned SSO user:", user.ID)
+		return user, nil
+
+	case err != nil:
+		return models.User{}, err
+	}
+
+	// Existing account: link it and keep role/verification in sync with the IdP.
+	// Roles the mapping hands out are the IdP's to take away too: a user who left
+	// every mapped group goes back to the default role. Roles given locally stay.
+	update := bson.M{"oidcIssuer": issuer, "oidcSubject": subject}
+	role := mappedRole
+	if role == "" && provider.MapsToRole(user.Role) {
+		role = middleware.DefaultRole
+	}
+	if role != "" && role != user.Role {
+		update["role"] = role
+		user.Role = role
+	}
+	if user.IsPendingVerification() && emailVerified {
+		update["accountStatus"] = models.AccountStatusActive
+		update["emailVerifiedAt"] = now
+		user.AccountStatus = models.AccountStatusActive
+	}
+	if err := updateUserFields(ctx, user.ID, update); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// oidcProviderError maps provider setup failures to responses
+func oidcProviderError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, oidc.ErrDisabled) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Single sign-on is not configured"})
+	}
+	fmt.Println("OIDC provider error:", err)
+	return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Identity provider tidak dapat dihubungi"})
+}
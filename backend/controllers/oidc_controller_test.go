@@ -0,0 +1,129 @@
+package controllers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"backend/middleware"
+	"backend/models"
+	"backend/oidc/oidctest"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ssoLogin runs the browser side of an SSO login: /auth/oidc/login, the IdP with
+// claims, and back to /auth/oidc/callback
+func ssoLogin(t *testing.T, app *fiber.App, idp *oidctest.IdP, claims jwt.MapClaims) (int, map[string]interface{}) {
+	t.Helper()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != fiber.StatusFound {
+		t.Fatalf("/auth/oidc/login: status %d, want 302", resp.StatusCode)
+	}
+
+	code, state, err := idp.Authorize(resp.Header.Get("Location"), claims)
+	if err != nil {
+		t.Fatalf("IdP rejected the authorization request: %v", err)
+	}
+	query := url.Values{"code": {code}, "state": {state}}
+	return callJSON(t, app, http.MethodGet, "/auth/oidc/callback?"+query.Encode(), "", nil)
+}
+
+func TestOIDCCallback(t *testing.T) {
+	// The provider is discovered once per process, so every case shares one IdP
+	idp := oidctest.New(t, "coemotion", "s3cret")
+	t.Setenv("OIDC_ISSUER", idp.Issuer())
+	t.Setenv("OIDC_CLIENT_ID", idp.ClientID)
+	t.Setenv("OIDC_CLIENT_SECRET", idp.ClientSecret)
+	t.Setenv("OIDC_ROLE_MAPPING", "idp-admins=Admin")
+	os.Unsetenv("OIDC_POST_LOGIN_REDIRECT")
+
+	t.Run("unverified email does not link to an existing account", func(t *testing.T) {
+		app := newTestApp(t)
+		admin := seedUser(t, models.User{Nama: "Admin", Email: "admin@example.com", Role: middleware.RoleAdmin})
+
+		for name, claims := range map[string]jwt.MapClaims{
+			"missing": {"sub": "attacker-1", "email": "admin@example.com"},
+			"false":   {"sub": "attacker-2", "email": "admin@example.com", "email_verified": false},
+		} {
+			status, body := ssoLogin(t, app, idp, claims)
+			if status != fiber.StatusForbidden || body["token"] != nil {
+				t.Fatalf("email_verified %s: status %d, body %v; want 403 without a token", name, status, body)
+			}
+		}
+		if stored := findUser(t, admin.ID); stored.OIDCSubject != "" {
+			t.Fatalf("account linked to %q", stored.OIDCSubject)
+		}
+	})
+
+	t.Run("verified email links and follows the IdP groups", func(t *testing.T) {
+		app := newTestApp(t)
+		user := seedUser(t, models.User{Nama: "Ana", Email: "ana@example.com"})
+
+		status, body := ssoLogin(t, app, idp, jwt.MapClaims{
+			"sub": "ana", "email": "ANA@example.com", "email_verified": true, "groups": []string{"idp-admins"},
+		})
+		if status != fiber.StatusOK || body["token"] == nil {
+			t.Fatalf("status %d, body %v; want 200 with a token", status, body)
+		}
+		stored := findUser(t, user.ID)
+		if stored.OIDCSubject != "ana" || stored.Role != middleware.RoleAdmin {
+			t.Fatalf("subject %q role %q, want ana and Admin", stored.OIDCSubject, stored.Role)
+		}
+
+		// Leaving the group takes the mapped role away again
+		status, _ = ssoLogin(t, app, idp, jwt.MapClaims{"sub": "ana", "email": "ana@example.com", "groups": []string{}})
+		if status != fiber.StatusOK {
+			t.Fatalf("second login: status %d", status)
+		}
+		if stored := findUser(t, user.ID); stored.Role != middleware.DefaultRole {
+			t.Fatalf("role %q after leaving idp-admins, want %s", stored.Role, middleware.DefaultRole)
+		}
+	})
+
+	t.Run("roles given locally are kept", func(t *testing.T) {
+		app := newTestApp(t)
+		user := seedUser(t, models.User{Nama: "Lead", Email: "lead@example.com", Role: "Team Leader"})
+
+		if status, _ := ssoLogin(t, app, idp, jwt.MapClaims{"sub": "lead", "email": "lead@example.com", "email_verified": true}); status != fiber.StatusOK {
+			t.Fatalf("status %d", status)
+		}
+		if stored := findUser(t, user.ID); stored.Role != "Team Leader" {
+			t.Fatalf("role %q, want Team Leader", stored.Role)
+		}
+	})
+
+	t.Run("accounts with 2FA get a challenge instead of tokens", func(t *testing.T) {
+		app := newTestApp(t)
+		seedUser(t, models.User{Nama: "Budi", Email: "budi@example.com", TwoFactorEnabled: true, TwoFactorSecret: "JBSWY3DPEHPK3PXP"})
+
+		status, body := ssoLogin(t, app, idp, jwt.MapClaims{"sub": "budi", "email": "budi@example.com", "email_verified": true})
+		if status != fiber.StatusOK || body["twoFactorRequired"] != true || body["challengeToken"] == nil {
+			t.Fatalf("status %d, body %v; want a 2FA challenge", status, body)
+		}
+		if body["token"] != nil || body["refreshToken"] != nil {
+			t.Fatal("tokens issued before the second factor")
+		}
+	})
+
+	t.Run("first login provisions the account", func(t *testing.T) {
+		app := newTestApp(t)
+
+		status, body := ssoLogin(t, app, idp, jwt.MapClaims{"sub": "new", "email": "new@example.com", "email_verified": true, "name": "Nia"})
+		if status != fiber.StatusOK || body["token"] == nil {
+			t.Fatalf("status %d, body %v; want 200 with a token", status, body)
+		}
+		user, _ := body["user"].(map[string]interface{})
+		if user["nama"] != "Nia" || user["role"] != middleware.DefaultRole {
+			t.Fatalf("provisioned user %v", user)
+		}
+	})
+}
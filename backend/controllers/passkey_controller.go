@@ -0,0 +1,625 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/config"
+	"backend/middleware"
+	"backend/models"
+	"backend/utils"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// webAuthnCeremonyTTL is how long the user has to answer the authenticator prompt
+const webAuthnCeremonyTTL = 5 * time.Minute
+
+var (
+	relyingParty     *webauthn.WebAuthn
+	relyingPartyErr  error
+	relyingPartyOnce sync.Once
+)
+
+// webAuthn returns the relying party built from WEBAUTHN_RP_ID, WEBAUTHN_RP_NAME and
+// WEBAUTHN_RP_ORIGINS (comma separated). The defaults follow APP_URL.
+func webAuthn() (*webauthn.WebAuthn, error) {
+	relyingPartyOnce.Do(func() {
+		origins := strings.Split(os.Getenv("WEBAUTHN_RP_ORIGINS"), ",")
+		if os.Getenv("WEBAUTHN_RP_ORIGINS") == "" {
+			origins = []string{config.AppURL()}
+		}
+		for i := range origins {
+			origins[i] = strings.TrimSpace(origins[i])
+		}
+
+		rpID := os.Getenv("WEBAUTHN_RP_ID")
+		if rpID == "" {
+			if appURL, err := url.Parse(config.AppURL()); err == nil {
+				rpID = appURL.Hostname()
+			}
+		}
+		rpName := os.Getenv("WEBAUTHN_RP_NAME")
+		if rpName == "" {
+			rpName = "CoEmotion"
+		}
+
+		relyingParty, relyingPartyErr = webauthn.New(&webauthn.Config{
+			RPID:          rpID,
+			RPDisplayName: rpName,
+			RPOrigins:     origins,
+			AuthenticatorSelection: protocol.AuthenticatorSelection{
+				ResidentKey:      protocol.ResidentKeyRequirementRequired,
+				UserVerification: protocol.VerificationRequired,
+			},
+		})
+	})
+	return relyingParty, relyingPartyErr
+}
+
+// webAuthnUser adapts models.User to the WebAuthn library. The user handle is the user ID.
+type webAuthnUser struct {
+	models.User
+}
+
+func (u webAuthnUser) WebAuthnID() []byte          { return []byte(u.ID) }
+func (u webAuthnUser) WebAuthnName() string        { return u.Email }
+func (u webAuthnUser) WebAuthnDisplayName() string { return u.Nama }
+
+func (u webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.Passkeys))
+	for _, passkey := range u.Passkeys {
+		id, err := base64.RawURLEncoding.DecodeString(passkey.ID)
+		if err != nil {
+			continue
+		}
+		transports := make([]protocol.AuthenticatorTransport, 0, len(passkey.Transports))
+		for _, t := range passkey.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+		credentials = append(credentials, webauthn.Credential{
+			ID:              id,
+			PublicKey:       passkey.PublicKey,
+			AttestationType: passkey.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				UserPresent:    true,
+				UserVerified:   passkey.UserVerified,
+				BackupEligible: passkey.BackupEligible,
+				BackupState:    passkey.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:       passkey.AAGUID,
+				SignCount:    passkey.SignCount,
+				CloneWarning: passkey.CloneWarning,
+			},
+		})
+	}
+	return credentials
+}
+
+// BeginPasskeyRegistration godoc
+//
+//	@Summary		Start passkey registration
+//	@Description	Get the WebAuthn creation options for a new passkey. A stolen access token must not be enough to plant a passkey, so the user confirms it's them first with currentPassword, a 2FA code or recoveryCode, or an assertion of an existing passkey (sessionId from /auth/passkeys/login/begin with the account email, and the PublicKeyCredential). Pass options.publicKey to navigator.credentials.create and send the result with sessionId to /api/passkeys/register/finish.
+//	@Tags			Passkeys
+//	@Accept			json
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			body	body		object{currentPassword=string,code=string,recoveryCode=string,assertion=object{sessionId=string,credential=object}}	true	"One proof of identity"
+//	@Success		200		{object}	map[string]interface{}	"sessionId and creation options"
+//	@Failure		400		{object}	map[string]string		"Invalid request body"
+//	@Failure		403		{object}	map[string]string		"Re-authentication failed"
+//	@Failure		500		{object}	map[string]string		"Internal server error"
+//	@Router			/api/passkeys/register/begin [post]
+func BeginPasskeyRegistration(c *fiber.Ctx) error {
+	var input reauthentication
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rp, err := webAuthn()
+	if err != nil {
+		fmt.Println("WebAuthn config error:", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Passkeys are not configured correctly"})
+	}
+
+	user, err := findUserByID(ctx, middleware.CurrentUserID(c))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+	if err := reauthenticate(ctx, rp, user, input); err != nil {
+		if !errors.Is(err, errReauthenticationFailed) {
+			fmt.Println("Error re-authenticating user:", err)
+		}
+		return c.Status(403).JSON(fiber.Map{
+			"error": "Confirm it's you with your password, a 2FA code or one of your passkeys",
+			"code":  "reauthentication_required",
+		})
+	}
+	wu := webAuthnUser{user}
+
+	// Don't let the same authenticator register twice
+	exclude := make([]protocol.CredentialDescriptor, 0, len(user.Passkeys))
+	for _, credential := range wu.WebAuthnCredentials() {
+		exclude = append(exclude, credential.Descriptor())
+	}
+
+	options, session, err := rp.BeginRegistration(wu, webauthn.WithExclusions(exclude))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to start passkey registration"})
+	}
+
+	sessionID, err := saveWebAuthnSession(ctx, models.WebAuthnRegistration, user.ID, session)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to start passkey registration"})
+	}
+
+	return c.JSON(fiber.Map{"sessionId": sessionID, "options": options})
+}
+
+// FinishPasskeyRegistration godoc
+//
+//	@Summary		Finish passkey registration
+//	@Description	Verify the authenticator's attestation and store the new passkey on the user. The sessionId only exists after the re-authentication of /api/passkeys/register/begin and expires after 5 minutes.
+//	@Tags			Passkeys
+//	@Accept			json
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			body	body		object{sessionId=string,name=string,credential=object}	true	"Session ID from begin, a name for the passkey and the PublicKeyCredential"
+//	@Success		201		{object}	models.Passkey											"Passkey registered"
+//	@Failure		400		{object}	map[string]string										"Invalid or expired ceremony"
+//	@Failure		500		{object}	map[string]string										"Internal server error"
+//	@Router			/api/passkeys/register/finish [post]
+func FinishPasskeyRegistration(c *fiber.Ctx) error {
+	var input struct {
+		SessionID  string          `json:"sessionId"`
+		Name       string          `json:"name"`
+		Credential json.RawMessage `json:"credential"`
+	}
+	if err := c.BodyParser(&input); err != nil || input.SessionID == "" || len(input.Credential) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rp, err := webAuthn()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Passkeys are not configured correctly"})
+	}
+
+	userID := middleware.CurrentUserID(c)
+	sessionUserID, session, err := takeWebAuthnSession(ctx, input.SessionID, models.WebAuthnRegistration)
+	if err != nil || sessionUserID != userID {
+		return c.Status(400).JSON(fiber.Map{"error": "Passkey registration expired, please try again"})
+	}
+
+	user, err := findUserByID(ctx, userID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(input.Credential)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid passkey response"})
+	}
+	credential, err := rp.CreateCredential(webAuthnUser{user}, session, parsed)
+	if err != nil {
+		fmt.Println("Passkey registration rejected:", err)
+		return c.Status(400).JSON(fiber.Map{"error": "Passkey could not be verified"})
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		name = fmt.Sprintf("Passkey %d", len(user.Passkeys)+1)
+	}
+	passkey := passkeyFromCredential(credential, name)
+
+	_, err = config.UserCollectionRef.UpdateOne(ctx, models.UserIDFilter(user.ID), bson.M{"$push": bson.M{"passkeys": passkey}})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save passkey"})
+	}
+
+	return c.Status(201).JSON(passkey)
+}
+
+// GetPasskeys godoc
+//
+//	@Summary		List passkeys
+//	@Description	List the passkeys of the current user and whether the account still has a password
+//	@Tags			Passkeys
+//	@Produce		json
+//	@Security		Bearer
+//	@Success		200	{object}	map[string]interface{}	"passkeys and hasPassword"
+//	@Failure		404	{object}	map[string]string		"User not found"
+//	@Router			/api/passkeys [get]
+func GetPasskeys(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := findUserByID(ctx, middleware.CurrentUserID(c))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	passkeys := user.Passkeys
+	if passkeys == nil {
+		passkeys = []models.Passkey{}
+	}
+	return c.JSON(fiber.Map{"passkeys": passkeys, "hasPassword": user.Password != ""})
+}
+
+// DeletePasskey godoc
+//
+//	@Summary		Remove a passkey
+//	@Description	Remove one of the current user's passkeys. The last passkey of an account without a password can't be removed.
+//	@Tags			Passkeys
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			id	path		string				true	"Passkey ID"
+//	@Success		200	{object}	map[string]string	"Passkey removed"
+//	@Failure		404	{object}	map[string]string	"Passkey not found"
+//	@Failure		409	{object}	map[string]string	"Last sign-in method"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Router			/api/passkeys/{id} [delete]
+func DeletePasskey(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := findUserByID(ctx, middleware.CurrentUserID(c))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	passkeyID := c.Params("id")
+	found := false
+	for _, passkey := range user.Passkeys {
+		if passkey.ID == passkeyID {
+			found = true
+		}
+	}
+	if !found {
+		return c.Status(404).JSON(fiber.Map{"error": "Passkey not found"})
+	}
+	if len(user.Passkeys) == 1 && user.Password == "" {
+		return c.Status(409).JSON(fiber.Map{"error": "This is your only way to sign in. Set a password first."})
+	}
+
+	_, err = config.UserCollectionRef.UpdateOne(ctx, models.UserIDFilter(user.ID), bson.M{"$pull": bson.M{"passkeys": bson.M{"id": passkeyID}}})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to remove passkey"})
+	}
+	return c.JSON(fiber.Map{"message": "Passkey removed"})
+}
+
+// RemovePassword godoc
+//
+//	@Summary		Go passwordless
+//	@Description	Remove the password of an account that has at least one passkey, so it can only sign in with passkeys (or email links). A password can be set again through the password reset flow.
+//	@Tags			Passkeys
+//	@Accept			json
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			body	body		object{currentPassword=string}	true	"Current password"
+//	@Success		200		{object}	map[string]string				"Password removed"
+//	@Failure		400		{object}	map[string]string				"Current password is incorrect"
+//	@Failure		409		{object}	map[string]string				"No passkey registered"
+//	@Failure		500		{object}	map[string]string				"Internal server error"
+//	@Router			/api/passkeys/passwordless [post]
+func RemovePassword(c *fiber.Ctx) error {
+	var input struct {
+		CurrentPassword string `json:"currentPassword"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := findUserByID(ctx, middleware.CurrentUserID(c))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+	if len(user.Passkeys) == 0 {
+		return c.Status(409).JSON(fiber.Map{"error": "Register a passkey before removing your password"})
+	}
+	if user.Password == "" {
+		return c.JSON(fiber.Map{"message": "Account is already passwordless"})
+	}
+	if !utils.ComparePasswords(user.Password, input.CurrentPassword) {
+		return c.Status(400).JSON(fiber.Map{"error": "Current password is incorrect"})
+	}
+
+	// The old hash goes into the history so it can't simply be set again later
+	history := append([]string{user.Password}, user.PasswordHistory...)
+	if keep := utils.PasswordPolicyFromEnv().HistorySize; len(history) > keep {
+		history = history[:keep]
+	}
+	err = updateUserFields(ctx, user.ID, bson.M{
+		"password":          "",
+		"passwordHistory":   history,
+		"passwordChangedAt": time.Now(),
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to remove password"})
+	}
+	return c.JSON(fiber.Map{"message": "Password removed, sign in with your passkey from now on"})
+}
+
+// BeginPasskeyLogin godoc
+//
+//	@Summary		Start passkey login
+//	@Description	Get the WebAuthn request options. Without an email any discoverable passkey for this site can be used. Pass options.publicKey to navigator.credentials.get and send the result with sessionId to /auth/passkeys/login/finish.
+//	@Tags			Passkeys
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		object{email=string}	false	"Optional account email"
+//	@Success		200		{object}	map[string]interface{}	"sessionId and request options"
+//	@Failure		500		{object}	map[string]string		"Internal server error"
+//	@Router			/auth/passkeys/login/begin [post]
+func BeginPasskeyLogin(c *fiber.Ctx) error {
+	var input struct {
+		Email string `json:"email"`
+	}
+	c.BodyParser(&input)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rp, err := webAuthn()
+	if err != nil {
+		fmt.Println("WebAuthn config error:", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Passkey belum dikonfigurasi dengan benar"})
+	}
+
+	var (
+		options *protocol.CredentialAssertion
+		session *webauthn.SessionData
+		userID  string
+	)
+
+	// An unknown email or one without passkeys falls back to a discoverable login,
+	// so the response doesn't reveal which accounts exist
+	var user models.User
+	email := strings.TrimSpace(input.Email)
+	if email != "" && config.UserCollectionRef.FindOne(ctx, emailFilter(email)).Decode(&user) == nil && len(user.Passkeys) > 0 {
+		options, session, err = rp.BeginLogin(webAuthnUser{user})
+		userID = user.ID
+	} else {
+		options, session, err = rp.BeginDiscoverableLogin()
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Gagal memulai login passkey"})
+	}
+
+	sessionID, err := saveWebAuthnSession(ctx, models.WebAuthnLogin, userID, session)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Gagal memulai login passkey"})
+	}
+
+	return c.JSON(fiber.Map{"sessionId": sessionID, "options": options})
+}
+
+// FinishPasskeyLogin godoc
+//
+//	@Summary		Finish passkey login
+//	@Description	Verify the passkey assertion and log the user in. A passkey with user verification is already multi-factor, so there is no separate 2FA step.
+//	@Tags			Passkeys
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		object{sessionId=string,credential=object}	true	"Session ID from begin and the PublicKeyCredential"
+//	@Success		200		{object}	map[string]interface{}						"Login successful"
+//	@Failure		400		{object}	map[string]string							"Invalid or expired ceremony"
+//	@Failure		401		{object}	map[string]string							"Passkey not accepted"
+//	@Failure		403		{object}	map[string]string							"Email not verified"
+//	@Failure		500		{object}	map[string]string							"Internal server error"
+//	@Router			/auth/passkeys/login/finish [post]
+func FinishPasskeyLogin(c *fiber.Ctx) error {
+	var input struct {
+		SessionID  string          `json:"sessionId"`
+		Credential json.RawMessage `json:"credential"`
+	}
+	if err := c.BodyParser(&input); err != nil || input.SessionID == "" || len(input.Credential) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Request tidak valid"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rp, err := webAuthn()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Passkey belum dikonfigurasi dengan benar"})
+	}
+
+	sessionUserID, session, err := takeWebAuthnSession(ctx, input.SessionID, models.WebAuthnLogin)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Login passkey kedaluwarsa, silakan coba lagi"})
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(input.Credential)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Respons passkey tidak valid"})
+	}
+
+	var user models.User
+	var credential *webauthn.Credential
+	if sessionUserID != "" {
+		user, err = findUserByID(ctx, sessionUserID)
+		if err == nil {
+			credential, err = rp.ValidateLogin(webAuthnUser{user}, session, parsed)
+		}
+	} else {
+		credential, err = rp.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+			found, err := findUserByID(ctx, string(userHandle))
+			if err != nil {
+				return nil, err
+			}
+			user = found
+			return webAuthnUser{found}, nil
+		}, session, parsed)
+	}
+	if err != nil {
+		fmt.Println("Passkey login rejected:", err)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Passkey tidak dikenali"})
+	}
+
+	if err := recordPasskeyUse(ctx, user.ID, credential); err != nil {
+		fmt.Println("Error updating passkey:", err)
+	}
+	if credential.Authenticator.CloneWarning {
+		fmt.Println("Passkey sign counter went backwards, possible cloned authenticator for user:", user.ID)
+	}
+
+	if user.IsPendingVerification() {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Email belum diverifikasi, silakan cek email Anda",
+			"code":  "email_not_verified",
+		})
+	}
+
+	return respondWithTokens(c, ctx, user, "Login berhasil")
+}
+
+// reauthentication is the proof of identity sensitive passkey changes need on top of the access token
+type reauthentication struct {
+	CurrentPassword string `json:"currentPassword"`
+	Code            string `json:"code"`
+	RecoveryCode    string `json:"recoveryCode"`
+	Assertion       *struct {
+		SessionID  string          `json:"sessionId"`
+		Credential json.RawMessage `json:"credential"`
+	} `json:"assertion"`
+}
+
+var errReauthenticationFailed = errors.New("re-authentication failed")
+
+// reauthenticate checks that the holder of the access token knows the password, has the
+// 2FA device or holds one of the passkeys of the account. Only one proof is needed.
+func reauthenticate(ctx context.Context, rp *webauthn.WebAuthn, user models.User, input reauthentication) error {
+	switch {
+	case input.CurrentPassword != "":
+		if user.Password == "" || !utils.ComparePasswords(user.Password, input.CurrentPassword) {
+			return errReauthenticationFailed
+		}
+		return nil
+
+	case input.Code != "" || input.RecoveryCode != "":
+		if !user.TwoFactorEnabled {
+			return errReauthenticationFailed
+		}
+		err := verifySecondFactor(ctx, user, input.Code, input.RecoveryCode)
+		if errors.Is(err, errInvalidTwoFactor) {
+			return errReauthenticationFailed
+		}
+		return err
+
+	case input.Assertion != nil:
+		// The ceremony must have been started for this very account
+		sessionUserID, session, err := takeWebAuthnSession(ctx, input.Assertion.SessionID, models.WebAuthnLogin)
+		if err != nil || sessionUserID != user.ID {
+			return errReauthenticationFailed
+		}
+		parsed, err := protocol.ParseCredentialRequestResponseBytes(input.Assertion.Credential)
+		if err != nil {
+			return errReauthenticationFailed
+		}
+		credential, err := rp.ValidateLogin(webAuthnUser{user}, session, parsed)
+		if err != nil {
+			return errReauthenticationFailed
+		}
+		return recordPasskeyUse(ctx, user.ID, credential)
+	}
+
+	return errReauthenticationFailed
+}
+
+// passkeyFromCredential turns a verified credential into the stored passkey
+func passkeyFromCredential(credential *webauthn.Credential, name string) models.Passkey {
+	transports := make([]string, 0, len(credential.Transport))
+	for _, t := range credential.Transport {
+		transports = append(transports, string(t))
+	}
+	return models.Passkey{
+		ID:              base64.RawURLEncoding.EncodeToString(credential.ID),
+		Name:            name,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      transports,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		UserVerified:    credential.Flags.UserVerified,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+		CreatedAt:       time.Now(),
+	}
+}
+
+// recordPasskeyUse stores the new sign counter and backup state of a passkey after a login
+func recordPasskeyUse(ctx context.Context, userID string, credential *webauthn.Credential) error {
+	filter := models.UserIDFilter(userID)
+	filter["passkeys.id"] = base64.RawURLEncoding.EncodeToString(credential.ID)
+
+	_, err := config.UserCollectionRef.UpdateOne(ctx, filter, bson.M{"$set": bson.M{
+		"passkeys.$.signCount":    credential.Authenticator.SignCount,
+		"passkeys.$.cloneWarning": credential.Authenticator.CloneWarning,
+		"passkeys.$.backupState":  credential.Flags.BackupState,
+		"passkeys.$.lastUsedAt":   time.Now(),
+	}})
+	return err
+}
+
+// saveWebAuthnSession stores the ceremony state and returns the ID the client sends back
+func saveWebAuthnSession(ctx context.Context, kind, userID string, session *webauthn.SessionData) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+	id, err := utils.GenerateRandomToken(24)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = config.WebAuthnSessionCollectionRef.InsertOne(ctx, models.WebAuthnSession{
+		ID:        id,
+		Kind:      kind,
+		UserID:    userID,
+		Data:      string(data),
+		ExpiresAt: time.Now().Add(webAuthnCeremonyTTL),
+	})
+	return id, err
+}
+
+// takeWebAuthnSession removes and returns the state of a ceremony, along with the user it was started for
+func takeWebAuthnSession(ctx context.Context, id, kind string) (string, webauthn.SessionData, error) {
+	var session webauthn.SessionData
+
+	var stored models.WebAuthnSession
+	err := config.WebAuthnSessionCollectionRef.FindOneAndDelete(ctx, bson.M{"_id": id, "kind": kind}).Decode(&stored)
+	if err != nil {
+		return "", session, err
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return "", session, mongo.ErrNoDocuments
+	}
+	if err := json.Unmarshal([]byte(stored.Data), &session); err != nil {
+		return "", session, errors.New("corrupt webauthn session")
+	}
+	return stored.UserID, session, nil
+}
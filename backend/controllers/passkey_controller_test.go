@@ -0,0 +1,307 @@
+package controllers_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"backend/middleware"
+	"backend/models"
+
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestMain configures the relying party as rpID / rpOrigin
+const (
+	rpID     = "localhost"
+	rpOrigin = "http://localhost:5173"
+)
+
+// virtualAuthenticator is a platform authenticator with one ES256 passkey that always
+// verifies the user, like a phone unlocked with a fingerprint
+type virtualAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newVirtualAuthenticator(t *testing.T) *virtualAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 32)
+	rand.Read(id)
+	return &virtualAuthenticator{key: key, credentialID: id}
+}
+
+// ceremonyOptions is the part of the server's options the authenticator looks at
+type ceremonyOptions struct {
+	SessionID string `json:"sessionId"`
+	Options   struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+			User      struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		} `json:"publicKey"`
+	} `json:"options"`
+}
+
+func decodeOptions(t *testing.T, data []byte) ceremonyOptions {
+	t.Helper()
+	var options ceremonyOptions
+	if err := json.Unmarshal(data, &options); err != nil || options.SessionID == "" || options.Options.PublicKey.Challenge == "" {
+		t.Fatalf("not ceremony options: %s", data)
+	}
+	return options
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func (a *virtualAuthenticator) clientData(t *testing.T, kind, challenge string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]interface{}{
+		"type":        kind,
+		"challenge":   challenge,
+		"origin":      rpOrigin,
+		"crossOrigin": false,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// authenticatorData is rpIdHash | flags | signCount, followed by attested credential data when registering
+func (a *virtualAuthenticator) authenticatorData(t *testing.T, attested bool) []byte {
+	t.Helper()
+	const userPresent, userVerified, attestedData = 0x01, 0x04, 0x40
+
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	flags := byte(userPresent | userVerified)
+	if attested {
+		flags |= attestedData
+	}
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if !attested {
+		return data
+	}
+
+	publicKey, err := webauthncbor.Marshal(map[int]interface{}{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: a.key.X.FillBytes(make([]byte, 32)),
+		-3: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, make([]byte, 16)...) // AAGUID
+	data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+	data = append(data, a.credentialID...)
+	return append(data, publicKey...)
+}
+
+// create answers navigator.credentials.create with a "none" attestation
+func (a *virtualAuthenticator) create(t *testing.T, options ceremonyOptions) map[string]interface{} {
+	t.Helper()
+
+	handle, err := base64.RawURLEncoding.DecodeString(options.Options.PublicKey.User.ID)
+	if err != nil {
+		t.Fatalf("user.id: %v", err)
+	}
+	a.userHandle = handle
+
+	attestation, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": a.authenticatorData(t, true),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]interface{}{
+		"id":    b64(a.credentialID),
+		"rawId": b64(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    b64(a.clientData(t, "webauthn.create", options.Options.PublicKey.Challenge)),
+			"attestationObject": b64(attestation),
+			"transports":        []string{"internal"},
+		},
+		"clientExtensionResults": map[string]interface{}{},
+	}
+}
+
+// get answers navigator.credentials.get with a signed assertion
+func (a *virtualAuthenticator) get(t *testing.T, options ceremonyOptions) map[string]interface{} {
+	t.Helper()
+
+	a.signCount++
+	authData := a.authenticatorData(t, false)
+	clientData := a.clientData(t, "webauthn.get", options.Options.PublicKey.Challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]interface{}{
+		"id":    b64(a.credentialID),
+		"rawId": b64(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    b64(clientData),
+			"authenticatorData": b64(authData),
+			"signature":         b64(signature),
+			"userHandle":        b64(a.userHandle),
+		},
+		"clientExtensionResults": map[string]interface{}{},
+	}
+}
+
+// registerPasskey runs begin and finish with the given proof of identity and returns
+// the status of whichever step failed, or of finish
+func registerPasskey(t *testing.T, app *fiber.App, token string, authenticator *virtualAuthenticator, proof map[string]interface{}) int {
+	t.Helper()
+
+	status, data := call(t, app, http.MethodPost, "/api/passkeys/register/begin", token, proof)
+	if status != fiber.StatusOK {
+		return status
+	}
+	options := decodeOptions(t, data)
+	status, _ = call(t, app, http.MethodPost, "/api/passkeys/register/finish", token, map[string]interface{}{
+		"sessionId":  options.SessionID,
+		"name":       "Laptop",
+		"credential": authenticator.create(t, options),
+	})
+	return status
+}
+
+// passkeyAssertion signs a login ceremony started for email
+func passkeyAssertion(t *testing.T, app *fiber.App, authenticator *virtualAuthenticator, email string) map[string]interface{} {
+	t.Helper()
+
+	status, data := call(t, app, http.MethodPost, "/auth/passkeys/login/begin", "", map[string]string{"email": email})
+	if status != fiber.StatusOK {
+		t.Fatalf("login begin: status %d, %s", status, data)
+	}
+	options := decodeOptions(t, data)
+	return map[string]interface{}{"sessionId": options.SessionID, "credential": authenticator.get(t, options)}
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	app := newTestApp(t)
+	user := seedUser(t, models.User{Nama: "Ana", Email: "ana@example.com"})
+	token := tokenFor(t, user)
+	authenticator := newVirtualAuthenticator(t)
+
+	if status := registerPasskey(t, app, token, authenticator, map[string]interface{}{"currentPassword": testPassword}); status != fiber.StatusCreated {
+		t.Fatalf("register: status %d, want 201", status)
+	}
+	stored := findUser(t, user.ID)
+	if len(stored.Passkeys) != 1 || stored.Passkeys[0].ID != b64(authenticator.credentialID) || !stored.Passkeys[0].UserVerified {
+		t.Fatalf("stored passkeys %+v", stored.Passkeys)
+	}
+
+	// Login with the account email, then discoverable login without one
+	for _, email := range []string{user.Email, ""} {
+		assertion := passkeyAssertion(t, app, authenticator, email)
+		status, body := callJSON(t, app, http.MethodPost, "/auth/passkeys/login/finish", "", assertion)
+		if status != fiber.StatusOK || body["token"] == nil {
+			t.Fatalf("login (email %q): status %d, body %v", email, status, body)
+		}
+		loggedIn, _ := body["user"].(map[string]interface{})
+		if loggedIn["id"] != user.ID {
+			t.Fatalf("logged in as %v, want %s", loggedIn["id"], user.ID)
+		}
+	}
+	if stored := findUser(t, user.ID); stored.Passkeys[0].SignCount != authenticator.signCount {
+		t.Fatalf("sign count %d, want %d", stored.Passkeys[0].SignCount, authenticator.signCount)
+	}
+
+	// An assertion can't be replayed
+	assertion := passkeyAssertion(t, app, authenticator, user.Email)
+	call(t, app, http.MethodPost, "/auth/passkeys/login/finish", "", assertion)
+	if status, _ := call(t, app, http.MethodPost, "/auth/passkeys/login/finish", "", assertion); status == fiber.StatusOK {
+		t.Fatal("replayed assertion accepted")
+	}
+
+	// Another key claiming the same credential ID is refused
+	impostor := newVirtualAuthenticator(t)
+	impostor.credentialID, impostor.userHandle = authenticator.credentialID, authenticator.userHandle
+	if status, _ := call(t, app, http.MethodPost, "/auth/passkeys/login/finish", "", passkeyAssertion(t, app, impostor, user.Email)); status != fiber.StatusUnauthorized {
+		t.Fatalf("impostor: status %d, want 401", status)
+	}
+}
+
+func TestPasskeyRegistrationNeedsReauthentication(t *testing.T) {
+	app := newTestApp(t)
+	user := seedUser(t, models.User{Nama: "Ana", Email: "ana@example.com"})
+	other := seedUser(t, models.User{Nama: "Budi", Email: "budi@example.com"})
+	token := tokenFor(t, user)
+
+	refused := map[string]map[string]interface{}{
+		"no proof":       {},
+		"wrong password": {"currentPassword": "not-the-password"},
+		"2FA off":        {"code": "123456"},
+	}
+	for name, proof := range refused {
+		if status := registerPasskey(t, app, token, newVirtualAuthenticator(t), proof); status != fiber.StatusForbidden {
+			t.Fatalf("%s: status %d, want 403", name, status)
+		}
+	}
+
+	// A passkey assertion of the account works as proof...
+	first := newVirtualAuthenticator(t)
+	if status := registerPasskey(t, app, token, first, map[string]interface{}{"currentPassword": testPassword}); status != fiber.StatusCreated {
+		t.Fatalf("first passkey: status %d", status)
+	}
+	second := newVirtualAuthenticator(t)
+	proof := map[string]interface{}{"assertion": passkeyAssertion(t, app, first, user.Email)}
+	if status := registerPasskey(t, app, token, second, proof); status != fiber.StatusCreated {
+		t.Fatalf("second passkey with an assertion: status %d", status)
+	}
+
+	// ...but not one of somebody else's account
+	otherKey := newVirtualAuthenticator(t)
+	if status := registerPasskey(t, app, tokenFor(t, other), otherKey, map[string]interface{}{"currentPassword": testPassword}); status != fiber.StatusCreated {
+		t.Fatalf("other user's passkey: status %d", status)
+	}
+	proof = map[string]interface{}{"assertion": passkeyAssertion(t, app, otherKey, other.Email)}
+	if status := registerPasskey(t, app, token, newVirtualAuthenticator(t), proof); status != fiber.StatusForbidden {
+		t.Fatalf("assertion of another account: status %d, want 403", status)
+	}
+
+	if stored := findUser(t, user.ID); len(stored.Passkeys) != 2 {
+		t.Fatalf("%d passkeys stored, want 2", len(stored.Passkeys))
+	}
+}
+
+// Passkeys are personal: impersonation tokens can't add one to the account
+func TestPasskeyRegistrationIsDeniedWhileImpersonating(t *testing.T) {
+	app := newTestApp(t)
+	admin := seedUser(t, models.User{Nama: "Admin", Email: "admin@example.com", Role: middleware.RoleAdmin})
+	user := seedUser(t, models.User{Nama: "Ana", Email: "ana@example.com"})
+
+	token := impersonate(t, app, admin, user)
+	if status := registerPasskey(t, app, token, newVirtualAuthenticator(t), map[string]interface{}{"currentPassword": testPassword}); status != fiber.StatusForbidden {
+		t.Fatalf("status %d, want 403", status)
+	}
+}
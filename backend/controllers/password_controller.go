@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend/config"
+	"backend/mailer"
+	"backend/models"
+	"backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// errInvalidOneTimeToken covers unknown, expired and already used one-time tokens alike
+var errInvalidOneTimeToken = errors.New("invalid or expired token")
+
+// ForgotPassword godoc
+//
+//	@Summary		Request a password reset
+//	@Description	Email a single-use password reset link. The response is the same whether or not the email is registered.
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		object{email=string}	true	"Account email"
+//	@Success		200		{object}	map[string]string		"Reset link sent if the account exists"
+//	@Failure		400		{object}	map[string]string		"Invalid request"
+//	@Failure		429		{object}	map[string]string		"Too many requests"
+//	@Router			/auth/forgot-password [post]
+func ForgotPassword(c *fiber.Ctx) error {
+	var input struct {
+		Email string `json:"email"`
+	}
+	if err := c.BodyParser(&input); err != nil || strings.TrimSpace(input.Email) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Request tidak valid"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Same answer either way, so this endpoint can't be used to find registered emails
+	response := fiber.Map{"message": "Jika email terdaftar, link reset password telah dikirim"}
+
+	var user models.User
+	err := config.UserCollectionRef.FindOne(ctx, emailFilter(strings.TrimSpace(input.Email))).Decode(&user)
+	if err != nil {
+		fmt.Println("Password reset requested for unknown email")
+		return c.JSON(response)
+	}
+
+	// Only the newest link should work
+	if err := invalidateOneTimeTokens(ctx, user.ID, models.TokenPurposePasswordReset); err != nil {
+		fmt.Println("Error invalidating old reset tokens:", err)
+	}
+
+	ttl := utils.DurationFromEnv("PASSWORD_RESET_TTL", time.Hour)
+	token, err := createOneTimeToken(ctx, user.ID, models.TokenPurposePasswordReset, ttl)
+	if err != nil {
+		fmt.Println("Error creating reset token:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal membuat token reset"})
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", config.AppURL(), token)
+	err = mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset password CoEmotion",
+		Body: fmt.Sprintf("Halo %s,\n\nGunakan link berikut untuk mengatur ulang password Anda:\n%s\n\nLink ini berlaku selama %s dan hanya bisa dipakai sekali. Abaikan email ini jika Anda tidak meminta reset password.\n",
+			user.Nama, link, ttl),
+	})
+	if err != nil {
+		fmt.Println("Error sending reset email:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal mengirim email"})
+	}
+
+	return c.JSON(response)
+}
+
+// ResetPassword godoc
+//
+//	@Summary		Reset password
+//	@Description	Set a new password with a token from the reset email. The token can only be used once and every refresh token of the account is revoked.
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		object{token=string,newPassword=string}	true	"Reset token and new password"
+//	@Success		200		{object}	map[string]string						"Password reset"
+//	@Failure		400		{object}	map[string]string						"Invalid or expired token"
+//	@Failure		422		{object}	map[string]interface{}					"Password violates the password policy"
+//	@Failure		500		{object}	map[string]string						"Internal server error"
+//	@Router			/auth/reset-password [post]
+func ResetPassword(c *fiber.Ctx) error {
+	var input struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"newPassword"`
+	}
+	if err := c.BodyParser(&input); err != nil || input.Token == "" || input.NewPassword == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Request tidak valid"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Look the token up without using it, so a password the policy refuses doesn't burn the link
+	resetToken, err := findOneTimeToken(ctx, input.Token, models.TokenPurposePasswordReset)
+	if err != nil {
+		if errors.Is(err, errInvalidOneTimeToken) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Token reset tidak valid atau sudah kedaluwarsa"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memeriksa token reset"})
+	}
+
+	user, err := findUserByID(ctx, resetToken.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Token reset tidak valid atau sudah kedaluwarsa"})
+	}
+
+	if handled, err := validatePassword(c, input.NewPassword, user); handled {
+		return err
+	}
+
+	if _, err := consumeOneTimeToken(ctx, input.Token, models.TokenPurposePasswordReset); err != nil {
+		if errors.Is(err, errInvalidOneTimeToken) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Token reset tidak valid atau sudah kedaluwarsa"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memeriksa token reset"})
+	}
+
+	passwordFields, err := applyNewPassword(&user, input.NewPassword)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal hash password"})
+	}
+
+	if err := updateUserFields(ctx, user.ID, passwordFields); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal menyimpan password"})
+	}
+
+	// Whoever knew the old password shouldn't stay logged in
+	if err := revokeUserSessions(ctx, user.ID); err != nil {
+		fmt.Println("Error revoking sessions:", err)
+	}
+
+	return c.JSON(fiber.Map{"message": "Password berhasil direset"})
+}
+
+// createOneTimeToken stores the hash of a new one-time token and returns the token itself
+func createOneTimeToken(ctx context.Context, userID, purpose string, ttl time.Duration) (string, error) {
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	_, err = config.OneTimeTokenCollectionRef.InsertOne(ctx, models.OneTimeToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// findOneTimeToken returns a valid token without using it up
+func findOneTimeToken(ctx context.Context, token, purpose string) (models.OneTimeToken, error) {
+	var stored models.OneTimeToken
+	err := config.OneTimeTokenCollectionRef.FindOne(ctx, bson.M{
+		"tokenHash": utils.HashToken(token),
+		"purpose":   purpose,
+		"usedAt":    bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}).Decode(&stored)
+	if err == mongo.ErrNoDocuments {
+		return stored, errInvalidOneTimeToken
+	}
+	return stored, err
+}
+
+// consumeOneTimeToken marks a valid token as used and returns it.
+// The update only matches unused, unexpired tokens, so a token can't be redeemed twice.
+func consumeOneTimeToken(ctx context.Context, token, purpose string) (models.OneTimeToken, error) {
+	var stored models.OneTimeToken
+	now := time.Now()
+
+	err := config.OneTimeTokenCollectionRef.FindOneAndUpdate(ctx,
+		bson.M{
+			"tokenHash": utils.HashToken(token),
+			"purpose":   purpose,
+			"usedAt":    bson.M{"$exists": false},
+			"expiresAt": bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{"usedAt": now}},
+	).Decode(&stored)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return stored, errInvalidOneTimeToken
+		}
+		return stored, err
+	}
+	return stored, nil
+}
+
+// invalidateOneTimeTokens marks every outstanding token of the given purpose as used
+func invalidateOneTimeTokens(ctx context.Context, userID, purpose string) error {
+	_, err := config.OneTimeTokenCollectionRef.UpdateMany(ctx,
+		bson.M{"userId": userID, "purpose": purpose, "usedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"usedAt": time.Now()}},
+	)
+	return err
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"backend/models"
+	"backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// GetPasswordPolicy godoc
+//
+//	@Summary		Password policy
+//	@Description	The rules new passwords must follow, so clients can show them before submitting
+//	@Tags			Auth
+//	@Produce		json
+//	@Success		200	{object}	utils.PasswordPolicy	"Current policy"
+//	@Router			/auth/password-policy [get]
+func GetPasswordPolicy(c *fiber.Ctx) error {
+	return c.JSON(utils.PasswordPolicyFromEnv())
+}
+
+// checkNewPassword returns every policy rule the password breaks for this user,
+// including reuse of recent passwords and presence in the breached password list
+func checkNewPassword(password string, user models.User) ([]utils.PasswordViolation, error) {
+	policy := utils.PasswordPolicyFromEnv()
+	violations := policy.Check(password, user.Nama, user.Email)
+
+	if policy.HistorySize > 0 && user.Password != "" {
+		recent := append([]string{user.Password}, user.PasswordHistory...)
+		if len(recent) > policy.HistorySize {
+			recent = recent[:policy.HistorySize]
+		}
+		for _, hash := range recent {
+			if utils.CheckPasswordHash(password, hash) {
+				violations = append(violations, utils.PasswordViolation{
+					Code:    utils.PasswordReused,
+					Message: fmt.Sprintf("Password tidak boleh sama dengan %d password terakhir", policy.HistorySize),
+				})
+				break
+			}
+		}
+	}
+
+	breached, err := utils.IsBreachedPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	if breached {
+		violations = append(violations, utils.PasswordViolation{
+			Code:    utils.PasswordBreached,
+			Message: "Password ini pernah bocor dalam kebocoran data, gunakan password lain",
+		})
+	}
+
+	return violations, nil
+}
+
+// rejectPassword answers with the structured list of policy violations
+func rejectPassword(c *fiber.Ctx, violations []utils.PasswordViolation) error {
+	return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+		"error":      "Password tidak memenuhi kebijakan password",
+		"violations": violations,
+	})
+}
+
+// validatePassword checks a new password and writes the error response when it's refused.
+// It reports whether the request was answered.
+func validatePassword(c *fiber.Ctx, password string, user models.User) (bool, error) {
+	violations, err := checkNewPassword(password, user)
+	if err != nil {
+		fmt.Println("Error checking password:", err)
+		return true, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memeriksa password"})
+	}
+	if len(violations) > 0 {
+		return true, rejectPassword(c, violations)
+	}
+	return false, nil
+}
+
+// applyNewPassword hashes the password into the user, pushing the old hash into the
+// history, and returns the fields to $set when the user already exists
+func applyNewPassword(user *models.User, password string) (bson.M, error) {
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := utils.PasswordPolicyFromEnv().HistorySize - 1
+	history := user.PasswordHistory
+	if user.Password != "" {
+		history = append([]string{user.Password}, history...)
+	}
+	if keep < 0 {
+		keep = 0
+	}
+	if len(history) > keep {
+		history = history[:keep]
+	}
+
+	now := time.Now()
+	user.Password = hashedPassword
+	user.PasswordHistory = history
+	user.PasswordChangedAt = &now
+	user.MustChangePassword = false
+
+	return bson.M{
+		"password":           hashedPassword,
+		"passwordHistory":    history,
+		"passwordChangedAt":  now,
+		"mustChangePassword": false,
+	}, nil
+}
+
+// upgradePasswordHash rehashes a just-verified password when its stored hash uses an
+// older algorithm or weaker parameters. Failures only cost the upgrade, never the login.
+func upgradePasswordHash(ctx context.Context, user models.User, password string) {
+	if !utils.NeedsRehash(user.Password) {
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		fmt.Println("Error rehashing password:", err)
+		return
+	}
+	if err := updateUserFields(ctx, user.ID, bson.M{"password": hashedPassword}); err != nil {
+		fmt.Println("Error saving rehashed password:", err)
+		return
+	}
+	fmt.Println("Upgraded password hash for user:", user.ID)
+}
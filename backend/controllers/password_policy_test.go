@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"backend/models"
+	"backend/utils"
+)
+
+// hashes returns bcrypt hashes of the given passwords, newest first like a user's history
+func hashes(t *testing.T, passwords ...string) []string {
+	t.Helper()
+	t.Setenv("PASSWORD_HASH_ALGORITHM", utils.HashAlgorithmBcrypt)
+	t.Setenv("BCRYPT_COST", "4")
+	hashed := make([]string, len(passwords))
+	for i, password := range passwords {
+		hash, err := utils.HashPassword(password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashed[i] = hash
+	}
+	return hashed
+}
+
+func TestPasswordHistoryRejectsReuse(t *testing.T) {
+	old := hashes(t, "Current-Pass-1", "Previous-Pass-2", "Oldest-Pass-3")
+	user := models.User{Nama: "Ana", Email: "ana@example.com", Password: old[0], PasswordHistory: old[1:]}
+	tests := []struct {
+		name     string
+		history  int
+		password string
+		reused   bool
+	}{
+		{"current password", 3, "Current-Pass-1", true},
+		{"previous password", 3, "Previous-Pass-2", true},
+		{"oldest remembered password", 3, "Oldest-Pass-3", true},
+		{"new password", 3, "Brand-New-Pass-4", false},
+		{"beyond the history size", 2, "Oldest-Pass-3", false},
+		{"history disabled", 0, "Current-Pass-1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PASSWORD_HISTORY", strconv.Itoa(tt.history))
+			violations, err := checkNewPassword(tt.password, user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reused := false
+			for _, v := range violations {
+				reused = reused || v.Code == utils.PasswordReused
+			}
+			if reused != tt.reused {
+				t.Fatalf("reused = %v, want %v (violations %v)", reused, tt.reused, violations)
+			}
+		})
+	}
+}
+
+func TestApplyNewPasswordKeepsHistory(t *testing.T) {
+	old := hashes(t, "Current-Pass-1", "Previous-Pass-2", "Oldest-Pass-3")
+	tests := []struct {
+		name     string
+		history  int
+		password string
+		previous []string
+		want     []string
+	}{
+		{"pushes the old hash", 5, old[0], old[1:], old},
+		{"trims to the history size", 3, old[0], old[1:], old[:2]},
+		{"first password", 5, "", nil, nil},
+		{"history of one keeps nothing", 1, old[0], old[1:], []string{}},
+		{"history disabled", 0, old[0], old[1:], []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PASSWORD_HISTORY", strconv.Itoa(tt.history))
+			user := models.User{Password: tt.password, PasswordHistory: tt.previous, MustChangePassword: true}
+			fields, err := applyNewPassword(&user, "Brand-New-Pass-4")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(user.PasswordHistory, tt.want) {
+				t.Fatalf("history has %d hashes, want %d", len(user.PasswordHistory), len(tt.want))
+			}
+			if !utils.CheckPasswordHash("Brand-New-Pass-4", user.Password) || fields["password"] != user.Password {
+				t.Fatal("new password not stored")
+			}
+			if user.MustChangePassword || user.PasswordChangedAt == nil {
+				t.Fatalf("password change not recorded: %+v", user)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"backend/config"
+	"backend/middleware"
+	"backend/models"
+	"backend/presence"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// maxCustomStatusMessage is the longest message a custom status may carry
+const maxCustomStatusMessage = 100
+
+// SetCustomStatusInput is the body of PUT /api/presence/status
+type SetCustomStatusInput struct {
+	Status           string     `json:"status"`
+	Message          string     `json:"message"`
+	ExpiresAt        *time.Time `json:"expiresAt"`
+	ExpiresInMinutes int        `json:"expiresInMinutes"`
+}
+
+// Heartbeat godoc
+//
+//	@Summary		Presence heartbeat
+//	@Description	Mark the current user as active. Clients call this periodically while open, so users stay online without making other requests. Impersonated requests don't count as activity.
+//	@Tags			Presence
+//	@Produce		json
+//	@Security		Bearer
+//	@Success		200	{object}	models.Presence		"Current presence"
+//	@Failure		401	{object}	map[string]string	"Unauthorized"
+//	@Failure		404	{object}	map[string]string	"User not found"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Router			/api/presence/heartbeat [post]
+func Heartbeat(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID := middleware.CurrentUserID(c)
+	if !middleware.IsImpersonating(c) {
+		if _, err := presence.Heartbeat(ctx, userID); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to update presence"})
+		}
+	}
+
+	user, err := findUserByID(ctx, userID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+	return c.JSON(userPresence(user, time.Now()))
+}
+
+// SetCustomStatus godoc
+//
+//	@Summary		Set custom status
+//	@Description	Set a status like "Do not disturb" on the current user, optionally expiring at expiresAt or after expiresInMinutes. Statuses: dnd, busy, in_meeting, out_of_office.
+//	@Tags			Presence
+//	@Accept			json
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			status	body		SetCustomStatusInput	true	"Status, message and expiry"
+//	@Success		200		{object}	models.Presence			"Current presence"
+//	@Failure		400		{object}	map[string]string		"Invalid input"
+//	@Failure		500		{object}	map[string]string		"Internal server error"
+//	@Router			/api/presence/status [put]
+func SetCustomStatus(c *fiber.Ctx) error {
+	var input SetCustomStatusInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	if !models.IsCustomStatus(input.Status) {
+		return c.Status(400).JSON(fiber.Map{"error": "status must be one of dnd, busy, in_meeting, out_of_office"})
+	}
+	input.Message = strings.TrimSpace(input.Message)
+	if len(input.Message) > maxCustomStatusMessage {
+		return c.Status(400).JSON(fiber.Map{"error": "message must be at most 100 characters"})
+	}
+
+	now := time.Now()
+	status := models.CustomStatus{Status: input.Status, Message: input.Message}
+	switch {
+	case input.ExpiresAt != nil && input.ExpiresInMinutes != 0:
+		return c.Status(400).JSON(fiber.Map{"error": "Use either expiresAt or expiresInMinutes"})
+	case input.ExpiresAt != nil:
+		if !input.ExpiresAt.After(now) {
+			return c.Status(400).JSON(fiber.Map{"error": "expiresAt must be in the future"})
+		}
+		status.ExpiresAt = input.ExpiresAt
+	case input.ExpiresInMinutes < 0:
+		return c.Status(400).JSON(fiber.Map{"error": "expiresInMinutes must be positive"})
+	case input.ExpiresInMinutes > 0:
+		expiresAt := now.Add(time.Duration(input.ExpiresInMinutes) * time.Minute)
+		status.ExpiresAt = &expiresAt
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID := middleware.CurrentUserID(c)
+	if err := updateUserFields(ctx, userID, bson.M{"customStatus": status}); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to set status"})
+	}
+
+	user, err := findUserByID(ctx, userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load user"})
+	}
+	return c.JSON(userPresence(user, now))
+}
+
+// ClearCustomStatus godoc
+//
+//	@Summary		Clear custom status
+//	@Description	Remove the current user's custom status
+//	@Tags			Presence
+//	@Produce		json
+//	@Security		Bearer
+//	@Success		200	{object}	models.Presence		"Current presence"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Router			/api/presence/status [delete]
+func ClearCustomStatus(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID := middleware.CurrentUserID(c)
+	if _, err := config.UserCollectionRef.UpdateOne(ctx, models.UserIDFilter(userID), bson.M{"$unset": bson.M{"customStatus": ""}}); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to clear status"})
+	}
+
+	user, err := findUserByID(ctx, userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load user"})
+	}
+	return c.JSON(userPresence(user, time.Now()))
+}
+
+// userPresence derives a user's presence; expired custom statuses are left out
+func userPresence(user models.User, now time.Time) models.Presence {
+	return models.Presence{
+		UserID:       user.ID,
+		Status:       presence.State(user.LastActive, now),
+		LastActive:   user.LastActive,
+		CustomStatus: user.ActiveCustomStatus(now),
+	}
+}
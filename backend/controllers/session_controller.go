@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"backend/config"
+	"backend/middleware"
+	"backend/models"
+	"backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetSessions godoc
+//
+//	@Summary		List active sessions
+//	@Description	List the active login sessions of the current user, newest activity first
+//	@Tags			Sessions
+//	@Produce		json
+//	@Security		Bearer
+//	@Success		200	{array}		models.SessionResponse	"Active sessions"
+//	@Failure		500	{object}	map[string]string		"Internal server error"
+//	@Router			/api/sessions [get]
+func GetSessions(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := config.SessionCollectionRef.Find(ctx,
+		bson.M{
+			"userId":    middleware.CurrentUserID(c),
+			"revokedAt": bson.M{"$exists": false},
+			"expiresAt": bson.M{"$gt": time.Now()},
+		},
+		options.Find().SetSort(bson.D{{Key: "lastSeenAt", Value: -1}}),
+	)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch sessions"})
+	}
+	defer cursor.Close(ctx)
+
+	var sessions []models.Session
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to decode sessions"})
+	}
+
+	currentID := currentSessionID(c)
+	responses := make([]models.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		responses = append(responses, models.SessionResponse{
+			Session: session,
+			Current: session.ID.Hex() == currentID,
+		})
+	}
+
+	return c.JSON(responses)
+}
+
+// RevokeSession godoc
+//
+//	@Summary		Revoke a session
+//	@Description	Log one of the current user's sessions out. Its tokens stop working immediately.
+//	@Tags			Sessions
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			id	path		string				true	"Session ID"
+//	@Success		200	{object}	map[string]string	"Session revoked"
+//	@Failure		404	{object}	map[string]string	"Session not found"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Router			/api/sessions/{id} [delete]
+func RevokeSession(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := revokeSession(ctx, middleware.CurrentUserID(c), c.Params("id"))
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{"error": "Session not found"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to revoke session"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Session revoked"})
+}
+
+// RevokeAllSessions godoc
+//
+//	@Summary		Revoke all sessions
+//	@Description	Log the current user out everywhere. With exceptCurrent=true the session making the request stays active.
+//	@Tags			Sessions
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			exceptCurrent	query		bool				false	"Keep the current session"
+//	@Success		200				{object}	map[string]string	"Sessions revoked"
+//	@Failure		500				{object}	map[string]string	"Internal server error"
+//	@Router			/api/sessions [delete]
+func RevokeAllSessions(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID := middleware.CurrentUserID(c)
+	if !c.QueryBool("exceptCurrent") {
+		if err := revokeUserSessions(ctx, userID); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to revoke sessions"})
+		}
+		return c.JSON(fiber.Map{"message": "All sessions revoked"})
+	}
+
+	cursor, err := config.SessionCollectionRef.Find(ctx, bson.M{"userId": userID, "revokedAt": bson.M{"$exists": false}})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch sessions"})
+	}
+	var sessions []models.Session
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to decode sessions"})
+	}
+
+	currentID := currentSessionID(c)
+	for _, session := range sessions {
+		if session.ID.Hex() == currentID {
+			continue
+		}
+		if err := revokeSession(ctx, userID, session.ID.Hex()); err != nil && err != mongo.ErrNoDocuments {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to revoke sessions"})
+		}
+	}
+
+	return c.JSON(fiber.Map{"message": "Other sessions revoked"})
+}
+
+// currentSessionID returns the "sid" claim of the request's access token
+func currentSessionID(c *fiber.Ctx) string {
+	claims, ok := c.Locals("user").(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	sid, _ := claims["sid"].(string)
+	return sid
+}
+
+// startSession records a new login of the user from the requesting device
+func startSession(ctx context.Context, c *fiber.Ctx, user models.User) (models.Session, error) {
+	now := time.Now()
+	session := models.Session{
+		ID:         primitive.NewObjectID(),
+		UserID:     user.ID,
+		UserAgent:  c.Get(fiber.HeaderUserAgent),
+		IP:         c.IP(),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(utils.RefreshTokenTTL()),
+	}
+
+	_, err := config.SessionCollectionRef.InsertOne(ctx, session)
+	return session, err
+}
+
+// touchSessionOnRefresh keeps an active session alive for another refresh token lifetime.
+// It returns mongo.ErrNoDocuments when the session was revoked or has expired.
+func touchSessionOnRefresh(ctx context.Context, c *fiber.Ctx, sessionID string) error {
+	objectID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return mongo.ErrNoDocuments
+	}
+
+	now := time.Now()
+	result, err := config.SessionCollectionRef.UpdateOne(ctx,
+		bson.M{"_id": objectID, "revokedAt": bson.M{"$exists": false}, "expiresAt": bson.M{"$gt": now}},
+		bson.M{"$set": bson.M{
+			"lastSeenAt": now,
+			"ip":         c.IP(),
+			"expiresAt":  now.Add(utils.RefreshTokenTTL()),
+		}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// revokeSession ends one session of the user together with its refresh tokens
+func revokeSession(ctx context.Context, userID, sessionID string) error {
+	objectID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return mongo.ErrNoDocuments
+	}
+
+	now := time.Now()
+	result, err := config.SessionCollectionRef.UpdateOne(ctx,
+		bson.M{"_id": objectID, "userId": userID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": now}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	_, err = config.RefreshTokenCollectionRef.UpdateMany(ctx,
+		bson.M{"sessionId": sessionID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": now, "revokedReason": models.RefreshTokenRevoked}},
+	)
+	return err
+}
+
+// revokeUserSessions logs the user out everywhere
+func revokeUserSessions(ctx context.Context, userID string) error {
+	_, err := config.SessionCollectionRef.UpdateMany(ctx,
+		bson.M{"userId": userID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	return revokeUserRefreshTokens(ctx, userID)
+}
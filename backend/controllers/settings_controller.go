@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"backend/config"
+	"backend/middleware"
+	"backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetSecuritySettings godoc
+//
+//	@Summary		Get security settings
+//	@Description	Get the runtime security settings, such as which roles must use two-factor authentication
+//	@Tags			Admin
+//	@Produce		json
+//	@Security		Bearer
+//	@Success		200	{object}	models.SecuritySettings	"Security settings"
+//	@Failure		403	{object}	map[string]string		"Forbidden"
+//	@Failure		500	{object}	map[string]string		"Internal server error"
+//	@Router			/api/admin/security-settings [get]
+func GetSecuritySettings(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	settings, err := loadSecuritySettings(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load security settings"})
+	}
+	return c.JSON(settings)
+}
+
+// UpdateSecuritySettings godoc
+//
+//	@Summary		Update security settings
+//	@Description	Change the runtime security settings. Listing a role in requireTwoFactorRoles forces its users to enroll in 2FA at their next login.
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			settings	body		object{requireTwoFactorRoles=[]string}	true	"Security settings"
+//	@Success		200			{object}	models.SecuritySettings					"Updated settings"
+//	@Failure		400			{object}	map[string]string						"Invalid request"
+//	@Failure		403			{object}	map[string]string						"Forbidden"
+//	@Failure		500			{object}	map[string]string						"Internal server error"
+//	@Router			/api/admin/security-settings [put]
+func UpdateSecuritySettings(c *fiber.Ctx) error {
+	var input struct {
+		RequireTwoFactorRoles []string `json:"requireTwoFactorRoles"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if input.RequireTwoFactorRoles == nil {
+		input.RequireTwoFactorRoles = []string{}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	settings := models.SecuritySettings{
+		ID:                    models.SecuritySettingsID,
+		RequireTwoFactorRoles: input.RequireTwoFactorRoles,
+		UpdatedAt:             time.Now(),
+		UpdatedBy:             middleware.CurrentUserID(c),
+	}
+
+	_, err := config.SettingsCollectionRef.ReplaceOne(ctx,
+		bson.M{"_id": models.SecuritySettingsID},
+		settings,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save security settings"})
+	}
+
+	return c.JSON(settings)
+}
+
+// loadSecuritySettings returns the stored settings, or empty defaults if none were saved yet
+func loadSecuritySettings(ctx context.Context) (models.SecuritySettings, error) {
+	settings := models.SecuritySettings{ID: models.SecuritySettingsID, RequireTwoFactorRoles: []string{}}
+
+	err := config.SettingsCollectionRef.FindOne(ctx, bson.M{"_id": models.SecuritySettingsID}).Decode(&settings)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return settings, err
+	}
+	return settings, nil
+}
+
+// twoFactorRequiredFor reports whether users with the role must have 2FA enabled
+func twoFactorRequiredFor(ctx context.Context, role string) (bool, error) {
+	if role == "" {
+		role = middleware.DefaultRole
+	}
+
+	settings, err := loadSecuritySettings(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, r := range settings.RequireTwoFactorRoles {
+		if r == role {
+			return true, nil
+		}
+	}
+	return false, nil
+}
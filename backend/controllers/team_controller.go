@@ -0,0 +1,506 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"backend/config"
+	"backend/middleware"
+	"backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// errUnknownTeamUser is returned when a team would reference a user that doesn't exist
+var errUnknownTeamUser = errors.New("unknown user")
+
+// TeamInput is the body of POST and PUT /api/teams
+type TeamInput struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Members     []string `json:"members"`
+	Leads       []string `json:"leads"`
+}
+
+// TeamMemberInput is the body of POST /api/teams/{id}/members
+type TeamMemberInput struct {
+	UserID string `json:"userId"`
+	Lead   bool   `json:"lead"`
+}
+
+// CreateTeam godoc
+//
+//	@Summary		Create a team
+//	@Description	Create a team. The creator becomes a member and a lead; leads must also be members.
+//	@Tags			Teams
+//	@Accept			json
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			team	body		TeamInput			true	"Team name, description, members and leads"
+//	@Success		201		{object}	models.Team			"Team created"
+//	@Failure		400		{object}	map[string]string	"Invalid input"
+//	@Failure		500		{object}	map[string]string	"Internal server error"
+//	@Router			/api/teams [post]
+func CreateTeam(c *fiber.Ctx) error {
+	var input TeamInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" || len(input.Name) > 100 {
+		return c.Status(400).JSON(fiber.Map{"error": "Name is required and must be at most 100 characters"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	creatorID := middleware.CurrentUserID(c)
+	members := uniqueStrings(append([]string{creatorID}, input.Members...))
+	leads := uniqueStrings(append([]string{creatorID}, input.Leads...))
+	for _, lead := range leads {
+		if !slices.Contains(members, lead) {
+			return c.Status(400).JSON(fiber.Map{"error": "Leads must also be members"})
+		}
+	}
+	if err := checkTeamUsers(ctx, c, members); err != nil {
+		if errors.Is(err, errUnknownTeamUser) {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check members"})
+	}
+
+	now := time.Now()
+	team := models.Team{
+		ID:          primitive.NewObjectID(),
+		Name:        input.Name,
+		Description: strings.TrimSpace(input.Description),
+		Members:     members,
+		Leads:       leads,
+		WorkspaceID: middleware.CurrentWorkspaceID(c),
+		CreatedBy:   creatorID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if _, err := config.TeamCollectionRef.InsertOne(ctx, team); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create team"})
+	}
+
+	return c.Status(201).JSON(team)
+}
+
+// GetTeams godoc
+//
+//	@Summary		List teams
+//	@Description	List the teams the caller belongs to. With ?all=true, callers allowed to manage every team get all teams of their workspace.
+//	@Tags			Teams
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			all	query		bool				false	"List every team"
+//	@Success		200	{array}		models.Team			"Teams"
+//	@Failure		403	{object}	map[string]string	"Not allowed to list every team"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Router			/api/teams [get]
+func GetTeams(c *fiber.Ctx) error {
+	filter := bson.M{"members": middleware.CurrentUserID(c)}
+	if c.QueryBool("all") {
+		if !middleware.Can(c, middleware.PermTeamsManageAny) {
+			return c.Status(403).JSON(fiber.Map{"error": "You can only list your own teams"})
+		}
+		filter = bson.M{}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := config.TeamCollectionRef.Find(ctx, inWorkspace(c, filter), options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch teams"})
+	}
+	defer cursor.Close(ctx)
+
+	teams := []models.Team{}
+	if err := cursor.All(ctx, &teams); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to decode teams"})
+	}
+	return c.JSON(teams)
+}
+
+// GetTeam godoc
+//
+//	@Summary		Get a team
+//	@Description	Get a team the caller belongs to
+//	@Tags			Teams
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			id	path		string				true	"Team ID"
+//	@Success		200	{object}	models.Team			"Team"
+//	@Failure		404	{object}	map[string]string	"Team not found"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Router			/api/teams/{id} [get]
+func GetTeam(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	team, err := findTeamByID(ctx, c, c.Params("id"))
+	if err != nil {
+		return teamLookupError(c, err)
+	}
+	// Outsiders get a 404 so team IDs can't be probed
+	if !canReadTeam(c, team) {
+		return c.Status(404).JSON(fiber.Map{"error": "Team not found"})
+	}
+	return c.JSON(team)
+}
+
+// UpdateTeam godoc
+//
+//	@Summary		Update a team
+//	@Description	Rename a team or change its description. Only leads of the team can do this. Members are managed with the members endpoints.
+//	@Tags			Teams
+//	@Accept			json
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			id		path		string				true	"Team ID"
+//	@Param			team	body		TeamInput			true	"Team name and description"
+//	@Success		200		{object}	models.Team			"Team updated"
+//	@Failure		400		{object}	map[string]string	"Invalid input"
+//	@Failure		403		{object}	map[string]string	"Not a lead of the team"
+//	@Failure		404		{object}	map[string]string	"Team not found"
+//	@Failure		500		{object}	map[string]string	"Internal server error"
+//	@Router			/api/teams/{id} [put]
+func UpdateTeam(c *fiber.Ctx) error {
+	var input TeamInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" || len(input.Name) > 100 {
+		return c.Status(400).JSON(fiber.Map{"error": "Name is required and must be at most 100 characters"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	team, err := findTeamByID(ctx, c, c.Params("id"))
+	if err != nil {
+		return teamLookupError(c, err)
+	}
+	if handled, err := requireTeamLead(c, team); handled {
+		return err
+	}
+
+	team.Name = input.Name
+	team.Description = strings.TrimSpace(input.Description)
+	team.UpdatedAt = time.Now()
+	_, err = config.TeamCollectionRef.UpdateOne(ctx, bson.M{"_id": team.ID}, bson.M{"$set": bson.M{
+		"name":        team.Name,
+		"description": team.Description,
+		"updatedAt":   team.UpdatedAt,
+	}})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update team"})
+	}
+	return c.JSON(team)
+}
+
+// DeleteTeam godoc
+//
+//	@Summary		Delete a team
+//	@Description	Delete a team. Its meetings keep their current members as a fixed participant list.
+//	@Tags			Teams
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			id	path		string				true	"Team ID"
+//	@Success		200	{object}	map[string]string	"Team deleted"
+//	@Failure		403	{object}	map[string]string	"Not a lead of the team"
+//	@Failure		404	{object}	map[string]string	"Team not found"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Router			/api/teams/{id} [delete]
+func DeleteTeam(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	team, err := findTeamByID(ctx, c, c.Params("id"))
+	if err != nil {
+		return teamLookupError(c, err)
+	}
+	if handled, err := requireTeamLead(c, team); handled {
+		return err
+	}
+
+	// Team meetings would otherwise lose everyone they invite
+	participants := make([]primitive.ObjectID, 0, len(team.Members))
+	for _, member := range team.Members {
+		if objectID, err := primitive.ObjectIDFromHex(member); err == nil {
+			participants = append(participants, objectID)
+		}
+	}
+	_, err = config.MeetingCollectionRef.UpdateMany(ctx,
+		bson.M{"teamId": team.ID},
+		bson.M{"$set": bson.M{"participants": participants}, "$unset": bson.M{"teamId": ""}},
+	)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to detach team meetings"})
+	}
+
+	if _, err := config.TeamCollectionRef.DeleteOne(ctx, bson.M{"_id": team.ID}); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete team"})
+	}
+	return c.JSON(fiber.Map{"message": "Team deleted"})
+}
+
+// AddTeamMember godoc
+//
+//	@Summary		Add or update a team member
+//	@Description	Add a user to a team, or change whether an existing member is a lead. Only leads of the team can do this.
+//	@Tags			Teams
+//	@Accept			json
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			id		path		string				true	"Team ID"
+//	@Param			member	body		TeamMemberInput		true	"User ID and lead flag"
+//	@Success		200		{object}	models.Team			"Team with the new member"
+//	@Failure		400		{object}	map[string]string	"Invalid input"
+//	@Failure		403		{object}	map[string]string	"Not a lead of the team"
+//	@Failure		404		{object}	map[string]string	"Team not found"
+//	@Failure		500		{object}	map[string]string	"Internal server error"
+//	@Router			/api/teams/{id}/members [post]
+func AddTeamMember(c *fiber.Ctx) error {
+	var input TeamMemberInput
+	if err := c.BodyParser(&input); err != nil || input.UserID == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "userId is required"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	team, err := findTeamByID(ctx, c, c.Params("id"))
+	if err != nil {
+		return teamLookupError(c, err)
+	}
+	if handled, err := requireTeamLead(c, team); handled {
+		return err
+	}
+	if err := checkTeamUsers(ctx, c, []string{input.UserID}); err != nil {
+		if errors.Is(err, errUnknownTeamUser) {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check member"})
+	}
+
+	leads := removeString(team.Leads, input.UserID)
+	if input.Lead {
+		leads = append(leads, input.UserID)
+	}
+	if len(leads) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "A team needs at least one lead"})
+	}
+
+	team.Members = uniqueStrings(append(team.Members, input.UserID))
+	team.Leads = leads
+	if err := saveTeamMembership(ctx, &team); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update team"})
+	}
+	return c.JSON(team)
+}
+
+// RemoveTeamMember godoc
+//
+//	@Summary		Remove a team member
+//	@Description	Remove a user from a team. Leads can remove anyone, members can remove themselves. The last lead can't leave while others remain.
+//	@Tags			Teams
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			id		path		string				true	"Team ID"
+//	@Param			userId	path		string				true	"User ID"
+//	@Success		200		{object}	models.Team			"Team without the member"
+//	@Failure		400		{object}	map[string]string	"Would leave the team without a lead"
+//	@Failure		403		{object}	map[string]string	"Not a lead of the team"
+//	@Failure		404		{object}	map[string]string	"Team or member not found"
+//	@Failure		500		{object}	map[string]string	"Internal server error"
+//	@Router			/api/teams/{id}/members/{userId} [delete]
+func RemoveTeamMember(c *fiber.Ctx) error {
+	userID := c.Params("userId")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	team, err := findTeamByID(ctx, c, c.Params("id"))
+	if err != nil {
+		return teamLookupError(c, err)
+	}
+	if userID != middleware.CurrentUserID(c) || !team.HasMember(userID) {
+		if handled, err := requireTeamLead(c, team); handled {
+			return err
+		}
+	}
+	if !team.HasMember(userID) {
+		return c.Status(404).JSON(fiber.Map{"error": "User is not a member of this team"})
+	}
+
+	team.Members = removeString(team.Members, userID)
+	team.Leads = removeString(team.Leads, userID)
+	if len(team.Leads) == 0 && len(team.Members) > 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "A team needs at least one lead"})
+	}
+
+	if err := saveTeamMembership(ctx, &team); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update team"})
+	}
+	return c.JSON(team)
+}
+
+// findTeamByID loads a team of the caller's workspace by its ObjectID
+func findTeamByID(ctx context.Context, c *fiber.Ctx, teamID string) (models.Team, error) {
+	var team models.Team
+	objectID, err := primitive.ObjectIDFromHex(teamID)
+	if err != nil {
+		return team, mongo.ErrNoDocuments
+	}
+	err = config.TeamCollectionRef.FindOne(ctx, inWorkspace(c, bson.M{"_id": objectID})).Decode(&team)
+	return team, err
+}
+
+// teamLookupError answers a failed findTeamByID
+func teamLookupError(c *fiber.Ctx, err error) error {
+	if err == mongo.ErrNoDocuments {
+		return c.Status(404).JSON(fiber.Map{"error": "Team not found"})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch team"})
+}
+
+// canReadTeam reports whether the caller is a member or may manage every team
+func canReadTeam(c *fiber.Ctx, team models.Team) bool {
+	return team.HasMember(middleware.CurrentUserID(c)) || middleware.Can(c, middleware.PermTeamsManageAny)
+}
+
+// requireTeamLead answers 403 (or 404 for outsiders) unless the caller leads the team
+// or may manage every team. handled is true when a response was written.
+func requireTeamLead(c *fiber.Ctx, team models.Team) (bool, error) {
+	if team.HasLead(middleware.CurrentUserID(c)) || middleware.Can(c, middleware.PermTeamsManageAny) {
+		return false, nil
+	}
+	if !canReadTeam(c, team) {
+		return true, c.Status(404).JSON(fiber.Map{"error": "Team not found"})
+	}
+	return true, c.Status(403).JSON(fiber.Map{"error": "Only team leads can manage this team"})
+}
+
+// saveTeamMembership writes the members and leads of a team
+func saveTeamMembership(ctx context.Context, team *models.Team) error {
+	team.UpdatedAt = time.Now()
+	_, err := config.TeamCollectionRef.UpdateOne(ctx, bson.M{"_id": team.ID}, bson.M{"$set": bson.M{
+		"members":   team.Members,
+		"leads":     team.Leads,
+		"updatedAt": team.UpdatedAt,
+	}})
+	return err
+}
+
+// checkTeamUsers makes sure every ID belongs to a user of the caller's workspace that isn't deleted
+func checkTeamUsers(ctx context.Context, c *fiber.Ctx, userIDs []string) error {
+	for _, userID := range userIDs {
+		filter := bson.M{"$and": []bson.M{models.UserIDFilter(userID), notDeletedFilter()}}
+		count, err := config.UserCollectionRef.CountDocuments(ctx, inWorkspace(c, filter))
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("%w %s", errUnknownTeamUser, userID)
+		}
+	}
+	return nil
+}
+
+// teamMemberIDs returns the members of the caller's teams, or of one team when teamID is set.
+// ok is false when the caller can't see that team.
+func teamMemberIDs(ctx context.Context, c *fiber.Ctx, teamID string) (ids []string, ok bool, err error) {
+	if teamID != "" {
+		team, err := findTeamByID(ctx, c, teamID)
+		if err == mongo.ErrNoDocuments {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if !canReadTeam(c, team) {
+			return nil, false, nil
+		}
+		return team.Members, true, nil
+	}
+
+	cursor, err := config.TeamCollectionRef.Find(ctx, inWorkspace(c, bson.M{"members": middleware.CurrentUserID(c)}))
+	if err != nil {
+		return nil, false, err
+	}
+	defer cursor.Close(ctx)
+
+	var teams []models.Team
+	if err := cursor.All(ctx, &teams); err != nil {
+		return nil, false, err
+	}
+	for _, team := range teams {
+		ids = append(ids, team.Members...)
+	}
+	return uniqueStrings(ids), true, nil
+}
+
+// addUserToTeam makes a user a member of a team
+func addUserToTeam(ctx context.Context, teamID primitive.ObjectID, userID string) error {
+	_, err := config.TeamCollectionRef.UpdateOne(ctx,
+		bson.M{"_id": teamID},
+		bson.M{"$addToSet": bson.M{"members": userID}, "$set": bson.M{"updatedAt": time.Now()}},
+	)
+	return err
+}
+
+// removeUserFromTeams drops a deleted user from every team
+func removeUserFromTeams(ctx context.Context, userID string) error {
+	_, err := config.TeamCollectionRef.UpdateMany(ctx,
+		bson.M{"members": userID},
+		bson.M{"$pull": bson.M{"members": userID, "leads": userID}, "$set": bson.M{"updatedAt": time.Now()}},
+	)
+	return err
+}
+
+// usersByIDFilter matches users whose _id is any of the IDs, stored as ObjectID or string
+func usersByIDFilter(userIDs []string) bson.M {
+	ids := make([]interface{}, 0, 2*len(userIDs))
+	for _, userID := range userIDs {
+		ids = append(ids, userID)
+		if objectID, err := primitive.ObjectIDFromHex(userID); err == nil {
+			ids = append(ids, objectID)
+		}
+	}
+	return bson.M{"_id": bson.M{"$in": ids}}
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if value != "" && !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+func removeString(values []string, value string) []string {
+	kept := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != value {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
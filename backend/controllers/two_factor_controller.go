@@ -0,0 +1,506 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"backend/config"
+	"backend/middleware"
+	"backend/models"
+	"backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Purposes of the short-lived tokens handed out between the password step and the 2FA step
+const (
+	purposeTwoFactorChallenge  = "2fa_challenge"
+	purposeTwoFactorEnrollment = "2fa_enrollment"
+)
+
+const (
+	twoFactorIssuer       = "CoEmotion"
+	twoFactorTokenTTL     = 5 * time.Minute
+	recoveryCodeCount     = 10
+	twoFactorEnrollTTL    = 15 * time.Minute
+	invalidTwoFactorError = "Kode verifikasi tidak valid"
+)
+
+var (
+	errTwoFactorNotPending = errors.New("two-factor setup was not started")
+	errInvalidTwoFactor    = errors.New("invalid two-factor code")
+)
+
+// SetupTwoFactor godoc
+//
+//	@Summary		Start 2FA enrollment
+//	@Description	Generate a new TOTP secret for the current user. Show otpauthUri as a QR code, then confirm with a code from the authenticator app.
+//	@Tags			Two-Factor
+//	@Produce		json
+//	@Security		Bearer
+//	@Success		200	{object}	map[string]string	"Secret and otpauth:// provisioning URI"
+//	@Failure		409	{object}	map[string]string	"2FA already enabled"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Router			/api/2fa/setup [post]
+func SetupTwoFactor(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := findUserByID(ctx, middleware.CurrentUserID(c))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+	if user.TwoFactorEnabled {
+		return c.Status(409).JSON(fiber.Map{"error": "Two-factor authentication is already enabled"})
+	}
+
+	setup, err := startTwoFactorSetup(ctx, user)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to start two-factor setup"})
+	}
+	return c.JSON(setup)
+}
+
+// ConfirmTwoFactor godoc
+//
+//	@Summary		Confirm 2FA enrollment
+//	@Description	Enable 2FA with a code from the authenticator app. The recovery codes are only shown once.
+//	@Tags			Two-Factor
+//	@Accept			json
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			body	body		object{code=string}		true	"TOTP code"
+//	@Success		200		{object}	map[string]interface{}	"2FA enabled, with recovery codes"
+//	@Failure		400		{object}	map[string]string		"Invalid code or setup not started"
+//	@Failure		500		{object}	map[string]string		"Internal server error"
+//	@Router			/api/2fa/confirm [post]
+func ConfirmTwoFactor(c *fiber.Ctx) error {
+	var input struct {
+		Code string `json:"code"`
+	}
+	if err := c.BodyParser(&input); err != nil || input.Code == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := findUserByID(ctx, middleware.CurrentUserID(c))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	codes, err := confirmTwoFactorSetup(ctx, user, input.Code)
+	if err != nil {
+		return twoFactorSetupError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message":       "Two-factor authentication enabled",
+		"recoveryCodes": codes,
+	})
+}
+
+// DisableTwoFactor godoc
+//
+//	@Summary		Disable 2FA
+//	@Description	Turn 2FA off for the current user. Requires the password and a TOTP or recovery code. Not allowed for roles that require 2FA.
+//	@Tags			Two-Factor
+//	@Accept			json
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			body	body		object{password=string,code=string,recoveryCode=string}	true	"Password and second factor"
+//	@Success		200		{object}	map[string]string										"2FA disabled"
+//	@Failure		400		{object}	map[string]string										"Invalid password or code"
+//	@Failure		403		{object}	map[string]string										"2FA is required for this role"
+//	@Failure		500		{object}	map[string]string										"Internal server error"
+//	@Router			/api/2fa/disable [post]
+func DisableTwoFactor(c *fiber.Ctx) error {
+	var input struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := findUserByID(ctx, middleware.CurrentUserID(c))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+	if !user.TwoFactorEnabled {
+		return c.Status(400).JSON(fiber.Map{"error": "Two-factor authentication is not enabled"})
+	}
+
+	required, err := twoFactorRequiredFor(ctx, user.Role)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load security settings"})
+	}
+	if required {
+		return c.Status(403).JSON(fiber.Map{"error": "Two-factor authentication is required for your role"})
+	}
+
+	if !utils.CheckPasswordHash(input.Password, user.Password) {
+		return c.Status(400).JSON(fiber.Map{"error": "Password is incorrect"})
+	}
+	if err := verifySecondFactor(ctx, user, input.Code, input.RecoveryCode); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": invalidTwoFactorError})
+	}
+
+	_, err = config.UserCollectionRef.UpdateOne(ctx, models.UserIDFilter(user.ID), bson.M{
+		"$unset": bson.M{
+			"twoFactorEnabled":       "",
+			"twoFactorSecret":        "",
+			"twoFactorPendingSecret": "",
+			"twoFactorLastStep":      "",
+			"recoveryCodes":          "",
+		},
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to disable two-factor authentication"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes godoc
+//
+//	@Summary		Regenerate recovery codes
+//	@Description	Replace all recovery codes of the current user. Requires a TOTP code.
+//	@Tags			Two-Factor
+//	@Accept			json
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			body	body		object{code=string}		true	"TOTP code"
+//	@Success		200		{object}	map[string]interface{}	"New recovery codes"
+//	@Failure		400		{object}	map[string]string		"Invalid code"
+//	@Failure		500		{object}	map[string]string		"Internal server error"
+//	@Router			/api/2fa/recovery-codes [post]
+func RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	var input struct {
+		Code string `json:"code"`
+	}
+	if err := c.BodyParser(&input); err != nil || input.Code == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := findUserByID(ctx, middleware.CurrentUserID(c))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+	if !user.TwoFactorEnabled {
+		return c.Status(400).JSON(fiber.Map{"error": "Two-factor authentication is not enabled"})
+	}
+	if err := verifySecondFactor(ctx, user, input.Code, ""); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": invalidTwoFactorError})
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate recovery codes"})
+	}
+	if err := updateUserFields(ctx, user.ID, bson.M{"recoveryCodes": hashes}); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save recovery codes"})
+	}
+
+	return c.JSON(fiber.Map{"recoveryCodes": codes})
+}
+
+// LoginTwoFactor godoc
+//
+//	@Summary		Complete login with 2FA
+//	@Description	Exchange the challenge token returned by /login plus a TOTP or recovery code for the access and refresh tokens
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		object{challengeToken=string,code=string,recoveryCode=string}	true	"Challenge token and second factor"
+//	@Success		200		{object}	map[string]interface{}											"Login successful"
+//	@Failure		400		{object}	map[string]string												"Invalid request"
+//	@Failure		401		{object}	map[string]string												"Invalid challenge or code"
+//	@Failure		429		{object}	map[string]string												"Too many failed attempts, temporarily locked"
+//	@Failure		500		{object}	map[string]string												"Internal server error"
+//	@Router			/auth/login/2fa [post]
+func LoginTwoFactor(c *fiber.Ctx) error {
+	var input struct {
+		ChallengeToken string `json:"challengeToken"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recoveryCode"`
+	}
+	if err := c.BodyParser(&input); err != nil || input.ChallengeToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Request tidak valid"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := userFromPurposeToken(ctx, input.ChallengeToken, purposeTwoFactorChallenge)
+	if err != nil || !user.TwoFactorEnabled {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Sesi login tidak valid atau sudah kedaluwarsa"})
+	}
+
+	// Six-digit codes are guessable too, so they share the password lockout
+	if handled, err := rejectIfLoginLocked(ctx, c, user.Email); handled {
+		return err
+	}
+
+	if err := verifySecondFactor(ctx, user, input.Code, input.RecoveryCode); err != nil {
+		if errors.Is(err, errInvalidTwoFactor) {
+			recordFailedLogin(ctx, c, user.Email)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": invalidTwoFactorError})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memeriksa kode verifikasi"})
+	}
+
+	if err := clearAccountLoginFailures(ctx, user.Email); err != nil {
+		fmt.Println("Error clearing login failures:", err)
+	}
+
+	return respondWithTokens(c, ctx, user, "Login berhasil")
+}
+
+// EnrollTwoFactor godoc
+//
+//	@Summary		Start mandatory 2FA enrollment
+//	@Description	For users whose role requires 2FA but who have not enrolled yet. Uses the enrollment token returned by /login instead of an access token.
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		object{enrollmentToken=string}	true	"Enrollment token"
+//	@Success		200		{object}	map[string]string				"Secret and otpauth:// provisioning URI"
+//	@Failure		401		{object}	map[string]string				"Invalid enrollment token"
+//	@Failure		500		{object}	map[string]string				"Internal server error"
+//	@Router			/auth/2fa/enroll [post]
+func EnrollTwoFactor(c *fiber.Ctx) error {
+	var input struct {
+		EnrollmentToken string `json:"enrollmentToken"`
+	}
+	if err := c.BodyParser(&input); err != nil || input.EnrollmentToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Request tidak valid"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := userFromPurposeToken(ctx, input.EnrollmentToken, purposeTwoFactorEnrollment)
+	if err != nil || user.TwoFactorEnabled {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Sesi login tidak valid atau sudah kedaluwarsa"})
+	}
+
+	setup, err := startTwoFactorSetup(ctx, user)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memulai pengaturan 2FA"})
+	}
+	return c.JSON(setup)
+}
+
+// ConfirmTwoFactorEnrollment godoc
+//
+//	@Summary		Finish mandatory 2FA enrollment
+//	@Description	Enable 2FA with a code from the authenticator app and log in. The recovery codes are only shown once.
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		object{enrollmentToken=string,code=string}	true	"Enrollment token and TOTP code"
+//	@Success		200		{object}	map[string]interface{}						"Login successful, with recovery codes"
+//	@Failure		400		{object}	map[string]string							"Invalid code"
+//	@Failure		401		{object}	map[string]string							"Invalid enrollment token"
+//	@Failure		500		{object}	map[string]string							"Internal server error"
+//	@Router			/auth/2fa/enroll/confirm [post]
+func ConfirmTwoFactorEnrollment(c *fiber.Ctx) error {
+	var input struct {
+		EnrollmentToken string `json:"enrollmentToken"`
+		Code            string `json:"code"`
+	}
+	if err := c.BodyParser(&input); err != nil || input.EnrollmentToken == "" || input.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Request tidak valid"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := userFromPurposeToken(ctx, input.EnrollmentToken, purposeTwoFactorEnrollment)
+	if err != nil || user.TwoFactorEnabled {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Sesi login tidak valid atau sudah kedaluwarsa"})
+	}
+
+	codes, err := confirmTwoFactorSetup(ctx, user, input.Code)
+	if err != nil {
+		return twoFactorSetupError(c, err)
+	}
+
+	accessToken, refreshToken, err := startSessionWithTokens(ctx, c, user)
+	if errors.Is(err, errAccountDisabled) {
+		return rejectDisabledAccount(c)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal membuat token"})
+	}
+
+	response := loginPayload(user, accessToken, refreshToken, "Login berhasil, 2FA telah diaktifkan")
+	response["recoveryCodes"] = codes
+	return c.JSON(response)
+}
+
+// twoFactorLoginStep decides what Login returns after the password check.
+// It returns nil when no second step is needed and tokens can be issued right away.
+func twoFactorLoginStep(ctx context.Context, user models.User) (fiber.Map, error) {
+	if user.TwoFactorEnabled {
+		challenge, err := utils.GeneratePurposeToken(purposeTwoFactorChallenge, jwt.MapClaims{"id": user.ID}, twoFactorTokenTTL)
+		if err != nil {
+			return nil, err
+		}
+		return fiber.Map{
+			"message":           "Masukkan kode verifikasi dari aplikasi authenticator",
+			"twoFactorRequired": true,
+			"challengeToken":    challenge,
+		}, nil
+	}
+
+	required, err := twoFactorRequiredFor(ctx, user.Role)
+	if err != nil || !required {
+		return nil, err
+	}
+
+	enrollment, err := utils.GeneratePurposeToken(purposeTwoFactorEnrollment, jwt.MapClaims{"id": user.ID}, twoFactorEnrollTTL)
+	if err != nil {
+		return nil, err
+	}
+	return fiber.Map{
+		"message":                "Role Anda wajib menggunakan 2FA, silakan aktifkan terlebih dahulu",
+		"twoFactorSetupRequired": true,
+		"enrollmentToken":        enrollment,
+	}, nil
+}
+
+// userFromPurposeToken validates a 2FA challenge or enrollment token and loads its user
+func userFromPurposeToken(ctx context.Context, token, purpose string) (models.User, error) {
+	claims, err := utils.ParsePurposeToken(token, purpose)
+	if err != nil {
+		return models.User{}, err
+	}
+	userID, _ := claims["id"].(string)
+	return findUserByID(ctx, userID)
+}
+
+// startTwoFactorSetup stores a pending secret; it only becomes active once confirmed
+func startTwoFactorSetup(ctx context.Context, user models.User) (fiber.Map, error) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := updateUserFields(ctx, user.ID, bson.M{"twoFactorPendingSecret": secret}); err != nil {
+		return nil, err
+	}
+
+	return fiber.Map{
+		"secret":     secret,
+		"otpauthUri": utils.TOTPProvisioningURI(twoFactorIssuer, user.Email, secret),
+	}, nil
+}
+
+// confirmTwoFactorSetup activates the pending secret and returns fresh recovery codes
+func confirmTwoFactorSetup(ctx context.Context, user models.User, code string) ([]string, error) {
+	if user.TwoFactorPendingSecret == "" {
+		return nil, errTwoFactorNotPending
+	}
+
+	step, ok := utils.ValidateTOTP(user.TwoFactorPendingSecret, code, time.Now())
+	if !ok {
+		return nil, errInvalidTwoFactor
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = config.UserCollectionRef.UpdateOne(ctx, models.UserIDFilter(user.ID), bson.M{
+		"$set": bson.M{
+			"twoFactorEnabled":  true,
+			"twoFactorSecret":   user.TwoFactorPendingSecret,
+			"twoFactorLastStep": step,
+			"recoveryCodes":     hashes,
+		},
+		"$unset": bson.M{"twoFactorPendingSecret": ""},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// twoFactorSetupError maps confirmTwoFactorSetup errors to responses
+func twoFactorSetupError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errTwoFactorNotPending):
+		return c.Status(400).JSON(fiber.Map{"error": "Two-factor setup was not started"})
+	case errors.Is(err, errInvalidTwoFactor):
+		return c.Status(400).JSON(fiber.Map{"error": invalidTwoFactorError})
+	default:
+		fmt.Println("Error confirming two-factor setup:", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to enable two-factor authentication"})
+	}
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code.
+// TOTP steps can't be replayed and recovery codes are removed once used.
+func verifySecondFactor(ctx context.Context, user models.User, code, recoveryCode string) error {
+	if code != "" {
+		step, ok := utils.ValidateTOTP(user.TwoFactorSecret, code, time.Now())
+		if !ok {
+			return errInvalidTwoFactor
+		}
+
+		filter := models.UserIDFilter(user.ID)
+		filter["twoFactorLastStep"] = bson.M{"$not": bson.M{"$gte": step}}
+		result, err := config.UserCollectionRef.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"twoFactorLastStep": step}})
+		if err != nil {
+			return err
+		}
+		if result.ModifiedCount == 0 {
+			return errInvalidTwoFactor
+		}
+		return nil
+	}
+
+	if recoveryCode != "" {
+		hash := utils.HashToken(utils.NormalizeRecoveryCode(recoveryCode))
+		filter := models.UserIDFilter(user.ID)
+		filter["recoveryCodes"] = hash
+		result, err := config.UserCollectionRef.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"recoveryCodes": hash}})
+		if err != nil {
+			return err
+		}
+		if result.ModifiedCount == 0 {
+			return errInvalidTwoFactor
+		}
+		return nil
+	}
+
+	return errInvalidTwoFactor
+}
+
+// newRecoveryCodes returns plain recovery codes for the user and the hashes to store
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashToken(utils.NormalizeRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
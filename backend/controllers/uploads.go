@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"backend/storage"
+)
+
+// saveUpload stores a file under a name we generated and returns its public URL.
+// Every upload goes through the configured storage, so all instances see the same files.
+func saveUpload(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	return storage.Put(ctx, key, data, contentType)
+}
+
+// removeUpload deletes the file behind an upload URL. URLs of files stored
+// elsewhere (absolute URLs from SSO profiles) are left alone, as are files already gone.
+func removeUpload(ctx context.Context, url string) error {
+	key, ok := storage.KeyFromURL(url)
+	if !ok {
+		return nil
+	}
+	return storage.Delete(ctx, key)
+}
+
+// removeUploads deletes several uploads, logging rather than failing on errors
+func removeUploads(ctx context.Context, urls []string) {
+	for _, url := range urls {
+		if err := removeUpload(ctx, url); err != nil {
+			fmt.Println("Error removing upload:", err)
+		}
+	}
+}
+
+// profileImageURLs lists every file of a user's profile image: the main one and each avatar size
+func profileImageURLs(profileImage string, avatars map[string]string) []string {
+	urls := []string{}
+	if profileImage != "" {
+		urls = append(urls, profileImage)
+	}
+	for _, url := range avatars {
+		if url != profileImage {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"backend/config"
+	"backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserDeletionPolicy says what happens to the meetings and files of a user being deleted.
+// Everything is opt-in: without a policy the data stays as it is, so a restore brings it all back.
+// Cleanup is not undone by a restore.
+type UserDeletionPolicy struct {
+	// ReassignMeetingsTo hands the meetings the user created to another user of the workspace
+	ReassignMeetingsTo string `json:"reassignMeetingsTo,omitempty" bson:"reassignMeetingsTo,omitempty"`
+	// RemoveParticipations takes the user out of the participants of every meeting
+	RemoveParticipations bool `json:"removeParticipations,omitempty" bson:"removeParticipations,omitempty"`
+	// CancelSoloMeetings deletes meetings the user created that have nobody else in them
+	CancelSoloMeetings bool `json:"cancelSoloMeetings,omitempty" bson:"cancelSoloMeetings,omitempty"`
+	// DeleteProfileImage removes the uploaded profile image and its avatar sizes
+	DeleteProfileImage bool `json:"deleteProfileImage,omitempty" bson:"deleteProfileImage,omitempty"`
+}
+
+// UserDeletionReport is what the cleanup of a deleted user did. A failed step is
+// listed in Errors and doesn't stop the others.
+type UserDeletionReport struct {
+	MeetingsCancelled     int64    `json:"meetingsCancelled" bson:"meetingsCancelled"`
+	MeetingsReassigned    int64    `json:"meetingsReassigned" bson:"meetingsReassigned"`
+	ParticipationsRemoved int64    `json:"participationsRemoved" bson:"participationsRemoved"`
+	ProfileImageDeleted   bool     `json:"profileImageDeleted" bson:"profileImageDeleted"`
+	Errors                []string `json:"errors,omitempty" bson:"errors,omitempty"`
+}
+
+var errInvalidReassignTarget = errors.New("reassignMeetingsTo must be another active user of the workspace")
+
+// checkDeletionPolicy validates a policy for deleting user before anything is changed
+func checkDeletionPolicy(ctx context.Context, c *fiber.Ctx, user models.User, policy UserDeletionPolicy) error {
+	if policy.ReassignMeetingsTo == "" {
+		return nil
+	}
+	if policy.ReassignMeetingsTo == user.ID {
+		return errInvalidReassignTarget
+	}
+	// Meetings refer to their creator by ObjectID
+	if _, err := primitive.ObjectIDFromHex(policy.ReassignMeetingsTo); err != nil {
+		return errInvalidReassignTarget
+	}
+	owner, err := findWorkspaceUser(ctx, c, policy.ReassignMeetingsTo)
+	if err != nil || owner.IsDisabled() {
+		return errInvalidReassignTarget
+	}
+	return nil
+}
+
+// cleanUpDeletedUser applies a deletion policy. Solo meetings are cancelled before
+// the rest are reassigned, so the new owner doesn't inherit empty meetings.
+func cleanUpDeletedUser(ctx context.Context, user models.User, policy UserDeletionPolicy) UserDeletionReport {
+	var report UserDeletionReport
+	fail := func(step string, err error) {
+		fmt.Printf("Error cleaning up after user %s (%s): %v\n", user.ID, step, err)
+		report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", step, err))
+	}
+
+	// Users with string IDs can't appear in meetings, which only hold ObjectIDs
+	if objectID, err := primitive.ObjectIDFromHex(user.ID); err == nil {
+		workspaceID := user.Workspace()
+
+		if policy.CancelSoloMeetings {
+			result, err := config.MeetingCollectionRef.DeleteMany(ctx, scopeToWorkspace(workspaceID, bson.M{
+				"createdBy":  objectID,
+				"allMembers": bson.M{"$ne": true},
+				"teamId":     bson.M{"$exists": false},
+				// Nobody but the creator: no participants, or only the creator
+				"participants": bson.M{"$not": bson.M{"$elemMatch": bson.M{"$ne": objectID}}},
+			}))
+			if err != nil {
+				fail("cancel solo meetings", err)
+			} else {
+				report.MeetingsCancelled = result.DeletedCount
+			}
+		}
+
+		if policy.ReassignMeetingsTo != "" {
+			owner, _ := primitive.ObjectIDFromHex(policy.ReassignMeetingsTo)
+			result, err := config.MeetingCollectionRef.UpdateMany(ctx,
+				scopeToWorkspace(workspaceID, bson.M{"createdBy": objectID}),
+				bson.M{"$set": bson.M{"createdBy": owner}},
+			)
+			if err != nil {
+				fail("reassign meetings", err)
+			} else {
+				report.MeetingsReassigned = result.ModifiedCount
+			}
+		}
+
+		if policy.RemoveParticipations {
+			result, err := config.MeetingCollectionRef.UpdateMany(ctx,
+				scopeToWorkspace(workspaceID, bson.M{"participants": objectID}),
+				bson.M{"$pull": bson.M{"participants": objectID}},
+			)
+			if err != nil {
+				fail("remove participations", err)
+			} else {
+				report.ParticipationsRemoved = result.ModifiedCount
+			}
+		}
+	}
+
+	if policy.DeleteProfileImage && user.ProfileImage != "" {
+		if err := deleteProfileImage(ctx, user); err != nil {
+			fail("delete profile image", err)
+		} else {
+			report.ProfileImageDeleted = true
+		}
+	}
+
+	return report
+}
+
+// deleteProfileImage removes the user's uploaded image files, every avatar size
+// included, and clears the fields. Images hosted elsewhere (absolute URLs) are only unlinked.
+func deleteProfileImage(ctx context.Context, user models.User) error {
+	for _, url := range profileImageURLs(user.ProfileImage, user.Avatars) {
+		if err := removeUpload(ctx, url); err != nil {
+			return err
+		}
+	}
+	_, err := config.UserCollectionRef.UpdateOne(ctx, models.UserIDFilter(user.ID), bson.M{"$unset": bson.M{"profileImage": "", "avatars": ""}})
+	return err
+}
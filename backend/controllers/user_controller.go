@@ -2,17 +2,21 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"io"
+	"strconv"
+	"strings"
 	"time"
 
 	"backend/config"
-	// "backend/middleware"
+	"backend/imaging"
+	"backend/middleware"
 	"backend/models"
+	"backend/presence"
 	"backend/utils"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -21,82 +25,122 @@ import (
 // GetTeamMembers godoc
 //
 //	@Summary		Get team members
-//	@Description	Get list of team members with additional information
+//	@Description	Get a page of the members of the caller's teams, or of one team with ?teamId=, with presence information. Pages are cursor based: pass nextCursor back as cursor until it is empty.
 //	@Tags			Users
 //	@Produce		json
 //	@Security		Bearer
-//	@Success		200	{array}		models.UserResponse	"List of team members"
-//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Param			teamId			query		string					false	"Only members of this team"
+//	@Param			limit			query		int						false	"Page size (default 25, max 100)"
+//	@Param			cursor			query		string					false	"nextCursor of the previous page"
+//	@Param			sort			query		string					false	"Sort field"	Enums(name, email, lastActive)
+//	@Param			order			query		string					false	"Sort order"	Enums(asc, desc)
+//	@Param			role			query		string					false	"Comma separated roles"
+//	@Param			status			query		string					false	"Comma separated presence states (online, away, offline) or custom statuses"
+//	@Param			accountStatus	query		string					false	"Comma separated account states"
+//	@Param			q				query		string					false	"Case-insensitive search on name and email"
+//	@Success		200				{object}	map[string]interface{}	"data: team members, nextCursor, total"
+//	@Failure		400				{object}	map[string]string		"Invalid query parameters"
+//	@Failure		404				{object}	map[string]string		"Team not found"
+//	@Failure		500				{object}	map[string]string		"Internal server error"
 //	@Router			/api/team-members [get]
 //
 // Mendapatkan daftar pengguna dengan informasi tambahan
 func GetTeamMembers(c *fiber.Ctx) error {
-	var users []models.UserResponse
+	query, err := parseUserListQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	cursor, err := config.UserCollectionRef.Find(ctx, bson.M{})
+	// Only people who share a team with the caller
+	memberIDs, ok, err := teamMemberIDs(ctx, c, c.Query("teamId"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	if !ok {
+		return c.Status(404).JSON(fiber.Map{"error": "Team not found"})
+	}
+	query.Filter = inWorkspace(c, bson.M{"$and": []bson.M{query.Filter, usersByIDFilter(memberIDs)}})
+
+	page, err := findUserPage(ctx, query)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
-	defer cursor.Close(ctx)
 
-	for cursor.Next(ctx) {
-		var user models.User
-		if err := cursor.Decode(&user); err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	now := time.Now()
+	users := make([]models.UserResponse, 0, len(page.Users))
+	for _, user := range page.Users {
+		role := user.Role
+		if role == "" {
+			role = "Team Member" // Default role
 		}
 
 		// Convert to user response without password
+		current := userPresence(user, now)
 		userResponse := models.UserResponse{
-			ID:         user.ID,
-			Nama:       user.Nama,
-			Email:      user.Email,
-			Role:       "Team Member", // Default role
-			Status:     "Online",      // Placeholder
-			LastActive: time.Now(),    // Placeholder
+			ID:           user.ID,
+			Nama:         user.Nama,
+			Email:        user.Email,
+			Role:         role,
+			Status:       current.Status,
+			LastActive:   current.LastActive,
+			CustomStatus: current.CustomStatus,
+			ProfileImage: user.ProfileImage,
+			Avatars:      user.Avatars,
 		}
 
 		users = append(users, userResponse)
 	}
 
-	return c.Status(200).JSON(users)
+	return c.Status(200).JSON(fiber.Map{
+		"data":       users,
+		"nextCursor": page.NextCursor,
+		"total":      page.Total,
+	})
 }
 
 // GetUsers godoc
 //
-//	@Summary		Get all users
-//	@Description	Get list of all users in the system
+//	@Summary		Get users
+//	@Description	Get a page of the users in the caller's workspace. Pages are cursor based: pass nextCursor back as cursor until it is empty.
 //	@Tags			Users
 //	@Produce		json
-//	@Success		200	{array}		models.User			"List of users"
-//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Security		Bearer
+//	@Param			limit			query		int						false	"Page size (default 25, max 100)"
+//	@Param			cursor			query		string					false	"nextCursor of the previous page"
+//	@Param			sort			query		string					false	"Sort field"	Enums(name, email, lastActive)
+//	@Param			order			query		string					false	"Sort order"	Enums(asc, desc)
+//	@Param			role			query		string					false	"Comma separated roles"
+//	@Param			status			query		string					false	"Comma separated presence states (online, away, offline) or custom statuses"
+//	@Param			accountStatus	query		string					false	"Comma separated account states"
+//	@Param			q				query		string					false	"Case-insensitive search on name and email"
+//	@Success		200				{object}	map[string]interface{}	"data: users, nextCursor, total"
+//	@Failure		400				{object}	map[string]string		"Invalid query parameters"
+//	@Failure		500				{object}	map[string]string		"Internal server error"
 //	@Router			/users [get]
-//
-// Make sure this function is correctly implemented
 func GetUsers(c *fiber.Ctx) error {
+	query, err := parseUserListQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	cursor, err := config.UserCollectionRef.Find(ctx, bson.M{})
+	query.Filter = inWorkspace(c, query.Filter)
+	page, err := findUserPage(ctx, query)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch users: " + err.Error(),
 		})
 	}
-	defer cursor.Close(ctx)
-
-	var users []models.User
-	if err := cursor.All(ctx, &users); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to parse users data: " + err.Error(),
-		})
-	}
 
 	// Convert to safe response without passwords
-	var safeUsers []fiber.Map
-	for _, user := range users {
+	now := time.Now()
+	safeUsers := make([]fiber.Map, 0, len(page.Users))
+	for _, user := range page.Users {
 		// Ensure role is not empty
 		role := user.Role
 		if role == "" {
@@ -104,42 +148,89 @@ func GetUsers(c *fiber.Ctx) error {
 		}
 
 		safeUsers = append(safeUsers, fiber.Map{
-			"id":           user.ID,
-			"nama":         user.Nama,
-			"email":        user.Email,
-			"role":         role,
-			"bio":          user.Bio,
-			"profileImage": user.ProfileImage,
+			"id":            user.ID,
+			"nama":          user.Nama,
+			"email":         user.Email,
+			"role":          role,
+			"bio":           user.Bio,
+			"profileImage":  user.ProfileImage,
+			"avatars":       user.Avatars,
+			"status":        presence.State(user.LastActive, now),
+			"lastActive":    user.LastActive,
+			"customStatus":  user.ActiveCustomStatus(now),
+			"accountStatus": user.AccountState(),
 		})
 	}
 
-	return c.JSON(safeUsers)
+	return c.JSON(fiber.Map{
+		"data":       safeUsers,
+		"nextCursor": page.NextCursor,
+		"total":      page.Total,
+	})
 }
 
 // CreateUser godoc
 //
 //	@Summary		Create a new user
-//	@Description	Create a new user account with username, email and password
+//	@Description	Create a new user account with name, email and password. Role and workspaceId are only taken from callers allowed to manage them.
 //	@Tags			Users
 //	@Accept			json
 //	@Produce		json
-//	@Param			user	body		models.User			true	"User data"
+//	@Param			user	body		object{nama=string,email=string,password=string,role=string,bio=string,workspaceId=string}	true	"User data"
 //	@Success		200		{object}	map[string]interface{}	"User created successfully"
-//	@Failure		400		{object}	map[string]string		"Invalid request"
+//	@Failure		400		{object}	map[string]string		"Invalid request or unknown role"
+//	@Failure		422		{object}	map[string]interface{}	"Password violates the password policy"
 //	@Failure		500		{object}	map[string]string		"Internal server error"
 //	@Router			/users [post]
 func CreateUser(c *fiber.Ctx) error {
-	var user models.User
-	if err := c.BodyParser(&user); err != nil {
+	// IDs, account state, images and presence are never taken from the client
+	var input struct {
+		Nama        string `json:"nama"`
+		Email       string `json:"email"`
+		Password    string `json:"password"`
+		Role        string `json:"role"`
+		Bio         string `json:"bio"`
+		WorkspaceID string `json:"workspaceId"`
+	}
+	if err := c.BodyParser(&input); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
+	user := models.User{
+		Nama:        input.Nama,
+		Email:       input.Email,
+		Role:        input.Role,
+		Bio:         input.Bio,
+		WorkspaceID: input.WorkspaceID,
+	}
 
-	// Hash password
-	hashedPassword, err := utils.HashPassword(user.Password)
-	if err != nil {
+	// Only callers who may manage roles get to pick one; everyone else gets the default
+	if user.Role == "" || !middleware.Can(c, middleware.PermUsersManageRoles) {
+		user.Role = middleware.DefaultRole
+	}
+	if !middleware.IsKnownRole(user.Role) {
+		return c.Status(400).JSON(fiber.Map{"error": "Unknown role " + user.Role})
+	}
+
+	// New accounts join the caller's workspace, anonymous sign-ups the default one
+	if user.WorkspaceID == "" || !middleware.Can(c, middleware.PermWorkspacesManage) {
+		user.WorkspaceID = middleware.CurrentWorkspaceID(c)
+	}
+
+	// Accounts created by an authorized user are trusted; anonymous sign-ups must verify their email
+	trusted := middleware.Can(c, middleware.PermUsersCreate)
+	if trusted {
+		user.AccountStatus = models.AccountStatusActive
+	} else {
+		user.AccountStatus = models.AccountStatusPendingVerification
+	}
+
+	// Check the password against the policy, then hash it
+	if handled, err := validatePassword(c, input.Password, user); handled {
+		return err
+	}
+	if _, err := applyNewPassword(&user, input.Password); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Gagal hash password"})
 	}
-	user.Password = hashedPassword
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -149,6 +240,13 @@ func CreateUser(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	if !trusted {
+		user.ID = insertedIDString(result.InsertedID)
+		if err := sendVerificationEmail(ctx, user); err != nil {
+			fmt.Println("Error sending verification email:", err)
+		}
+	}
+
 	return c.Status(200).JSON(fiber.Map{"inserted_id": result.InsertedID})
 }
 
@@ -157,9 +255,10 @@ func CreateUser(c *fiber.Ctx) error {
 // GetUserById godoc
 //
 //	@Summary		Get user by ID
-//	@Description	Get user information by user ID
+//	@Description	Get user information by user ID, within the caller's workspace
 //	@Tags			Users
 //	@Produce		json
+//	@Security		Bearer
 //	@Param			id	path		string					true	"User ID"
 //	@Success		200	{object}	models.UserResponse		"User information"
 //	@Failure		404	{object}	map[string]string		"User not found"
@@ -179,7 +278,7 @@ func GetUserById(c *fiber.Ctx) error {
 	// Coba dengan ObjectID terlebih dahulu
 	objectID, objErr := primitive.ObjectIDFromHex(userID)
 	if objErr == nil {
-		err = config.UserCollectionRef.FindOne(ctx, bson.M{"_id": objectID}).Decode(&user)
+		err = config.UserCollectionRef.FindOne(ctx, inWorkspace(c, visibleUserFilter(c, bson.M{"_id": objectID}))).Decode(&user)
 		if err == nil {
 			// User ditemukan dengan ObjectID
 			userResponse := models.UserResponse{
@@ -189,6 +288,10 @@ func GetUserById(c *fiber.Ctx) error {
 				Role:         user.Role,
 				Bio:          user.Bio,
 				ProfileImage: user.ProfileImage,
+				Avatars:      user.Avatars,
+
+				AccountStatus:    user.AccountState(),
+				TwoFactorEnabled: user.TwoFactorEnabled,
 			}
 			fmt.Println("User found with ObjectID:", userID)
 			return c.JSON(userResponse)
@@ -196,7 +299,7 @@ func GetUserById(c *fiber.Ctx) error {
 	}
 
 	// Jika tidak ditemukan dengan ObjectID, coba dengan string ID
-	err = config.UserCollectionRef.FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+	err = config.UserCollectionRef.FindOne(ctx, inWorkspace(c, visibleUserFilter(c, bson.M{"_id": userID}))).Decode(&user)
 	if err != nil {
 		fmt.Println("User not found with ID:", userID, "Error:", err)
 		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
@@ -210,6 +313,10 @@ func GetUserById(c *fiber.Ctx) error {
 		Role:         user.Role,
 		Bio:          user.Bio,
 		ProfileImage: user.ProfileImage,
+		Avatars:      user.Avatars,
+
+		AccountStatus:    user.AccountState(),
+		TwoFactorEnabled: user.TwoFactorEnabled,
 	}
 
 	fmt.Println("User found with string ID:", userID)
@@ -219,29 +326,21 @@ func GetUserById(c *fiber.Ctx) error {
 // UploadProfileImage godoc
 //
 //	@Summary		Upload profile image
-//	@Description	Upload a profile image for the authenticated user
+//	@Description	Upload a profile image for the authenticated user. JPEG, PNG and WebP are accepted, up to IMAGE_MAX_BYTES (default 2 MiB). The image is cropped to a square, stripped of metadata and stored in every AVATAR_SIZES size (default 64, 128 and 256 pixels); imageUrl is the largest.
 //	@Tags			Users
 //	@Accept			multipart/form-data
 //	@Produce		json
 //	@Security		Bearer
 //	@Param			image	formData	file					true	"Image file"
-//	@Success		200		{object}	map[string]interface{}	"Image uploaded successfully"
+//	@Success		200		{object}	map[string]interface{}	"imageUrl and avatars, the URL of each size"
 //	@Failure		400		{object}	map[string]string		"Invalid request"
+//	@Failure		404		{object}	map[string]string		"User not found"
+//	@Failure		413		{object}	map[string]string		"Image too large"
+//	@Failure		415		{object}	map[string]string		"Not a JPEG, PNG or WebP image"
 //	@Failure		500		{object}	map[string]string		"Internal server error"
 //	@Router			/api/upload-profile-image [post]
 func UploadProfileImage(c *fiber.Ctx) error {
-	// Get user ID from JWT token
-	userClaims, ok := c.Locals("user").(jwt.MapClaims)
-	if !ok {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to parse user claims"})
-	}
-
-	// Convert the ID to string safely
-	userID, ok := userClaims["id"].(string)
-	if !ok {
-		return c.Status(500).JSON(fiber.Map{"error": "Invalid user ID in token"})
-	}
-
+	userID := middleware.CurrentUserID(c)
 	fmt.Println("Processing upload for user:", userID)
 
 	// Get the file from request
@@ -249,97 +348,94 @@ func UploadProfileImage(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "No file provided or invalid file"})
 	}
-
-	// Create uploads directory if it doesn't exist
-	uploadDir := "./uploads"
-	if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
-		err = os.MkdirAll(uploadDir, 0755)
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to create uploads directory"})
-		}
+	maxBytes := imaging.MaxUploadBytes()
+	if file.Size > int64(maxBytes) {
+		return c.Status(413).JSON(fiber.Map{"error": fmt.Sprintf("Image must be at most %d KB", maxBytes/1024)})
 	}
 
-	// Generate file name with timestamp to avoid duplicates
-	timeStamp := time.Now().UnixNano()
-	fileName := fmt.Sprintf("%d_%s", timeStamp, file.Filename)
-	filePath := fmt.Sprintf("%s/%s", uploadDir, fileName)
-
-	// Save the file
-	if err := c.SaveFile(file, filePath); err != nil {
-		fmt.Println("Error saving file:", err)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to save the file"})
+	f, err := file.Open()
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "No file provided or invalid file"})
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, int64(maxBytes)+1))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "No file provided or invalid file"})
 	}
 
-	// Create image URL - PENTING: path harus mulai dengan '/'
-	imageURL := fmt.Sprintf("/uploads/%s", fileName)
-	fmt.Println("Image saved at:", imageURL)
+	// The content decides what the file is, not its name or the client's Content-Type
+	avatars, err := imaging.Avatars(data, imaging.AvatarSizes())
+	if err != nil {
+		if errors.Is(err, imaging.ErrTooLarge) {
+			return c.Status(413).JSON(fiber.Map{"error": "Image is too large"})
+		}
+		if errors.Is(err, imaging.ErrUnsupportedType) {
+			return c.Status(415).JSON(fiber.Map{"error": "Only JPEG, PNG and WebP images are accepted"})
+		}
+		fmt.Println("Error processing image:", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to process the image"})
+	}
 
-	// Update user profile in database
-	var result *mongo.UpdateResult
-	var updateErr error
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Try with ObjectID first
-	objectID, err := primitive.ObjectIDFromHex(userID)
-	if err == nil {
-		// Valid ObjectID
-		result, updateErr = config.UserCollectionRef.UpdateOne(
-			ctx,
-			bson.M{"_id": objectID},
-			bson.M{"$set": bson.M{"profileImage": imageURL}},
-		)
-
-		if updateErr == nil && result.MatchedCount > 0 {
-			fmt.Println("Updated with ObjectID successfully:", result)
-			return c.JSON(fiber.Map{
-				"message":  "Image uploaded successfully",
-				"imageUrl": imageURL,
-			})
+	user, err := findWorkspaceUser(ctx, c, userID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{"error": "User not found"})
 		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch user"})
 	}
 
-	// Fall back to string ID
-	result, updateErr = config.UserCollectionRef.UpdateOne(
-		ctx,
-		bson.M{"_id": userID},
-		bson.M{"$set": bson.M{"profileImage": imageURL}},
-	)
-
-	if updateErr != nil {
-		// Clean up file on error
-		os.Remove(filePath)
-		fmt.Println("Error updating profile:", updateErr)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to update profile"})
+	// File names are ours; the client's name never reaches the disk
+	base := primitive.NewObjectID().Hex()
+	urls := map[string]string{}
+	var imageURL string
+	for _, avatar := range avatars {
+		url, err := saveUpload(ctx, fmt.Sprintf("%s_%d%s", base, avatar.Size, imaging.AvatarExtension), avatar.Data, imaging.AvatarContentType)
+		if err != nil {
+			fmt.Println("Error saving file:", err)
+			removeUploads(ctx, profileImageURLs("", urls))
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to save the file"})
+		}
+		urls[strconv.Itoa(avatar.Size)] = url
+		// Sizes are ascending, so the last one is the largest
+		imageURL = url
 	}
 
-	if result.MatchedCount == 0 {
-		// No document matched, clean up file
-		os.Remove(filePath)
-		fmt.Println("No user found with ID:", userID)
-		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	err = updateUserFields(ctx, user.ID, bson.M{"profileImage": imageURL, "avatars": urls})
+	if err != nil {
+		// Clean up files on error
+		removeUploads(ctx, profileImageURLs("", urls))
+		fmt.Println("Error updating profile:", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update profile"})
 	}
 
-	fmt.Println("Update successful with string ID:", result)
+	// The previous image is no longer referenced anywhere
+	removeUploads(ctx, profileImageURLs(user.ProfileImage, user.Avatars))
 
+	fmt.Println("Image saved at:", imageURL)
 	return c.JSON(fiber.Map{
 		"message":  "Image uploaded successfully",
 		"imageUrl": imageURL,
+		"avatars":  urls,
 	})
 }
 
 // UpdateUser godoc
 //
 //	@Summary		Update user information
-//	@Description	Update user profile information including name, email, role, bio, and password
+//	@Description	Update user profile information including name, email, role, bio, and password. A new email is only stored as pending: it replaces the current one once the link mailed to it is used.
 //	@Tags			Users
 //	@Accept			json
 //	@Produce		json
 //	@Param			id		path		string					true	"User ID"
-//	@Param			user	body		object{nama=string,email=string,role=string,bio=string,profileImage=string,currentPassword=string,newPassword=string}	true	"User update data"
-//	@Success		200		{object}	map[string]string		"User updated successfully"
-//	@Failure		400		{object}	map[string]string		"Invalid request"
+//	@Param			user	body		object{nama=string,email=string,role=string,bio=string,currentPassword=string,newPassword=string}	true	"User update data"
+//	@Success		200		{object}	map[string]string		"User updated successfully; pendingEmail is set while an email change awaits confirmation"
+//	@Failure		400		{object}	map[string]string		"Invalid request or unknown role"
+//	@Failure		403		{object}	map[string]string		"Forbidden - Not allowed to update this user, or changing email, role or password while impersonating"
 //	@Failure		404		{object}	map[string]string		"User not found"
+//	@Failure		409		{object}	map[string]string		"Email already registered"
 //	@Failure		500		{object}	map[string]string		"Internal server error"
 //	@Router			/api/users/{id} [put]
 //
@@ -353,7 +449,6 @@ func UpdateUser(c *fiber.Ctx) error {
 		Email           string `json:"email"`
 		Role            string `json:"role"`
 		Bio             string `json:"bio"`
-		ProfileImage    string `json:"profileImage"`
 		CurrentPassword string `json:"currentPassword"`
 		NewPassword     string `json:"newPassword"`
 	}
@@ -362,22 +457,63 @@ func UpdateUser(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	fmt.Printf("Update data: %+v\n", updateData)
-
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	user, err := findWorkspaceUser(ctx, c, userID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	// Editing someone else's profile needs the ":any" permission
+	isSelf := user.ID == middleware.CurrentUserID(c)
+	if !isSelf && !middleware.Can(c, middleware.PermUsersUpdateAny) {
+		return c.Status(403).JSON(fiber.Map{"error": "You can only update your own profile"})
+	}
+	if isSelf && !middleware.Can(c, middleware.PermUsersUpdateSelf) {
+		return c.Status(403).JSON(fiber.Map{"error": "You are not allowed to update your profile"})
+	}
+
 	update := bson.M{}
 
 	if updateData.Nama != "" {
 		update["nama"] = updateData.Nama
 	}
 
-	if updateData.Email != "" {
-		update["email"] = updateData.Email
+	// A new address only takes effect once the link sent to it is used
+	newEmail := strings.TrimSpace(updateData.Email)
+	if strings.EqualFold(newEmail, user.Email) {
+		newEmail = ""
+	}
+	if newEmail != "" {
+		// Whoever controls the email controls the account, so it is not the impersonator's to change
+		if middleware.IsImpersonating(c) {
+			return middleware.RejectImpersonation(c)
+		}
+		taken, err := config.UserCollectionRef.CountDocuments(ctx, emailFilter(newEmail))
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to check email"})
+		}
+		if taken > 0 {
+			return c.Status(409).JSON(fiber.Map{"error": "Email is already registered"})
+		}
 	}
 
-	if updateData.Role != "" {
+	// The frontend always sends the role, so only an actual change needs the permission
+	currentRole := user.Role
+	if currentRole == "" {
+		currentRole = middleware.DefaultRole
+	}
+	if updateData.Role != "" && updateData.Role != currentRole {
+		if middleware.IsImpersonating(c) {
+			return middleware.RejectImpersonation(c)
+		}
+		if !middleware.Can(c, middleware.PermUsersManageRoles) {
+			return c.Status(403).JSON(fiber.Map{"error": "Only administrators can change roles"})
+		}
+		if !middleware.IsKnownRole(updateData.Role) {
+			return c.Status(400).JSON(fiber.Map{"error": "Unknown role " + updateData.Role})
+		}
 		update["role"] = updateData.Role
 	}
 
@@ -385,27 +521,12 @@ func UpdateUser(c *fiber.Ctx) error {
 		update["bio"] = updateData.Bio
 	}
 
-	if updateData.ProfileImage != "" {
-		update["profileImage"] = updateData.ProfileImage
-	}
-
-	if updateData.NewPassword != "" && updateData.CurrentPassword != "" {
-		// Validasi current password sebelum update
-		var user models.User
-
-		// Coba dengan ObjectID
-		objectID, err := primitive.ObjectIDFromHex(userID)
-		if err == nil {
-			err = config.UserCollectionRef.FindOne(ctx, bson.M{"_id": objectID}).Decode(&user)
+	if updateData.NewPassword != "" {
+		if middleware.IsImpersonating(c) {
+			return middleware.RejectImpersonation(c)
 		}
-
-		// Jika tidak berhasil, coba dengan string ID
-		if err != nil {
-			err = config.UserCollectionRef.FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
-		}
-
-		if err != nil {
-			return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+		if updateData.CurrentPassword == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "Current password is required to set a new password"})
 		}
 
 		// Verify current password
@@ -413,40 +534,57 @@ func UpdateUser(c *fiber.Ctx) error {
 			return c.Status(400).JSON(fiber.Map{"error": "Current password is incorrect"})
 		}
 
-		// Hash new password
-		hashedPassword, err := utils.HashPassword(updateData.NewPassword)
+		// The policy looks at the name and email the profile will have after this update
+		checked := user
+		if updateData.Nama != "" {
+			checked.Nama = updateData.Nama
+		}
+		if newEmail != "" {
+			checked.Email = newEmail
+		}
+		if handled, err := validatePassword(c, updateData.NewPassword, checked); handled {
+			return err
+		}
+
+		passwordFields, err := applyNewPassword(&user, updateData.NewPassword)
 		if err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": "Failed to hash new password"})
 		}
-
-		update["password"] = hashedPassword
+		for field, value := range passwordFields {
+			update[field] = value
+		}
 	}
 
+	if newEmail != "" {
+		update["pendingEmail"] = newEmail
+	}
 	if len(update) == 0 {
 		return c.Status(400).JSON(fiber.Map{"error": "No fields to update"})
 	}
+	response := fiber.Map{"message": "User updated successfully"}
+	if newEmail != "" {
+		response["pendingEmail"] = newEmail
+	}
 
 	// Coba update dengan ObjectID
-	var err error
-
 	objectID, err := primitive.ObjectIDFromHex(userID)
 	if err == nil {
 		updateResult, err := config.UserCollectionRef.UpdateOne(
 			ctx,
-			bson.M{"_id": objectID},
+			inWorkspace(c, bson.M{"_id": objectID}),
 			bson.M{"$set": update},
 		)
 
 		if err == nil && updateResult.MatchedCount > 0 {
 			fmt.Println("Updated with ObjectID successfully")
-			return c.Status(200).JSON(fiber.Map{"message": "User updated successfully"})
+			return respondToUserUpdate(c, ctx, user, newEmail, response)
 		}
 	}
 
 	// Jika gagal dengan ObjectID, coba dengan string ID
 	updateResult, err := config.UserCollectionRef.UpdateOne(
 		ctx,
-		bson.M{"_id": userID},
+		inWorkspace(c, bson.M{"_id": userID}),
 		bson.M{"$set": update},
 	)
 
@@ -460,99 +598,55 @@ func UpdateUser(c *fiber.Ctx) error {
 	}
 
 	fmt.Println("Updated with string ID successfully")
-	return c.Status(200).JSON(fiber.Map{"message": "User updated successfully"})
+	return respondToUserUpdate(c, ctx, user, newEmail, response)
 }
 
-// DeleteUser godoc
-//
-//	@Summary		Delete user
-//	@Description	Delete a user account by user ID
-//	@Tags			Users
-//	@Produce		json
-//	@Security		Bearer
-//	@Param			id	path		string				true	"User ID"
-//	@Success		200	{object}	map[string]string	"User deleted successfully"
-//	@Failure		400	{object}	map[string]string	"Bad request - Cannot delete self"
-//	@Failure		403	{object}	map[string]string	"Forbidden - Admin role required"
-//	@Failure		404	{object}	map[string]string	"User not found"
-//	@Failure		500	{object}	map[string]string	"Internal server error"
-//	@Router			/api/users/{id} [delete]
-func DeleteUser(c *fiber.Ctx) error {
-	// Get token user information
-	userClaims, ok := c.Locals("user").(jwt.MapClaims)
-	if !ok {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to parse user claims"})
-	}
-
-	// Get admin user's ID
-	adminID, ok := userClaims["id"].(string)
-	if !ok {
-		return c.Status(500).JSON(fiber.Map{"error": "Invalid user ID in token"})
-	}
-
-	// Get admin user's role from database to verify they're admin
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	var adminUser models.User
-
-	// Try getting admin user with ObjectID first
-	adminObjectID, err := primitive.ObjectIDFromHex(adminID)
-	if err == nil {
-		err = config.UserCollectionRef.FindOne(ctx, bson.M{"_id": adminObjectID}).Decode(&adminUser)
-		if err != nil {
-			// Try with string ID
-			err = config.UserCollectionRef.FindOne(ctx, bson.M{"_id": adminID}).Decode(&adminUser)
+// respondToUserUpdate sends the confirmation link for a requested email change, if any,
+// and answers the update
+func respondToUserUpdate(c *fiber.Ctx, ctx context.Context, user models.User, newEmail string, response fiber.Map) error {
+	if newEmail != "" {
+		if err := sendEmailChangeConfirmation(ctx, user, newEmail); err != nil {
+			fmt.Println("Error sending email change confirmation:", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to send the confirmation email"})
 		}
-	} else {
-		// Try with string ID
-		err = config.UserCollectionRef.FindOne(ctx, bson.M{"_id": adminID}).Decode(&adminUser)
+		response["message"] = "User updated; the new email takes effect once the link sent to it is used"
 	}
+	return c.Status(200).JSON(response)
+}
 
+// updateUserFields applies a $set to a user
+func updateUserFields(ctx context.Context, userID string, fields bson.M) error {
+	result, err := config.UserCollectionRef.UpdateOne(ctx, models.UserIDFilter(userID), bson.M{"$set": fields})
 	if err != nil {
-		fmt.Println("Error finding admin user:", err)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to verify admin credentials"})
+		return err
 	}
-
-	// Check if user is admin
-	if adminUser.Role != "Admin" {
-		fmt.Println("User tried to delete but doesn't have admin role:", adminUser.Role)
-		return c.Status(403).JSON(fiber.Map{"error": "Admin role required to delete users"})
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
 	}
+	return nil
+}
 
-	// Get target user ID to delete
-	userID := c.Params("id")
-	fmt.Println("Attempting to delete user with ID:", userID)
-
-	// Prevent admin from deleting themselves
-	if userID == adminID {
-		return c.Status(400).JSON(fiber.Map{"error": "Cannot delete your own account"})
+// insertedIDString converts the _id returned by InsertOne to the string form used in tokens
+func insertedIDString(id interface{}) string {
+	if oid, ok := id.(primitive.ObjectID); ok {
+		return oid.Hex()
 	}
+	return fmt.Sprint(id)
+}
 
-	var deleteResult *mongo.DeleteResult
+// findUserByID looks a user up by ObjectID first, then by string ID.
+// It ignores workspaces; lookups of other users on behalf of a caller use findWorkspaceUser.
+func findUserByID(ctx context.Context, userID string) (models.User, error) {
+	var user models.User
 
-	// Try with ObjectID first
 	objectID, err := primitive.ObjectIDFromHex(userID)
 	if err == nil {
-		deleteResult, err = config.UserCollectionRef.DeleteOne(ctx, bson.M{"_id": objectID})
-		if err == nil && deleteResult.DeletedCount > 0 {
-			fmt.Println("Deleted user with ObjectID successfully")
-			return c.JSON(fiber.Map{"message": "User deleted successfully"})
+		err = config.UserCollectionRef.FindOne(ctx, bson.M{"_id": objectID}).Decode(&user)
+		if err == nil {
+			return user, nil
 		}
 	}
 
-	// Fall back to string ID
-	deleteResult, err = config.UserCollectionRef.DeleteOne(ctx, bson.M{"_id": userID})
-	if err != nil {
-		fmt.Println("Error deleting user:", err)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete user"})
-	}
-
-	if deleteResult.DeletedCount == 0 {
-		fmt.Println("No user found with ID:", userID)
-		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
-	}
-
-	fmt.Println("Deleted user with string ID successfully")
-	return c.JSON(fiber.Map{"message": "User deleted successfully"})
+	err = config.UserCollectionRef.FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+	return user, err
 }
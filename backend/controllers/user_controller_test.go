@@ -0,0 +1,54 @@
+package controllers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"backend/middleware"
+	"backend/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// The picture is only set through the upload endpoint, which stores files it produced itself
+func TestUpdateUserIgnoresProfileImage(t *testing.T) {
+	app := newTestApp(t)
+	user := seedUser(t, models.User{Nama: "Ana", Email: "ana@example.com", ProfileImage: "/uploads/ana.png"})
+
+	status, _ := call(t, app, http.MethodPut, "/api/users/"+user.ID, tokenFor(t, user), map[string]interface{}{
+		"bio":          "Hello",
+		"profileImage": "https://evil.example.com/a.png",
+		"avatars":      map[string]string{"64": "https://evil.example.com/a.png"},
+	})
+	if status != fiber.StatusOK {
+		t.Fatalf("status %d, want 200", status)
+	}
+	stored := findUser(t, user.ID)
+	if stored.Bio != "Hello" || stored.ProfileImage != "/uploads/ana.png" || stored.Avatars != nil {
+		t.Fatalf("stored %+v", stored)
+	}
+}
+
+// A misspelt role would silently get the default role's permissions
+func TestUnknownRolesAreRejected(t *testing.T) {
+	app := newTestApp(t)
+	admin := seedUser(t, models.User{Nama: "Admin", Email: "admin@example.com", Role: middleware.RoleAdmin})
+	user := seedUser(t, models.User{Nama: "Ana", Email: "ana@example.com"})
+	token := tokenFor(t, admin)
+
+	status, _ := call(t, app, http.MethodPost, "/api/users", token, map[string]string{
+		"nama": "Budi", "email": "budi@example.com", "password": testPassword, "role": "admin",
+	})
+	if status != fiber.StatusBadRequest {
+		t.Fatalf("create: status %d, want 400", status)
+	}
+	if status, _ := call(t, app, http.MethodPut, "/api/users/"+user.ID, token, map[string]string{"role": "admin"}); status != fiber.StatusBadRequest {
+		t.Fatalf("update: status %d, want 400", status)
+	}
+	if stored := findUser(t, user.ID); stored.Role != middleware.DefaultRole {
+		t.Fatalf("role %q stored", stored.Role)
+	}
+	if status, _ := call(t, app, http.MethodPut, "/api/users/"+user.ID, token, map[string]string{"role": "Team Leader"}); status != fiber.StatusOK {
+		t.Fatalf("known role: status %d, want 200", status)
+	}
+}
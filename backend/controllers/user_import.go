@@ -0,0 +1,547 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"backend/audit"
+	"backend/config"
+	"backend/middleware"
+	"backend/models"
+	"backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// How imported accounts are set up
+const (
+	// ImportModeInvite emails an invitation; the account is created when it is accepted
+	ImportModeInvite = "invite"
+	// ImportModePassword creates the account with a temporary password that must be changed
+	ImportModePassword = "password"
+)
+
+// What happens to rows whose email is already registered
+const (
+	ImportDuplicateSkip   = "skip"
+	ImportDuplicateUpdate = "update"
+)
+
+// Row results
+const (
+	ImportRowCreated = "created"
+	ImportRowInvited = "invited"
+	ImportRowUpdated = "updated"
+	ImportRowSkipped = "skipped"
+	ImportRowFailed  = "failed"
+)
+
+// importColumns are the CSV columns, matched case-insensitively against the header
+var importColumns = map[string]string{
+	"name":  "name",
+	"nama":  "name",
+	"email": "email",
+	"role":  "role",
+	"bio":   "bio",
+	"team":  "team",
+}
+
+// ImportOptions controls a user import
+type ImportOptions struct {
+	DryRun      bool
+	Mode        string
+	OnDuplicate string
+	WorkspaceID string
+	// ActorID is recorded as the inviter
+	ActorID string
+	// AllowRoles lets rows assign roles other than the default one, or change the role of existing users
+	AllowRoles bool
+	// AllowUpdates lets OnDuplicate=update change existing users
+	AllowUpdates bool
+}
+
+// ImportRowResult is what happened to one CSV row. Row is the line number, the header being line 1.
+type ImportRowResult struct {
+	Row               int      `json:"row"`
+	Email             string   `json:"email,omitempty"`
+	Result            string   `json:"result"`
+	UserID            string   `json:"userId,omitempty"`
+	TemporaryPassword string   `json:"temporaryPassword,omitempty"`
+	Errors            []string `json:"errors,omitempty"`
+}
+
+// ImportReport summarises an import. In a dry run the results say what would have happened.
+type ImportReport struct {
+	DryRun      bool              `json:"dryRun"`
+	Mode        string            `json:"mode"`
+	OnDuplicate string            `json:"onDuplicate"`
+	Total       int               `json:"total"`
+	Created     int               `json:"created"`
+	Invited     int               `json:"invited"`
+	Updated     int               `json:"updated"`
+	Skipped     int               `json:"skipped"`
+	Failed      int               `json:"failed"`
+	Rows        []ImportRowResult `json:"rows"`
+}
+
+// importRow is a parsed CSV row
+type importRow struct {
+	line  int
+	name  string
+	email string
+	role  string
+	bio   string
+	team  string
+}
+
+// ImportUsers godoc
+//
+//	@Summary		Import users from CSV
+//	@Description	Create or invite users in bulk from a CSV with the columns name, email, role, bio and team (a team name in the workspace). Every row is validated and reported on its own. With dryRun=true nothing is written.
+//	@Tags			Admin
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			file		formData	file				true	"CSV file; a text/csv request body works too"
+//	@Param			dryRun		query		bool				false	"Only validate and report"
+//	@Param			mode		query		string				false	"How new accounts are set up"	Enums(invite, password)
+//	@Param			onDuplicate	query		string				false	"What to do with registered emails; update needs users:update:any"	Enums(skip, update)
+//	@Param			workspaceId	query		string				false	"Target workspace (default the caller's)"
+//	@Success		200			{object}	ImportReport		"Per-row report"
+//	@Failure		400			{object}	map[string]string	"Unreadable CSV or invalid options"
+//	@Failure		403			{object}	map[string]string	"Forbidden"
+//	@Failure		500			{object}	map[string]string	"Internal server error"
+//	@Router			/api/admin/users/import [post]
+func ImportUsers(c *fiber.Ctx) error {
+	opts := ImportOptions{
+		DryRun:       c.QueryBool("dryRun"),
+		Mode:         c.Query("mode", ImportModeInvite),
+		OnDuplicate:  c.Query("onDuplicate", ImportDuplicateSkip),
+		WorkspaceID:  c.Query("workspaceId", middleware.CurrentWorkspaceID(c)),
+		ActorID:      middleware.CurrentUserID(c),
+		AllowRoles:   middleware.Can(c, middleware.PermUsersManageRoles),
+		AllowUpdates: middleware.Can(c, middleware.PermUsersUpdateAny),
+	}
+	if opts.OnDuplicate == ImportDuplicateUpdate && !opts.AllowUpdates {
+		return c.Status(403).JSON(fiber.Map{"error": "Only administrators can update existing users"})
+	}
+	if opts.WorkspaceID != middleware.CurrentWorkspaceID(c) && !middleware.Can(c, middleware.PermWorkspacesManage) {
+		return c.Status(403).JSON(fiber.Map{"error": "You can only import users into your own workspace"})
+	}
+
+	var data []byte
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Failed to read the uploaded file"})
+		}
+		defer f.Close()
+		if data, err = io.ReadAll(f); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Failed to read the uploaded file"})
+		}
+	} else {
+		data = c.Body()
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "A CSV file is required"})
+	}
+
+	// Invitations and emails for a large file take longer than a normal request
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	report, err := RunUserImport(ctx, bytes.NewReader(data), opts)
+	if err != nil {
+		if errors.Is(err, errInvalidImport) {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Import failed: " + err.Error()})
+	}
+
+	if !opts.DryRun {
+		audit.RecordRequest(c, models.AuditLog{
+			Action:  models.AuditUsersImport,
+			ActorID: opts.ActorID,
+			Details: map[string]interface{}{
+				"workspaceId": opts.WorkspaceID,
+				"mode":        opts.Mode,
+				"total":       report.Total,
+				"created":     report.Created,
+				"invited":     report.Invited,
+				"updated":     report.Updated,
+				"failed":      report.Failed,
+			},
+		})
+	}
+
+	return c.JSON(report)
+}
+
+// errInvalidImport wraps problems with the file or options as a whole, as opposed to single rows
+var errInvalidImport = errors.New("invalid import")
+
+// RunUserImport reads a CSV of users and creates, invites or updates them row by row.
+// A bad row is reported and skipped; only an unreadable file or invalid options fail the whole import.
+func RunUserImport(ctx context.Context, r io.Reader, opts ImportOptions) (ImportReport, error) {
+	report := ImportReport{DryRun: opts.DryRun, Mode: opts.Mode, OnDuplicate: opts.OnDuplicate, Rows: []ImportRowResult{}}
+
+	if opts.Mode != ImportModeInvite && opts.Mode != ImportModePassword {
+		return report, fmt.Errorf("%w: mode must be invite or password", errInvalidImport)
+	}
+	if opts.OnDuplicate != ImportDuplicateSkip && opts.OnDuplicate != ImportDuplicateUpdate {
+		return report, fmt.Errorf("%w: onDuplicate must be skip or update", errInvalidImport)
+	}
+	if opts.OnDuplicate == ImportDuplicateUpdate && !opts.AllowUpdates {
+		return report, fmt.Errorf("%w: updating existing users is not allowed", errInvalidImport)
+	}
+	opts.WorkspaceID = models.WorkspaceOrDefault(opts.WorkspaceID)
+
+	rows, err := readImportRows(r)
+	if err != nil {
+		return report, err
+	}
+
+	importer := userImporter{opts: opts, teams: map[string]*models.Team{}, seen: map[string]int{}}
+	for _, row := range rows {
+		result := importer.importRow(ctx, row)
+		report.Rows = append(report.Rows, result)
+		switch result.Result {
+		case ImportRowCreated:
+			report.Created++
+		case ImportRowInvited:
+			report.Invited++
+		case ImportRowUpdated:
+			report.Updated++
+		case ImportRowSkipped:
+			report.Skipped++
+		default:
+			report.Failed++
+		}
+	}
+	report.Total = len(rows)
+	return report, nil
+}
+
+// readImportRows parses the CSV, mapping columns by the header
+func readImportRows(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot read the CSV header: %v", errInvalidImport, err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		// Spreadsheet exports like to start with a byte order mark
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if column, ok := importColumns[name]; ok {
+			columns[column] = i
+		}
+	}
+	for _, required := range []string{"name", "email"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: the CSV needs a %s column", errInvalidImport, required)
+		}
+	}
+
+	maxRows := utils.IntFromEnv("IMPORT_MAX_ROWS", 1000)
+	field := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", errInvalidImport, line, err)
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if len(rows) == maxRows {
+			return nil, fmt.Errorf("%w: at most %d rows can be imported at once", errInvalidImport, maxRows)
+		}
+		rows = append(rows, importRow{
+			line:  line,
+			name:  field(record, "name"),
+			email: strings.ToLower(field(record, "email")),
+			role:  field(record, "role"),
+			bio:   field(record, "bio"),
+			team:  field(record, "team"),
+		})
+	}
+	return rows, nil
+}
+
+// userImporter carries state between the rows of one import
+type userImporter struct {
+	opts ImportOptions
+	// teams caches team lookups by lower-cased name; nil means there is no such team
+	teams map[string]*models.Team
+	// seen maps emails to the line they first appeared on
+	seen map[string]int
+}
+
+func (im *userImporter) importRow(ctx context.Context, row importRow) ImportRowResult {
+	result := ImportRowResult{Row: row.line, Email: row.email, Result: ImportRowFailed}
+
+	team, problems, err := im.validate(ctx, row)
+	if err != nil {
+		result.Errors = []string{"Failed to validate row: " + err.Error()}
+		return result
+	}
+	if len(problems) > 0 {
+		result.Errors = problems
+		return result
+	}
+
+	var existing models.User
+	err = config.UserCollectionRef.FindOne(ctx, emailFilter(row.email)).Decode(&existing)
+	switch {
+	case err == nil:
+		return im.importExisting(ctx, row, team, existing, result)
+	case err == mongo.ErrNoDocuments:
+		return im.importNew(ctx, row, team, result)
+	default:
+		result.Errors = []string{"Failed to check email: " + err.Error()}
+		return result
+	}
+}
+
+// validate checks a row on its own and against the rows before it
+func (im *userImporter) validate(ctx context.Context, row importRow) (*models.Team, []string, error) {
+	var problems []string
+
+	if row.name == "" {
+		problems = append(problems, "name is required")
+	} else if len(row.name) > 100 {
+		problems = append(problems, "name must be at most 100 characters")
+	}
+
+	if row.email == "" {
+		problems = append(problems, "email is required")
+	} else if address, err := mail.ParseAddress(row.email); err != nil || address.Address != row.email {
+		problems = append(problems, "email is not a valid address")
+	} else if first, ok := im.seen[row.email]; ok {
+		problems = append(problems, fmt.Sprintf("email already appears on line %d", first))
+	} else {
+		im.seen[row.email] = row.line
+	}
+
+	if row.role != "" && !middleware.IsKnownRole(row.role) {
+		problems = append(problems, "unknown role "+row.role)
+	}
+
+	if len(row.bio) > 500 {
+		problems = append(problems, "bio must be at most 500 characters")
+	}
+
+	var team *models.Team
+	if row.team != "" {
+		var err error
+		team, err = im.findTeam(ctx, row.team)
+		if err != nil {
+			return nil, nil, err
+		}
+		if team == nil {
+			problems = append(problems, "unknown team "+row.team)
+		}
+	}
+
+	return team, problems, nil
+}
+
+// findTeam looks a team of the target workspace up by name, ignoring case
+func (im *userImporter) findTeam(ctx context.Context, name string) (*models.Team, error) {
+	key := strings.ToLower(name)
+	if team, ok := im.teams[key]; ok {
+		return team, nil
+	}
+
+	var team models.Team
+	filter := bson.M{"name": bson.M{"$regex": "^" + regexp.QuoteMeta(name) + "$", "$options": "i"}}
+	err := config.TeamCollectionRef.FindOne(ctx, scopeToWorkspace(im.opts.WorkspaceID, filter)).Decode(&team)
+	if err == mongo.ErrNoDocuments {
+		im.teams[key] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	im.teams[key] = &team
+	return &team, nil
+}
+
+// importExisting skips or updates a row whose email is already registered
+func (im *userImporter) importExisting(ctx context.Context, row importRow, team *models.Team, user models.User, result ImportRowResult) ImportRowResult {
+	result.UserID = user.ID
+	if user.Workspace() != im.opts.WorkspaceID {
+		result.Errors = []string{"email is registered in another workspace"}
+		return result
+	}
+	if im.opts.OnDuplicate == ImportDuplicateSkip {
+		result.Result = ImportRowSkipped
+		return result
+	}
+	// Disabled accounts are restored or purged through their own endpoints, not edited
+	if user.AccountStatus == models.AccountStatusDeactivated || user.AccountStatus == models.AccountStatusDeleted {
+		result.Result = ImportRowSkipped
+		result.Errors = []string{"account is " + user.AccountStatus}
+		return result
+	}
+	if problem := im.checkRole(row.role, user.Role); problem != "" {
+		result.Errors = []string{problem}
+		return result
+	}
+
+	fields := bson.M{"nama": row.name}
+	if row.role != "" {
+		fields["role"] = row.role
+	}
+	if row.bio != "" {
+		fields["bio"] = row.bio
+	}
+
+	if !im.opts.DryRun {
+		if err := updateUserFields(ctx, user.ID, fields); err != nil {
+			result.Errors = []string{"Failed to update user: " + err.Error()}
+			return result
+		}
+		if team != nil {
+			if err := addUserToTeam(ctx, team.ID, user.ID); err != nil {
+				result.Errors = []string{"Updated, but failed to add to team: " + err.Error()}
+				return result
+			}
+		}
+	}
+	result.Result = ImportRowUpdated
+	return result
+}
+
+// checkRole reports a problem when the row would change a user's role from current
+// without AllowRoles. An empty role leaves the current one alone.
+func (im *userImporter) checkRole(role, current string) string {
+	if current == "" {
+		current = middleware.DefaultRole
+	}
+	if role == "" || role == current || im.opts.AllowRoles {
+		return ""
+	}
+	return "only administrators can assign the role " + role
+}
+
+// importNew invites or creates the account of a row
+func (im *userImporter) importNew(ctx context.Context, row importRow, team *models.Team, result ImportRowResult) ImportRowResult {
+	role := row.role
+	if role == "" {
+		role = middleware.DefaultRole
+	}
+	if problem := im.checkRole(role, middleware.DefaultRole); problem != "" {
+		result.Errors = []string{problem}
+		return result
+	}
+
+	if im.opts.Mode == ImportModeInvite {
+		if !im.opts.DryRun {
+			if err := im.invite(ctx, row, role, team); err != nil {
+				result.Errors = []string{err.Error()}
+				return result
+			}
+		}
+		result.Result = ImportRowInvited
+		return result
+	}
+
+	if im.opts.DryRun {
+		result.Result = ImportRowCreated
+		return result
+	}
+
+	password, err := utils.PasswordPolicyFromEnv().GeneratePassword()
+	if err != nil {
+		result.Errors = []string{"Failed to generate a password"}
+		return result
+	}
+
+	now := time.Now()
+	user := models.User{
+		Nama:            row.name,
+		Email:           row.email,
+		Role:            role,
+		Bio:             row.bio,
+		WorkspaceID:     im.opts.WorkspaceID,
+		AccountStatus:   models.AccountStatusActive,
+		EmailVerifiedAt: &now,
+	}
+	if _, err := applyNewPassword(&user, password); err != nil {
+		result.Errors = []string{"Failed to hash the password"}
+		return result
+	}
+	user.MustChangePassword = true
+
+	inserted, err := config.UserCollectionRef.InsertOne(ctx, user)
+	if err != nil {
+		result.Errors = []string{"Failed to save user: " + err.Error()}
+		return result
+	}
+	result.UserID = insertedIDString(inserted.InsertedID)
+	result.TemporaryPassword = password
+	result.Result = ImportRowCreated
+
+	if team != nil {
+		if err := addUserToTeam(ctx, team.ID, result.UserID); err != nil {
+			result.Errors = []string{"Created, but failed to add to team: " + err.Error()}
+		}
+	}
+	return result
+}
+
+// invite saves and emails an invitation for a row
+func (im *userImporter) invite(ctx context.Context, row importRow, role string, team *models.Team) error {
+	ttl := utils.DurationFromEnv("INVITATION_TTL", 72*time.Hour)
+	now := time.Now()
+	invitation := models.Invitation{
+		ID:          primitive.NewObjectID(),
+		Email:       row.email,
+		Nama:        row.name,
+		Role:        role,
+		WorkspaceID: im.opts.WorkspaceID,
+		Bio:         row.bio,
+		Status:      models.InvitationPending,
+		InvitedBy:   im.opts.ActorID,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+	if team != nil {
+		invitation.TeamID = &team.ID
+	}
+
+	if err := saveInvitation(ctx, invitation); err != nil {
+		return fmt.Errorf("Failed to save invitation: %v", err)
+	}
+	if err := sendInvitationEmail(ctx, invitation, ttl); err != nil {
+		return fmt.Errorf("Invitation saved but the email could not be sent: %v", err)
+	}
+	return nil
+}
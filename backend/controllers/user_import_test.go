@@ -0,0 +1,47 @@
+package controllers_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"backend/controllers"
+	"backend/middleware"
+	"backend/models"
+)
+
+func TestImportUpdatesNeedRights(t *testing.T) {
+	newTestApp(t)
+	admin := seedUser(t, models.User{Nama: "Admin", Email: "admin@example.com", Role: middleware.RoleAdmin})
+	gone := seedUser(t, models.User{Nama: "Gone", Email: "gone@example.com", AccountStatus: models.AccountStatusDeactivated})
+	ctx := context.Background()
+
+	csv := "name,email,role\nDemoted,admin@example.com,Team Member\nBack,gone@example.com,\n"
+	opts := controllers.ImportOptions{Mode: controllers.ImportModeInvite, OnDuplicate: controllers.ImportDuplicateUpdate}
+
+	if _, err := controllers.RunUserImport(ctx, strings.NewReader(csv), opts); err == nil {
+		t.Fatal("update without AllowUpdates accepted")
+	}
+
+	// Without AllowRoles nobody's role changes, not even to the default one
+	opts.AllowUpdates = true
+	report, err := controllers.RunUserImport(ctx, strings.NewReader(csv), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Failed != 1 || report.Skipped != 1 || report.Updated != 0 {
+		t.Fatalf("report %+v", report)
+	}
+	if stored := findUser(t, admin.ID); stored.Role != middleware.RoleAdmin || stored.Nama != "Admin" {
+		t.Fatalf("admin changed: %+v", stored)
+	}
+	if stored := findUser(t, gone.ID); stored.Nama != "Gone" {
+		t.Fatalf("deactivated user changed: %+v", stored)
+	}
+
+	// Keeping the current role needs no role rights
+	report, err = controllers.RunUserImport(ctx, strings.NewReader("name,email,role\nBoss,admin@example.com,Admin\n"), opts)
+	if err != nil || report.Updated != 1 {
+		t.Fatalf("report %+v, %v", report, err)
+	}
+}
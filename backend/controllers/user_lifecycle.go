@@ -0,0 +1,324 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"backend/audit"
+	"backend/config"
+	"backend/middleware"
+	"backend/models"
+	"backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// errAccountDisabled is returned when a deactivated or deleted account tries to get tokens
+var errAccountDisabled = errors.New("account is disabled")
+
+const disabledAccountError = "Akun Anda telah dinonaktifkan"
+
+// rejectDisabledAccount answers a sign-in of a deactivated or deleted account
+func rejectDisabledAccount(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"error": disabledAccountError,
+		"code":  "account_disabled",
+	})
+}
+
+// DeactivateUser godoc
+//
+//	@Summary		Deactivate a user
+//	@Description	Switch an account off without deleting it: the user is signed out everywhere and can't sign in until the account is restored. Their name stays on meetings.
+//	@Tags			Admin
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			id	path		string				true	"User ID"
+//	@Success		200	{object}	map[string]string	"User deactivated"
+//	@Failure		400	{object}	map[string]string	"Cannot deactivate self"
+//	@Failure		403	{object}	map[string]string	"Forbidden"
+//	@Failure		404	{object}	map[string]string	"User not found"
+//	@Failure		409	{object}	map[string]string	"User is already deactivated or deleted"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Router			/api/admin/users/{id}/deactivate [post]
+func DeactivateUser(c *fiber.Ctx) error {
+	return disableUser(c, models.AccountStatusDeactivated, nil)
+}
+
+// DeleteUser godoc
+//
+//	@Summary		Delete user
+//	@Description	Soft-delete a user account: the user is signed out everywhere, hidden from listings and purged for good after USER_RETENTION (default 30 days). Until then the account can be restored and its email stays taken. The optional policy cleans up their meetings and profile image; the cleanup report is returned and audited.
+//	@Tags			Users
+//	@Accept			json
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			id		path		string					true	"User ID"
+//	@Param			policy	body		UserDeletionPolicy		false	"What to do with the user's meetings and files"
+//	@Success		200		{object}	map[string]interface{}	"User deleted; purgeAfter says when it is removed for good, cleanup what the policy did"
+//	@Failure		400		{object}	map[string]string		"Bad request - Cannot delete self or invalid policy"
+//	@Failure		403		{object}	map[string]string		"Forbidden - users:delete permission required"
+//	@Failure		404		{object}	map[string]string		"User not found"
+//	@Failure		409		{object}	map[string]string		"User is already deleted"
+//	@Failure		500		{object}	map[string]string		"Internal server error"
+//	@Router			/api/users/{id} [delete]
+func DeleteUser(c *fiber.Ctx) error {
+	var policy UserDeletionPolicy
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&policy); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid deletion policy"})
+		}
+	}
+	return disableUser(c, models.AccountStatusDeleted, &policy)
+}
+
+// disableUser deactivates or soft-deletes the user in :id and signs them out everywhere.
+// Deletions come with a policy for cleaning up after the user.
+func disableUser(c *fiber.Ctx, status string, policy *UserDeletionPolicy) error {
+	userID := c.Params("id")
+	if userID == middleware.CurrentUserID(c) {
+		if status == models.AccountStatusDeleted {
+			return c.Status(400).JSON(fiber.Map{"error": "Cannot delete your own account"})
+		}
+		return c.Status(400).JSON(fiber.Map{"error": "Cannot deactivate your own account"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := findWorkspaceUser(ctx, c, userID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch user"})
+	}
+
+	// Deactivated accounts can still be deleted; the other way round makes no sense
+	switch {
+	case user.AccountStatus == models.AccountStatusDeleted:
+		return c.Status(409).JSON(fiber.Map{"error": "User is already deleted"})
+	case user.AccountStatus == status:
+		return c.Status(409).JSON(fiber.Map{"error": "User is already deactivated"})
+	}
+	if policy != nil {
+		if err := checkDeletionPolicy(ctx, c, user, *policy); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	now := time.Now()
+	fields := bson.M{"accountStatus": status}
+	action := models.AuditUserDeactivate
+	if status == models.AccountStatusDeleted {
+		fields["deletedAt"] = now
+		action = models.AuditUserDelete
+	} else {
+		fields["deactivatedAt"] = now
+	}
+	if err := updateUserFields(ctx, user.ID, fields); err != nil {
+		fmt.Println("Error disabling user:", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update user"})
+	}
+
+	// Make sure the user is logged out everywhere
+	if err := revokeUserSessions(ctx, user.ID); err != nil {
+		fmt.Println("Error revoking sessions:", err)
+	}
+	if err := revokeUserAccessTokens(ctx, user.ID); err != nil {
+		fmt.Println("Error revoking access tokens:", err)
+	}
+
+	entry := models.AuditLog{
+		Action:    action,
+		ActorID:   middleware.CurrentUserID(c),
+		SubjectID: user.ID,
+	}
+	if policy == nil {
+		audit.RecordRequest(c, entry)
+		fmt.Println("Deactivated user:", user.ID)
+		return c.JSON(fiber.Map{"message": "User deactivated"})
+	}
+
+	report := cleanUpDeletedUser(ctx, user, *policy)
+	entry.Details = map[string]interface{}{"policy": *policy, "cleanup": report}
+	audit.RecordRequest(c, entry)
+
+	fmt.Println("Soft-deleted user:", user.ID)
+	return c.JSON(fiber.Map{
+		"message":    "User deleted successfully",
+		"purgeAfter": now.Add(userRetention()),
+		"cleanup":    report,
+	})
+}
+
+// RestoreUser godoc
+//
+//	@Summary		Restore a user
+//	@Description	Reactivate a deactivated or soft-deleted account. Deleted accounts can only be restored until they are purged.
+//	@Tags			Admin
+//	@Produce		json
+//	@Security		Bearer
+//	@Param			id	path		string				true	"User ID"
+//	@Success		200	{object}	map[string]string	"User restored"
+//	@Failure		403	{object}	map[string]string	"Forbidden"
+//	@Failure		404	{object}	map[string]string	"User not found"
+//	@Failure		409	{object}	map[string]string	"User is not deactivated or deleted"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Router			/api/admin/users/{id}/restore [post]
+func RestoreUser(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := findWorkspaceUser(ctx, c, c.Params("id"))
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch user"})
+	}
+	if !user.IsDisabled() {
+		return c.Status(409).JSON(fiber.Map{"error": "User is not deactivated or deleted"})
+	}
+
+	result, err := config.UserCollectionRef.UpdateOne(ctx, models.UserIDFilter(user.ID), bson.M{
+		"$set":   bson.M{"accountStatus": models.AccountStatusActive},
+		"$unset": bson.M{"deactivatedAt": "", "deletedAt": ""},
+	})
+	if err != nil || result.MatchedCount == 0 {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to restore user"})
+	}
+
+	audit.RecordRequest(c, models.AuditLog{
+		Action:    models.AuditUserRestore,
+		ActorID:   middleware.CurrentUserID(c),
+		SubjectID: user.ID,
+		Details:   map[string]interface{}{"from": user.AccountStatus},
+	})
+
+	fmt.Println("Restored user:", user.ID)
+	return c.JSON(fiber.Map{"message": "User restored"})
+}
+
+// userRetention is how long soft-deleted accounts are kept before the purge removes them
+func userRetention() time.Duration {
+	return utils.DurationFromEnv("USER_RETENTION", 30*24*time.Hour)
+}
+
+// StartUserPurge purges soft-deleted accounts now and then every USER_PURGE_INTERVAL
+// (default an hour). It returns at once; the purge runs in the background.
+func StartUserPurge() {
+	interval := utils.DurationFromEnv("USER_PURGE_INTERVAL", time.Hour)
+	go func() {
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			purged, err := PurgeDeletedUsers(ctx, time.Now())
+			cancel()
+			if err != nil {
+				fmt.Println("Error purging deleted users:", err)
+			} else if purged > 0 {
+				fmt.Println("Purged deleted users:", purged)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// PurgeDeletedUsers hard-deletes accounts that were soft-deleted more than USER_RETENTION
+// ago. Each one leaves a models.DeletedUser behind so meetings can still show its name.
+func PurgeDeletedUsers(ctx context.Context, now time.Time) (int, error) {
+	cursor, err := config.UserCollectionRef.Find(ctx, bson.M{
+		"accountStatus": models.AccountStatusDeleted,
+		"deletedAt":     bson.M{"$lte": now.Add(-userRetention())},
+	}, options.Find().SetProjection(bson.M{"_id": 1, "nama": 1, "workspaceId": 1, "deletedAt": 1}))
+	if err != nil {
+		return 0, err
+	}
+
+	// The raw _id is kept: meetings refer to users by ObjectID, older users have string IDs
+	var doomed []models.DeletedUser
+	if err := cursor.All(ctx, &doomed); err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, user := range doomed {
+		userID := insertedIDString(user.ID)
+		user.PurgedAt = now
+		_, err := config.DeletedUserCollectionRef.ReplaceOne(ctx, bson.M{"_id": user.ID}, user, options.Replace().SetUpsert(true))
+		if err != nil {
+			return purged, err
+		}
+		if err := removeUserFromTeams(ctx, userID); err != nil {
+			return purged, err
+		}
+		if err := deleteUserCredentials(ctx, userID); err != nil {
+			return purged, err
+		}
+		// Only delete what is still deleted, in case it was restored in the meantime
+		result, err := config.UserCollectionRef.DeleteOne(ctx, bson.M{"_id": user.ID, "accountStatus": models.AccountStatusDeleted})
+		if err != nil {
+			return purged, err
+		}
+		if result.DeletedCount == 0 {
+			continue
+		}
+		purged++
+
+		audit.Record(ctx, models.AuditLog{
+			Action:    models.AuditUserPurge,
+			ActorID:   "system",
+			SubjectID: userID,
+		})
+	}
+	return purged, nil
+}
+
+// deleteUserCredentials removes the sessions, refresh tokens, personal access tokens and
+// emailed one-time tokens of a purged user. Revoked access tokens expire on their own.
+func deleteUserCredentials(ctx context.Context, userID string) error {
+	for _, collection := range []*mongo.Collection{
+		config.SessionCollectionRef,
+		config.RefreshTokenCollectionRef,
+		config.AccessTokenCollectionRef,
+		config.OneTimeTokenCollectionRef,
+	} {
+		if _, err := collection.DeleteMany(ctx, bson.M{"userId": userID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notDeletedFilter leaves out soft-deleted accounts
+func notDeletedFilter() bson.M {
+	return bson.M{"accountStatus": bson.M{"$ne": models.AccountStatusDeleted}}
+}
+
+// deletedUserStandIn is what a meeting shows for a user who no longer exists:
+// the name they had if they were purged from the meeting's workspace, "Unknown User" otherwise
+func deletedUserStandIn(ctx context.Context, workspaceID string, userID interface{}) models.User {
+	var tombstone models.DeletedUser
+	err := config.DeletedUserCollectionRef.FindOne(ctx, scopeToWorkspace(workspaceID, bson.M{"_id": userID})).Decode(&tombstone)
+	if err != nil {
+		return models.User{Nama: "Unknown User"}
+	}
+	return models.User{
+		ID:            insertedIDString(tombstone.ID),
+		Nama:          tombstone.Nama,
+		AccountStatus: models.AccountStatusDeleted,
+	}
+}
+
+// visibleUserFilter hides soft-deleted accounts from callers who can't restore them
+func visibleUserFilter(c *fiber.Ctx, filter bson.M) bson.M {
+	if middleware.Can(c, middleware.PermUsersDelete) {
+		return filter
+	}
+	return bson.M{"$and": []bson.M{filter, notDeletedFilter()}}
+}
@@ -0,0 +1,54 @@
+package controllers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"backend/config"
+	"backend/controllers"
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestPurgeDeletedUsersRemovesCredentials(t *testing.T) {
+	newTestApp(t)
+	ctx := context.Background()
+	longAgo := time.Now().Add(-365 * 24 * time.Hour)
+	gone := seedUser(t, models.User{Nama: "Gone", Email: "gone@example.com", AccountStatus: models.AccountStatusDeleted, DeletedAt: &longAgo})
+	kept := seedUser(t, models.User{Nama: "Ana", Email: "ana@example.com"})
+
+	collections := map[string]*mongo.Collection{
+		"sessions":               config.SessionCollectionRef,
+		"refresh tokens":         config.RefreshTokenCollectionRef,
+		"personal access tokens": config.AccessTokenCollectionRef,
+		"one-time tokens":        config.OneTimeTokenCollectionRef,
+	}
+	for _, collection := range collections {
+		for _, user := range []models.User{gone, kept} {
+			// tokenHash is unique in the token collections
+			if _, err := collection.InsertOne(ctx, bson.M{"userId": user.ID, "tokenHash": primitive.NewObjectID().Hex()}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	purged, err := controllers.PurgeDeletedUsers(ctx, time.Now())
+	if err != nil || purged != 1 {
+		t.Fatalf("purged %d, %v", purged, err)
+	}
+	for name, collection := range collections {
+		for user, want := range map[string]int64{gone.ID: 0, kept.ID: 1} {
+			count, err := collection.CountDocuments(ctx, bson.M{"userId": user})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != want {
+				t.Fatalf("%s of %s: %d left, want %d", name, user, count, want)
+			}
+		}
+	}
+}
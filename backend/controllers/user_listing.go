@@ -0,0 +1,326 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"backend/config"
+	"backend/middleware"
+	"backend/models"
+	"backend/presence"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Page sizes of user listings
+const (
+	defaultUserPageSize = 25
+	maxUserPageSize     = 100
+)
+
+// userSortFields maps the ?sort= values to document fields
+var userSortFields = map[string]string{
+	"name":       "nama",
+	"email":      "email",
+	"lastActive": "lastActive",
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// userListQuery is a parsed user listing request
+type userListQuery struct {
+	Filter bson.M
+	Field  string
+	Desc   bool
+	Limit  int
+	After  *userCursor
+}
+
+// userCursor points just past the last user of a page: its sort value and _id
+type userCursor struct {
+	Field    string      `json:"f"`
+	Desc     bool        `json:"d,omitempty"`
+	Value    interface{} `json:"v"`
+	Time     bool        `json:"t,omitempty"`
+	ID       string      `json:"id"`
+	ObjectID bool        `json:"oid,omitempty"`
+}
+
+// userPage is one page of a user listing
+type userPage struct {
+	Users      []models.User
+	NextCursor string
+	Total      int64
+}
+
+// parseUserListQuery reads sort, order, limit, cursor, role, status, accountStatus and q
+func parseUserListQuery(c *fiber.Ctx) (userListQuery, error) {
+	query := userListQuery{Filter: bson.M{}, Field: "nama", Limit: defaultUserPageSize}
+
+	if sort := c.Query("sort"); sort != "" {
+		field, ok := userSortFields[sort]
+		if !ok {
+			return query, errors.New("sort must be one of name, email, lastActive")
+		}
+		query.Field = field
+	}
+	switch c.Query("order", "asc") {
+	case "asc":
+	case "desc":
+		query.Desc = true
+	default:
+		return query, errors.New("order must be asc or desc")
+	}
+
+	if limit := c.QueryInt("limit", defaultUserPageSize); limit > 0 {
+		query.Limit = limit
+	}
+	if query.Limit > maxUserPageSize {
+		query.Limit = maxUserPageSize
+	}
+
+	var and []bson.M
+	if roles := splitQueryList(c.Query("role")); len(roles) > 0 {
+		roleFilter := bson.M{"role": bson.M{"$in": roles}}
+		// Users without a role have the default one
+		for _, role := range roles {
+			if role == middleware.DefaultRole {
+				roleFilter = bson.M{"$or": []bson.M{roleFilter, {"role": bson.M{"$in": []interface{}{nil, ""}}}}}
+				break
+			}
+		}
+		and = append(and, roleFilter)
+	}
+	if statuses := splitQueryList(c.Query("status")); len(statuses) > 0 {
+		and = append(and, statusFilter(statuses, time.Now()))
+	}
+	if states := splitQueryList(c.Query("accountStatus")); len(states) > 0 {
+		stateFilter := bson.M{"accountStatus": bson.M{"$in": states}}
+		for _, state := range states {
+			// Deleted accounts are only listed to those who could restore them
+			if state == models.AccountStatusDeleted && !middleware.Can(c, middleware.PermUsersDelete) {
+				return query, errors.New("listing deleted users requires the users:delete permission")
+			}
+		}
+		// Accounts from before email verification have no state and count as active
+		for _, state := range states {
+			if state == models.AccountStatusActive {
+				stateFilter = bson.M{"$or": []bson.M{stateFilter, {"accountStatus": bson.M{"$in": []interface{}{nil, ""}}}}}
+				break
+			}
+		}
+		and = append(and, stateFilter)
+	} else {
+		and = append(and, notDeletedFilter())
+	}
+	if search := strings.TrimSpace(c.Query("q")); search != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(search), Options: "i"}
+		and = append(and, bson.M{"$or": []bson.M{{"nama": pattern}, {"email": pattern}}})
+	}
+	if len(and) > 0 {
+		query.Filter["$and"] = and
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := decodeUserCursor(raw)
+		if err != nil {
+			return query, err
+		}
+		// A cursor only makes sense in the order it was made for
+		if cursor.Field != query.Field || cursor.Desc != query.Desc {
+			return query, errInvalidCursor
+		}
+		query.After = cursor
+	}
+
+	return query, nil
+}
+
+// findUserPage runs a listing query with keyset pagination on (sort field, _id)
+func findUserPage(ctx context.Context, query userListQuery) (userPage, error) {
+	var page userPage
+
+	total, err := config.UserCollectionRef.CountDocuments(ctx, query.Filter,
+		options.Count().SetCollation(config.UserListCollation))
+	if err != nil {
+		return page, err
+	}
+	page.Total = total
+
+	filter := query.Filter
+	if query.After != nil {
+		filter = bson.M{"$and": []bson.M{query.Filter, query.After.filter(query.Field, query.Desc)}}
+	}
+
+	direction := 1
+	if query.Desc {
+		direction = -1
+	}
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: query.Field, Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(query.Limit + 1)).
+		SetCollation(config.UserListCollation)
+
+	cursor, err := config.UserCollectionRef.Find(ctx, filter, findOptions)
+	if err != nil {
+		return page, err
+	}
+	defer cursor.Close(ctx)
+
+	var raws []bson.Raw
+	if err := cursor.All(ctx, &raws); err != nil {
+		return page, err
+	}
+
+	// One extra document tells us whether there is a next page
+	hasMore := len(raws) > query.Limit
+	if hasMore {
+		raws = raws[:query.Limit]
+	}
+
+	page.Users = make([]models.User, 0, len(raws))
+	for _, raw := range raws {
+		var user models.User
+		if err := bson.Unmarshal(raw, &user); err != nil {
+			return page, err
+		}
+		page.Users = append(page.Users, user)
+	}
+
+	if hasMore {
+		next, err := newUserCursor(raws[len(raws)-1], query.Field, query.Desc)
+		if err != nil {
+			return page, err
+		}
+		page.NextCursor = next
+	}
+	return page, nil
+}
+
+// newUserCursor encodes the position of a document, keeping the BSON types of its values
+func newUserCursor(raw bson.Raw, field string, desc bool) (string, error) {
+	cursor := userCursor{Field: field, Desc: desc}
+
+	switch id := raw.Lookup("_id"); id.Type {
+	case bson.TypeObjectID:
+		cursor.ID = id.ObjectID().Hex()
+		cursor.ObjectID = true
+	default:
+		cursor.ID = id.StringValue()
+	}
+
+	switch value := raw.Lookup(field); value.Type {
+	case bson.TypeDateTime:
+		cursor.Value = value.Time().UTC().Format(time.RFC3339Nano)
+		cursor.Time = true
+	case bson.TypeString:
+		cursor.Value = value.StringValue()
+	}
+
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeUserCursor(raw string) (*userCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var cursor userCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return nil, errInvalidCursor
+	}
+	if cursor.Value != nil {
+		text, ok := cursor.Value.(string)
+		if !ok {
+			return nil, errInvalidCursor
+		}
+		if cursor.Time {
+			t, err := time.Parse(time.RFC3339Nano, text)
+			if err != nil {
+				return nil, errInvalidCursor
+			}
+			cursor.Value = t
+		}
+	}
+	return &cursor, nil
+}
+
+// filter matches the documents that come after the cursor in the listing order.
+// Missing values sort before everything else, as they do in MongoDB.
+func (cursor userCursor) filter(field string, desc bool) bson.M {
+	var id interface{} = cursor.ID
+	if cursor.ObjectID {
+		if objectID, err := primitive.ObjectIDFromHex(cursor.ID); err == nil {
+			id = objectID
+		}
+	}
+
+	after := "$gt"
+	if desc {
+		after = "$lt"
+	}
+
+	if cursor.Value == nil {
+		sameValue := bson.M{field: nil, "_id": bson.M{after: id}}
+		if desc {
+			// Nothing comes after the missing values when sorting descending
+			return sameValue
+		}
+		return bson.M{"$or": []bson.M{sameValue, {field: bson.M{"$ne": nil}}}}
+	}
+
+	or := []bson.M{
+		{field: bson.M{after: cursor.Value}},
+		{field: cursor.Value, "_id": bson.M{after: id}},
+	}
+	if desc {
+		or = append(or, bson.M{field: nil})
+	}
+	return bson.M{"$or": or}
+}
+
+// statusFilter matches presence states (online, away, offline) and unexpired custom statuses
+func statusFilter(statuses []string, now time.Time) bson.M {
+	var or []bson.M
+	var custom []string
+	for _, status := range statuses {
+		if presence.IsState(status) {
+			or = append(or, presence.StateFilter(status, now))
+		} else {
+			custom = append(custom, status)
+		}
+	}
+	if len(custom) > 0 {
+		or = append(or, bson.M{
+			"customStatus.status": bson.M{"$in": custom},
+			"$or": []bson.M{
+				{"customStatus.expiresAt": nil},
+				{"customStatus.expiresAt": bson.M{"$gt": now}},
+			},
+		})
+	}
+	return bson.M{"$or": or}
+}
+
+// splitQueryList splits a comma separated query parameter
+func splitQueryList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
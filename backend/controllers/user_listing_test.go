@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestUserCursorRoundTrip(t *testing.T) {
+	objectID := primitive.NewObjectID()
+	lastActive := time.Date(2026, 3, 4, 5, 6, 7, 89e6, time.UTC)
+	tests := []struct {
+		name  string
+		doc   bson.M
+		field string
+		desc  bool
+		want  userCursor
+	}{
+		{
+			"string value", bson.M{"_id": objectID, "nama": "Ana"}, "nama", false,
+			userCursor{Field: "nama", Value: "Ana", ID: objectID.Hex(), ObjectID: true},
+		},
+		{
+			"time value", bson.M{"_id": objectID, "lastActive": lastActive}, "lastActive", true,
+			userCursor{Field: "lastActive", Desc: true, Value: lastActive, Time: true, ID: objectID.Hex(), ObjectID: true},
+		},
+		{
+			"missing value", bson.M{"_id": objectID}, "lastActive", false,
+			userCursor{Field: "lastActive", ID: objectID.Hex(), ObjectID: true},
+		},
+		{
+			"null value", bson.M{"_id": objectID, "email": nil}, "email", true,
+			userCursor{Field: "email", Desc: true, ID: objectID.Hex(), ObjectID: true},
+		},
+		{
+			"string id", bson.M{"_id": "legacy-id", "email": "ana@example.com"}, "email", false,
+			userCursor{Field: "email", Value: "ana@example.com", ID: "legacy-id"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := bson.Marshal(tt.doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoded, err := newUserCursor(raw, tt.field, tt.desc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := decodeUserCursor(encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Fatalf("decoded %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeUserCursorRejectsGarbage(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"not base64", "not a cursor!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"f":"nama","v":"Ana","id":"x"}`))},
+		{"not json", encode("nama:Ana")},
+		{"no id", encode(`{"f":"nama","v":"Ana"}`)},
+		{"number value", encode(`{"f":"nama","v":42,"id":"x"}`)},
+		{"object value", encode(`{"f":"nama","v":{"$gt":""},"id":"x"}`)},
+		{"bad time", encode(`{"f":"lastActive","v":"yesterday","t":true,"id":"x"}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if cursor, err := decodeUserCursor(tt.raw); err != errInvalidCursor {
+				t.Fatalf("decoded %+v, %v; want errInvalidCursor", cursor, err)
+			}
+		})
+	}
+}
+
+func TestUserCursorFilter(t *testing.T) {
+	objectID := primitive.NewObjectID()
+	tests := []struct {
+		name   string
+		cursor userCursor
+		desc   bool
+		want   bson.M
+	}{
+		{
+			"ascending", userCursor{Value: "Ana", ID: objectID.Hex(), ObjectID: true}, false,
+			bson.M{"$or": []bson.M{
+				{"nama": bson.M{"$gt": "Ana"}},
+				{"nama": "Ana", "_id": bson.M{"$gt": objectID}},
+			}},
+		},
+		{
+			"descending also reaches missing values", userCursor{Value: "Ana", ID: objectID.Hex(), ObjectID: true}, true,
+			bson.M{"$or": []bson.M{
+				{"nama": bson.M{"$lt": "Ana"}},
+				{"nama": "Ana", "_id": bson.M{"$lt": objectID}},
+				{"nama": nil},
+			}},
+		},
+		{
+			"ascending from a missing value", userCursor{ID: objectID.Hex(), ObjectID: true}, false,
+			bson.M{"$or": []bson.M{
+				{"nama": nil, "_id": bson.M{"$gt": objectID}},
+				{"nama": bson.M{"$ne": nil}},
+			}},
+		},
+		{
+			"descending from a missing value", userCursor{ID: objectID.Hex(), ObjectID: true}, true,
+			bson.M{"nama": nil, "_id": bson.M{"$lt": objectID}},
+		},
+		{
+			"string id", userCursor{Value: "Ana", ID: objectID.Hex()}, false,
+			bson.M{"$or": []bson.M{
+				{"nama": bson.M{"$gt": "Ana"}},
+				{"nama": "Ana", "_id": bson.M{"$gt": objectID.Hex()}},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cursor.filter("nama", tt.desc); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("filter = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
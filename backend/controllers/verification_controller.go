@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend/config"
+	"backend/mailer"
+	"backend/models"
+	"backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Purpose claims of the links sent to confirm an address: the one of a new account,
+// and the new one of an existing account
+const (
+	purposeEmailVerification = "email_verification"
+	purposeEmailChange       = "email_change"
+)
+
+// VerifyEmail godoc
+//
+//	@Summary		Verify email address
+//	@Description	Activate a pending account with the signed token from the verification email. Tokens mailed to confirm a new address of an existing account switch the account to that address.
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		object{token=string}	true	"Verification token"
+//	@Success		200		{object}	map[string]string		"Email verified"
+//	@Failure		400		{object}	map[string]string		"Invalid or expired token"
+//	@Failure		500		{object}	map[string]string		"Internal server error"
+//	@Router			/auth/verify-email [post]
+func VerifyEmail(c *fiber.Ctx) error {
+	var input struct {
+		Token string `json:"token"`
+	}
+	if err := c.BodyParser(&input); err != nil || input.Token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Request tidak valid"})
+	}
+
+	claims, err := utils.ParsePurposeToken(input.Token, purposeEmailVerification)
+	if err != nil {
+		// Both links land on the same page
+		if changeClaims, changeErr := utils.ParsePurposeToken(input.Token, purposeEmailChange); changeErr == nil {
+			return confirmEmailChange(c, changeClaims)
+		}
+		fmt.Println("Email verification token error:", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Link verifikasi tidak valid atau sudah kedaluwarsa"})
+	}
+	userID, _ := claims["id"].(string)
+	email, _ := claims["email"].(string)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := findUserByID(ctx, userID)
+	// The link is only good for the address it was sent to
+	if err != nil || !strings.EqualFold(user.Email, email) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Link verifikasi tidak valid atau sudah kedaluwarsa"})
+	}
+
+	if !user.IsPendingVerification() {
+		return c.JSON(fiber.Map{"message": "Email sudah diverifikasi"})
+	}
+
+	err = updateUserFields(ctx, user.ID, bson.M{
+		"accountStatus":   models.AccountStatusActive,
+		"emailVerifiedAt": time.Now(),
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memverifikasi email"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Email berhasil diverifikasi, silakan login"})
+}
+
+// ResendVerification godoc
+//
+//	@Summary		Resend verification email
+//	@Description	Send a new verification link to a pending account. Limited per IP and per account; the response does not reveal whether the email is registered.
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		object{email=string}	true	"Account email"
+//	@Success		200		{object}	map[string]string		"Verification email sent if the account is pending"
+//	@Failure		400		{object}	map[string]string		"Invalid request"
+//	@Failure		429		{object}	map[string]string		"Too many requests"
+//	@Router			/auth/resend-verification [post]
+func ResendVerification(c *fiber.Ctx) error {
+	var input struct {
+		Email string `json:"email"`
+	}
+	if err := c.BodyParser(&input); err != nil || strings.TrimSpace(input.Email) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Request tidak valid"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	response := fiber.Map{"message": "Jika akun menunggu verifikasi, email verifikasi telah dikirim"}
+
+	var user models.User
+	err := config.UserCollectionRef.FindOne(ctx, emailFilter(strings.TrimSpace(input.Email))).Decode(&user)
+	if err != nil || !user.IsPendingVerification() {
+		return c.JSON(response)
+	}
+
+	// Per-account cooldown on top of the per-IP limiter on the route
+	interval := utils.DurationFromEnv("VERIFICATION_RESEND_INTERVAL", time.Minute)
+	if user.VerificationSentAt != nil && time.Since(*user.VerificationSentAt) < interval {
+		retryAfter := interval - time.Since(*user.VerificationSentAt)
+		c.Set(fiber.HeaderRetryAfter, fmt.Sprintf("%d", int(retryAfter.Seconds())+1))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "Tunggu sebentar sebelum meminta email verifikasi lagi"})
+	}
+
+	if err := sendVerificationEmail(ctx, user); err != nil {
+		fmt.Println("Error sending verification email:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal mengirim email"})
+	}
+
+	return c.JSON(response)
+}
+
+// sendVerificationEmail mails a signed verification link and records when it was sent
+func sendVerificationEmail(ctx context.Context, user models.User) error {
+	ttl := utils.DurationFromEnv("EMAIL_VERIFICATION_TTL", 24*time.Hour)
+	token, err := utils.GeneratePurposeToken(purposeEmailVerification, jwt.MapClaims{
+		"id":    user.ID,
+		"email": user.Email,
+	}, ttl)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", config.AppURL(), token)
+	err = mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verifikasi email CoEmotion",
+		Body: fmt.Sprintf("Halo %s,\n\nKlik link berikut untuk memverifikasi email Anda:\n%s\n\nLink ini berlaku selama %s.\n",
+			user.Nama, link, ttl),
+	})
+	if err != nil {
+		return err
+	}
+
+	return updateUserFields(ctx, user.ID, bson.M{"verificationSentAt": time.Now()})
+}
+
+// confirmEmailChange switches a user to the pending address the link was sent to.
+// Only the latest requested address works, and only while nobody else has registered it.
+func confirmEmailChange(c *fiber.Ctx, claims jwt.MapClaims) error {
+	userID, _ := claims["id"].(string)
+	email, _ := claims["email"].(string)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := findUserByID(ctx, userID)
+	if err != nil || email == "" || !strings.EqualFold(user.PendingEmail, email) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Link verifikasi tidak valid atau sudah kedaluwarsa"})
+	}
+
+	taken, err := config.UserCollectionRef.CountDocuments(ctx, bson.M{"$and": []bson.M{
+		emailFilter(email),
+		{"$nor": []bson.M{models.UserIDFilter(user.ID)}},
+	}})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memverifikasi email"})
+	}
+	if taken > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Email sudah terdaftar"})
+	}
+
+	set := bson.M{"email": email, "emailVerifiedAt": time.Now()}
+	// The link reached the user's inbox, which is all verifying a pending account asks for
+	if user.IsPendingVerification() {
+		set["accountStatus"] = models.AccountStatusActive
+	}
+	_, err = config.UserCollectionRef.UpdateOne(ctx, models.UserIDFilter(user.ID), bson.M{
+		"$set":   set,
+		"$unset": bson.M{"pendingEmail": ""},
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal memverifikasi email"})
+	}
+
+	// Let the old address know, in case it wasn't the owner who asked
+	err = mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Email akun CoEmotion diubah",
+		Body: fmt.Sprintf("Halo %s,\n\nEmail akun CoEmotion Anda telah diubah menjadi %s. Hubungi administrator jika Anda tidak melakukan perubahan ini.\n",
+			user.Nama, email),
+	})
+	if err != nil {
+		fmt.Println("Error sending email change notice:", err)
+	}
+
+	return c.JSON(fiber.Map{"message": "Email berhasil diubah, silakan login dengan email baru"})
+}
+
+// sendEmailChangeConfirmation mails a link to the new address of a user. The address
+// replaces the current one only when that link is used.
+func sendEmailChangeConfirmation(ctx context.Context, user models.User, newEmail string) error {
+	ttl := utils.DurationFromEnv("EMAIL_VERIFICATION_TTL", 24*time.Hour)
+	token, err := utils.GeneratePurposeToken(purposeEmailChange, jwt.MapClaims{
+		"id":    user.ID,
+		"email": newEmail,
+	}, ttl)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", config.AppURL(), token)
+	return mailer.Send(ctx, mailer.Message{
+		To:      newEmail,
+		Subject: "Konfirmasi email baru CoEmotion",
+		Body: fmt.Sprintf("Halo %s,\n\nKlik link berikut untuk memakai alamat ini sebagai email akun CoEmotion Anda:\n%s\n\nLink ini berlaku selama %s. Abaikan email ini jika Anda tidak meminta perubahan.\n",
+			user.Nama, link, ttl),
+	})
+}
@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"context"
+
+	"backend/config"
+	"backend/middleware"
+	"backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// workspaceFilter matches the documents of a workspace. Documents from before
+// workspaces existed have no workspaceId and belong to the default workspace.
+func workspaceFilter(workspaceID string) bson.M {
+	workspaceID = models.WorkspaceOrDefault(workspaceID)
+	if workspaceID == models.DefaultWorkspaceID {
+		return bson.M{"workspaceId": bson.M{"$in": []interface{}{nil, models.DefaultWorkspaceID}}}
+	}
+	return bson.M{"workspaceId": workspaceID}
+}
+
+// scopeToWorkspace restricts a filter to one workspace
+func scopeToWorkspace(workspaceID string, filter bson.M) bson.M {
+	return bson.M{"$and": []bson.M{filter, workspaceFilter(workspaceID)}}
+}
+
+// inWorkspace restricts a filter to the caller's workspace. Every user, meeting
+// and team query made on behalf of a request goes through here.
+func inWorkspace(c *fiber.Ctx, filter bson.M) bson.M {
+	return scopeToWorkspace(middleware.CurrentWorkspaceID(c), filter)
+}
+
+// findWorkspaceUser is findUserByID limited to the caller's workspace
+func findWorkspaceUser(ctx context.Context, c *fiber.Ctx, userID string) (models.User, error) {
+	var user models.User
+	err := config.UserCollectionRef.FindOne(ctx, inWorkspace(c, models.UserIDFilter(userID))).Decode(&user)
+	return user, err
+}
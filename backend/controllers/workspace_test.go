@@ -0,0 +1,257 @@
+package controllers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"backend/middleware"
+	"backend/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// workspace is an admin and a member seeded in one workspace
+type workspace struct {
+	id     string
+	admin  models.User
+	member models.User
+}
+
+func seedWorkspace(t *testing.T, id, domain string) workspace {
+	t.Helper()
+	return workspace{
+		id:     id,
+		admin:  seedUser(t, models.User{Nama: "Admin", Email: "admin@" + domain, Role: middleware.RoleAdmin, WorkspaceID: id}),
+		member: seedUser(t, models.User{Nama: "Member", Email: "member@" + domain, WorkspaceID: id}),
+	}
+}
+
+// created posts body as the given user and returns the id of the created resource
+func created(t *testing.T, app *fiber.App, path, token string, body interface{}) string {
+	t.Helper()
+	status, data := callJSON(t, app, http.MethodPost, path, token, body)
+	if status != fiber.StatusCreated {
+		t.Fatalf("POST %s: status %d, body %v", path, status, data)
+	}
+	id, _ := data["id"].(string)
+	if id == "" {
+		t.Fatalf("POST %s: no id in %v", path, data)
+	}
+	return id
+}
+
+// listIDs returns the ids of a JSON array, or of the "data" array of a page
+func listIDs(t *testing.T, app *fiber.App, path, token string) map[string]bool {
+	t.Helper()
+	status, data := call(t, app, http.MethodGet, path, token, nil)
+	if status != fiber.StatusOK {
+		t.Fatalf("GET %s: status %d, %s", path, status, data)
+	}
+	var items []map[string]interface{}
+	if err := json.Unmarshal(data, &items); err != nil {
+		var page struct {
+			Data []map[string]interface{} `json:"data"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			t.Fatalf("GET %s: not a list: %s", path, data)
+		}
+		items = page.Data
+	}
+	ids := map[string]bool{}
+	for _, item := range items {
+		id, _ := item["id"].(string)
+		ids[id] = true
+	}
+	return ids
+}
+
+func TestWorkspaceIsolation(t *testing.T) {
+	app := newTestApp(t)
+	a := seedWorkspace(t, models.DefaultWorkspaceID, "a.example.com")
+	b := seedWorkspace(t, "acme", "b.example.com")
+	adminA, adminB := tokenFor(t, a.admin), tokenFor(t, b.admin)
+
+	meeting := created(t, app, "/api/meetings", adminA, map[string]interface{}{
+		"title": "Planning", "date": "2026-12-01", "time": "10:00", "duration": 30,
+	})
+	team := created(t, app, "/api/teams", adminA, map[string]interface{}{
+		"name": "Alpha", "members": []string{a.member.ID},
+	})
+	invitation := created(t, app, "/api/invitations", adminA, map[string]interface{}{
+		"email": "new@a.example.com",
+	})
+
+	t.Run("users", func(t *testing.T) {
+		for _, path := range []string{"/api/users", "/users"} {
+			ids := listIDs(t, app, path, adminB)
+			if ids[a.admin.ID] || ids[a.member.ID] || !ids[b.member.ID] {
+				t.Fatalf("GET %s lists %v", path, ids)
+			}
+		}
+		for _, path := range []string{"/api/users/" + a.member.ID, "/users/" + a.member.ID} {
+			if status, _ := call(t, app, http.MethodGet, path, adminB, nil); status != fiber.StatusNotFound {
+				t.Fatalf("GET %s: status %d, want 404", path, status)
+			}
+		}
+		if status, _ := call(t, app, http.MethodPut, "/api/users/"+a.member.ID, adminB, map[string]string{"nama": "Renamed"}); status != fiber.StatusNotFound {
+			t.Fatalf("update: status %d, want 404", status)
+		}
+		if status, _ := call(t, app, http.MethodDelete, "/api/users/"+a.member.ID, adminB, nil); status != fiber.StatusNotFound {
+			t.Fatalf("delete: status %d, want 404", status)
+		}
+		if stored := findUser(t, a.member.ID); stored.Nama != a.member.Nama || stored.DeletedAt != nil {
+			t.Fatalf("user changed from the other workspace: %+v", stored)
+		}
+	})
+
+	t.Run("team members", func(t *testing.T) {
+		if status, _ := call(t, app, http.MethodGet, "/api/team-members?teamId="+team, adminB, nil); status != fiber.StatusNotFound {
+			t.Fatalf("status %d, want 404", status)
+		}
+		ids := listIDs(t, app, "/api/team-members", adminB)
+		if ids[a.member.ID] {
+			t.Fatalf("team members of the other workspace listed: %v", ids)
+		}
+	})
+
+	t.Run("meetings", func(t *testing.T) {
+		if ids := listIDs(t, app, "/api/meetings", adminB); ids[meeting] {
+			t.Fatal("meeting of the other workspace listed")
+		}
+		path := "/api/meetings/" + meeting
+		if status, _ := call(t, app, http.MethodGet, path, adminB, nil); status != fiber.StatusNotFound {
+			t.Fatalf("get: status %d, want 404", status)
+		}
+		if status, _ := call(t, app, http.MethodPut, path, adminB, map[string]string{"title": "Hijacked"}); status != fiber.StatusNotFound {
+			t.Fatalf("update: status %d, want 404", status)
+		}
+		if status, _ := call(t, app, http.MethodDelete, path, adminB, nil); status != fiber.StatusNotFound {
+			t.Fatalf("delete: status %d, want 404", status)
+		}
+		status, body := callJSON(t, app, http.MethodGet, path, adminA, nil)
+		if status != fiber.StatusOK || body["title"] != "Planning" {
+			t.Fatalf("meeting changed from the other workspace: status %d, %v", status, body)
+		}
+
+		// Users of another workspace can't be invited
+		foreign := map[string]interface{}{
+			"title": "Planning", "date": "2026-12-01", "time": "10:00", "duration": 30,
+			"participants": []string{a.member.ID, b.member.ID},
+		}
+		if status, _ := call(t, app, http.MethodPost, "/api/meetings", adminA, foreign); status != fiber.StatusBadRequest {
+			t.Fatalf("create with a foreign participant: status %d, want 400", status)
+		}
+		if status, _ := call(t, app, http.MethodPut, path, adminA, foreign); status != fiber.StatusBadRequest {
+			t.Fatalf("update with a foreign participant: status %d, want 400", status)
+		}
+	})
+
+	t.Run("teams", func(t *testing.T) {
+		if ids := listIDs(t, app, "/api/teams?all=true", adminB); ids[team] {
+			t.Fatal("team of the other workspace listed")
+		}
+		path := "/api/teams/" + team
+		if status, _ := call(t, app, http.MethodGet, path, adminB, nil); status != fiber.StatusNotFound {
+			t.Fatalf("get: status %d, want 404", status)
+		}
+		if status, _ := call(t, app, http.MethodPut, path, adminB, map[string]string{"name": "Hijacked"}); status != fiber.StatusNotFound {
+			t.Fatalf("update: status %d, want 404", status)
+		}
+		if status, _ := call(t, app, http.MethodPost, path+"/members", adminB, map[string]string{"userId": b.member.ID}); status != fiber.StatusNotFound {
+			t.Fatalf("add member: status %d, want 404", status)
+		}
+		if status, _ := call(t, app, http.MethodDelete, path, adminB, nil); status != fiber.StatusNotFound {
+			t.Fatalf("delete: status %d, want 404", status)
+		}
+
+		// Users of another workspace can't be put in a team
+		if status, _ := call(t, app, http.MethodPost, path+"/members", adminA, map[string]string{"userId": b.member.ID}); status != fiber.StatusBadRequest {
+			t.Fatalf("add foreign member: status %d, want 400", status)
+		}
+		status, _ := call(t, app, http.MethodPost, "/api/teams", adminB, map[string]interface{}{
+			"name": "Beta", "members": []string{a.member.ID},
+		})
+		if status != fiber.StatusBadRequest {
+			t.Fatalf("create with a foreign member: status %d, want 400", status)
+		}
+	})
+
+	t.Run("invitations", func(t *testing.T) {
+		if ids := listIDs(t, app, "/api/invitations", adminB); ids[invitation] {
+			t.Fatal("invitation of the other workspace listed")
+		}
+		if status, _ := call(t, app, http.MethodDelete, "/api/invitations/"+invitation, adminB, nil); status != fiber.StatusNotFound {
+			t.Fatalf("revoke: status %d, want 404", status)
+		}
+		// Inviting the same address elsewhere only supersedes invitations of that workspace
+		created(t, app, "/api/invitations", adminB, map[string]interface{}{"email": "new@a.example.com"})
+		if ids := listIDs(t, app, "/api/invitations?status=pending", adminA); !ids[invitation] {
+			t.Fatal("invitation revoked from the other workspace")
+		}
+
+		// Workspace admins can't invite into, or create users in, another workspace
+		status, _ := call(t, app, http.MethodPost, "/api/invitations", adminB, map[string]string{
+			"email": "intruder@b.example.com", "workspaceId": a.id,
+		})
+		if status != fiber.StatusForbidden {
+			t.Fatalf("invite into another workspace: status %d, want 403", status)
+		}
+		status, _ = call(t, app, http.MethodPost, "/api/users", adminB, map[string]string{
+			"nama": "Intruder", "email": "intruder@b.example.com", "password": testPassword, "workspaceId": a.id,
+		})
+		if status != fiber.StatusOK {
+			t.Fatalf("create user: status %d", status)
+		}
+		if stored := findUserByEmail(t, "intruder@b.example.com"); stored.WorkspaceID != b.id {
+			t.Fatalf("user created in workspace %q, want %q", stored.WorkspaceID, b.id)
+		}
+	})
+
+	t.Run("impersonation", func(t *testing.T) {
+		if status, _ := call(t, app, http.MethodPost, "/api/admin/impersonate/"+a.member.ID, adminB, nil); status != fiber.StatusNotFound {
+			t.Fatalf("status %d, want 404", status)
+		}
+	})
+}
+
+// Signing up can't pick a workspace, a role or any other server-owned field
+func TestSignUpIgnoresServerOwnedFields(t *testing.T) {
+	app := newTestApp(t)
+
+	for _, path := range []string{"/register", "/users"} {
+		email := "signup" + path[1:] + "@example.com"
+		status, body := callJSON(t, app, http.MethodPost, path, "", map[string]interface{}{
+			"id":            "64b000000000000000000001",
+			"nama":          "Ana",
+			"email":         email,
+			"password":      testPassword,
+			"role":          middleware.RoleAdmin,
+			"workspaceId":   "acme",
+			"accountStatus": models.AccountStatusActive,
+			"deletedAt":     "2026-01-01T00:00:00Z",
+			"deactivatedAt": "2026-01-01T00:00:00Z",
+			"lastActive":    "2026-01-01T00:00:00Z",
+			"profileImage":  "https://evil.example.com/a.png",
+			"avatars":       map[string]string{"64": "https://evil.example.com/a.png"},
+			"customStatus":  map[string]string{"text": "hi"},
+		})
+		if status != fiber.StatusCreated && status != fiber.StatusOK {
+			t.Fatalf("POST %s: status %d, body %v", path, status, body)
+		}
+
+		stored := findUserByEmail(t, email)
+		switch {
+		case stored.ID == "64b000000000000000000001":
+			t.Fatalf("POST %s: id taken from the request", path)
+		case stored.WorkspaceID != models.DefaultWorkspaceID:
+			t.Fatalf("POST %s: workspace %q", path, stored.WorkspaceID)
+		case stored.Role != middleware.DefaultRole || stored.AccountStatus != models.AccountStatusPendingVerification:
+			t.Fatalf("POST %s: role %q, status %q", path, stored.Role, stored.AccountStatus)
+		case stored.DeletedAt != nil || stored.DeactivatedAt != nil || !stored.LastActive.IsZero():
+			t.Fatalf("POST %s: lifecycle fields set: %+v", path, stored)
+		case stored.ProfileImage != "" || stored.Avatars != nil || stored.CustomStatus != nil:
+			t.Fatalf("POST %s: profile fields set: %+v", path, stored)
+		}
+	}
+}
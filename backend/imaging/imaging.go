@@ -0,0 +1,261 @@
+// Package imaging turns uploaded profile images into square avatars.
+//
+// Uploads are identified by their magic bytes, never by name or Content-Type,
+// and only JPEG, PNG and WebP are accepted. Every avatar is re-encoded from the
+// decoded pixels, so EXIF and any other metadata (GPS position, camera serial)
+// never reach the uploads folder. The EXIF orientation of JPEG photos is applied
+// first so portraits taken on phones don't come out sideways.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"backend/utils"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// Accepted upload types
+const (
+	TypeJPEG = "image/jpeg"
+	TypePNG  = "image/png"
+	TypeWebP = "image/webp"
+)
+
+// Avatars are always stored as JPEG
+const (
+	AvatarContentType = TypeJPEG
+	AvatarExtension   = ".jpg"
+)
+
+var (
+	ErrUnsupportedType = errors.New("only JPEG, PNG and WebP images are accepted")
+	ErrTooLarge        = errors.New("image is too large")
+)
+
+// MaxUploadBytes is the largest upload accepted, IMAGE_MAX_BYTES (default 2 MiB)
+func MaxUploadBytes() int {
+	return utils.IntFromEnv("IMAGE_MAX_BYTES", 2<<20)
+}
+
+// MaxPixels bounds the decoded size, IMAGE_MAX_PIXELS (default 25 megapixels).
+// A small, highly compressed file can otherwise decode into gigabytes.
+func MaxPixels() int {
+	return utils.IntFromEnv("IMAGE_MAX_PIXELS", 25_000_000)
+}
+
+// AvatarSizes are the square edge lengths generated for every upload, smallest
+// first, from AVATAR_SIZES (default "64,128,256")
+func AvatarSizes() []int {
+	var sizes []int
+	value := os.Getenv("AVATAR_SIZES")
+	if value == "" {
+		value = "64,128,256"
+	}
+	for _, part := range strings.Split(value, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(part))
+		if err == nil && size > 0 && size <= 1024 {
+			sizes = append(sizes, size)
+		}
+	}
+	if len(sizes) == 0 {
+		return []int{64, 128, 256}
+	}
+	sort.Ints(sizes)
+	return sizes
+}
+
+// Sniff returns the type of an image from its first bytes
+func Sniff(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return TypeJPEG, nil
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return TypePNG, nil
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return TypeWebP, nil
+	}
+	return "", ErrUnsupportedType
+}
+
+// Avatar is one generated size
+type Avatar struct {
+	Size int
+	Data []byte
+}
+
+// Avatars checks an upload and renders it as a square JPEG for every size.
+// Non-square images are cropped to their centre; transparency is flattened onto white.
+func Avatars(data []byte, sizes []int) ([]Avatar, error) {
+	if len(data) > MaxUploadBytes() {
+		return nil, ErrTooLarge
+	}
+	contentType, err := Sniff(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var config image.Config
+	switch contentType {
+	case TypeJPEG:
+		config, err = jpeg.DecodeConfig(bytes.NewReader(data))
+	case TypePNG:
+		config, err = png.DecodeConfig(bytes.NewReader(data))
+	case TypeWebP:
+		config, err = webp.DecodeConfig(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels() {
+		return nil, ErrTooLarge
+	}
+
+	var src image.Image
+	switch contentType {
+	case TypeJPEG:
+		src, err = jpeg.Decode(bytes.NewReader(data))
+	case TypePNG:
+		src, err = png.Decode(bytes.NewReader(data))
+	case TypeWebP:
+		src, err = webp.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+
+	orientation := 1
+	if contentType == TypeJPEG {
+		orientation = jpegOrientation(data)
+	}
+
+	// The centre square is the same before and after rotating, so the
+	// orientation is applied to the small avatars rather than the full photo
+	crop := centerSquare(src.Bounds())
+	avatars := make([]Avatar, 0, len(sizes))
+	for _, size := range sizes {
+		dst := image.NewRGBA(image.Rect(0, 0, size, size))
+		draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+		xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Over, nil)
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, orient(dst, orientation), &jpeg.Options{Quality: 85}); err != nil {
+			return nil, err
+		}
+		avatars = append(avatars, Avatar{Size: size, Data: buf.Bytes()})
+	}
+	return avatars, nil
+}
+
+// centerSquare is the largest square in the middle of r
+func centerSquare(r image.Rectangle) image.Rectangle {
+	side := r.Dx()
+	if r.Dy() < side {
+		side = r.Dy()
+	}
+	x := r.Min.X + (r.Dx()-side)/2
+	y := r.Min.Y + (r.Dy()-side)/2
+	return image.Rect(x, y, x+side, y+side)
+}
+
+// orient turns a square image upright according to an EXIF orientation (1-8)
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	n := img.Bounds().Dx()
+	out := image.NewRGBA(img.Bounds())
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // flip horizontally
+				dx, dy = n-1-x, y
+			case 3: // rotate 180°
+				dx, dy = n-1-x, n-1-y
+			case 4: // flip vertically
+				dx, dy = x, n-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // rotate 90° clockwise
+				dx, dy = n-1-y, x
+			case 7: // transverse
+				dx, dy = n-1-y, n-1-x
+			case 8: // rotate 90° counter-clockwise
+				dx, dy = y, n-1-x
+			}
+			out.SetRGBA(dx, dy, img.RGBAAt(x, y))
+		}
+	}
+	return out
+}
+
+// jpegOrientation reads the EXIF orientation tag of a JPEG, 1 (upright) when there is none
+func jpegOrientation(data []byte) int {
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		// Start of scan: the metadata segments are over
+		if marker == 0xDA {
+			return 1
+		}
+		length := int(data[i+2])<<8 | int(data[i+3])
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation finds tag 0x0112 in the first IFD of a TIFF header
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var u16 func([]byte) int
+	var u32 func([]byte) int
+	switch string(tiff[:2]) {
+	case "II":
+		u16 = func(b []byte) int { return int(b[0]) | int(b[1])<<8 }
+		u32 = func(b []byte) int { return u16(b) | u16(b[2:])<<16 }
+	case "MM":
+		u16 = func(b []byte) int { return int(b[0])<<8 | int(b[1]) }
+		u32 = func(b []byte) int { return u16(b)<<16 | u16(b[2:]) }
+	default:
+		return 1
+	}
+
+	ifd := u32(tiff[4:])
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := u16(tiff[ifd:])
+	for e := 0; e < entries; e++ {
+		entry := ifd + 2 + 12*e
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if u16(tiff[entry:]) == 0x0112 {
+			return u16(tiff[entry+8:])
+		}
+	}
+	return 1
+}
@@ -0,0 +1,234 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"reflect"
+	"testing"
+)
+
+var (
+	red   = color.RGBA{255, 0, 0, 255}
+	green = color.RGBA{0, 255, 0, 255}
+	blue  = color.RGBA{0, 0, 255, 255}
+	white = color.RGBA{255, 255, 255, 255}
+)
+
+// paint returns a w×h image whose pixels are given by fill
+func paint(w, h int, fill func(x, y int) color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, fill(x, y))
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withOrientation inserts an EXIF segment holding only the orientation tag after the JPEG's SOI marker
+func withOrientation(data []byte, orientation byte) []byte {
+	tiff := []byte{
+		'M', 'M', 0, 42, 0, 0, 0, 8, // big endian header, first IFD at 8
+		0, 1, // one entry
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, orientation, 0, 0, // orientation, SHORT, count 1
+		0, 0, 0, 0, // no next IFD
+	}
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	length := len(segment) + 2
+	app1 := append([]byte{0xFF, 0xE1, byte(length >> 8), byte(length)}, segment...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	return append(out, data[2:]...)
+}
+
+// near reports whether two colours are within JPEG compression error of each other
+func near(got color.Color, want color.RGBA) bool {
+	r, g, b, _ := got.RGBA()
+	diff := func(a uint32, b uint8) bool {
+		d := int(a>>8) - int(b)
+		return d > -48 && d < 48
+	}
+	return diff(r, want.R) && diff(g, want.G) && diff(b, want.B)
+}
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE0, 0, 0x10}, TypeJPEG},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), TypePNG},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), TypeWebP},
+		{"riff that isn't webp", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), ""},
+		{"truncated webp", []byte("RIFF\x24\x00\x00\x00WEB"), ""},
+		{"truncated png", []byte("\x89PNG\r\n"), ""},
+		{"gif", []byte("GIF89a\x01\x00\x01\x00"), ""},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`), ""},
+		{"empty", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Sniff(tt.data)
+			if got != tt.want {
+				t.Fatalf("Sniff = %q, want %q", got, tt.want)
+			}
+			if (tt.want == "") != errors.Is(err, ErrUnsupportedType) {
+				t.Fatalf("err = %v", err)
+			}
+		})
+	}
+}
+
+func TestAvatarSizes(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []int
+	}{
+		{"", []int{64, 128, 256}},
+		{"32", []int{32}},
+		{"256, 64,128", []int{64, 128, 256}},
+		{"64,abc,0,-1,2048,128", []int{64, 128}},
+		{"abc", []int{64, 128, 256}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("AVATAR_SIZES", tt.value)
+			if got := AvatarSizes(); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("AvatarSizes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAvatars(t *testing.T) {
+	// Red, green and blue thirds: the centre crop is all green
+	thirds := paint(300, 100, func(x, y int) color.Color {
+		switch {
+		case x < 100:
+			return red
+		case x < 200:
+			return green
+		}
+		return blue
+	})
+	// Red on top, blue below
+	halves := paint(64, 64, func(x, y int) color.Color {
+		if y < 32 {
+			return red
+		}
+		return blue
+	})
+
+	tests := []struct {
+		name string
+		data []byte
+		// want maps points of the 32px avatar to their colour
+		want map[image.Point]color.RGBA
+	}{
+		{"png cropped to the centre", encodePNG(t, thirds), map[image.Point]color.RGBA{{1, 1}: green, {30, 30}: green}},
+		{"jpeg cropped to the centre", encodeJPEG(t, thirds), map[image.Point]color.RGBA{{1, 1}: green, {30, 30}: green}},
+		{
+			"transparency flattened onto white",
+			encodePNG(t, paint(40, 40, func(x, y int) color.Color { return color.RGBA{} })),
+			map[image.Point]color.RGBA{{16, 16}: white},
+		},
+		{"upright jpeg", encodeJPEG(t, halves), map[image.Point]color.RGBA{{16, 4}: red, {16, 28}: blue}},
+		{
+			"jpeg rotated 90° clockwise", withOrientation(encodeJPEG(t, halves), 6),
+			map[image.Point]color.RGBA{{28, 24}: red, {4, 8}: blue},
+		},
+		{
+			"jpeg rotated 180°", withOrientation(encodeJPEG(t, halves), 3),
+			map[image.Point]color.RGBA{{16, 28}: red, {16, 4}: blue},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			avatars, err := Avatars(tt.data, []int{32, 48})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(avatars) != 2 || avatars[0].Size != 32 || avatars[1].Size != 48 {
+				t.Fatalf("got %d avatars", len(avatars))
+			}
+			for _, avatar := range avatars {
+				if bytes.Contains(avatar.Data, []byte("Exif")) {
+					t.Fatal("metadata kept")
+				}
+				if contentType, _ := Sniff(avatar.Data); contentType != AvatarContentType {
+					t.Fatalf("avatar is %q", contentType)
+				}
+				img, err := jpeg.Decode(bytes.NewReader(avatar.Data))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if b := img.Bounds(); b.Dx() != avatar.Size || b.Dy() != avatar.Size {
+					t.Fatalf("avatar %d is %v", avatar.Size, b)
+				}
+			}
+
+			img, _ := jpeg.Decode(bytes.NewReader(avatars[0].Data))
+			for point, want := range tt.want {
+				if got := img.At(point.X, point.Y); !near(got, want) {
+					t.Fatalf("pixel %v is %v, want %v", point, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAvatarsRejects(t *testing.T) {
+	small := encodePNG(t, paint(10, 10, func(x, y int) color.Color { return red }))
+	var animated bytes.Buffer
+	if err := gif.Encode(&animated, paint(10, 10, func(x, y int) color.Color { return red }), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		data []byte
+		want error
+	}{
+		{"unsupported type", nil, animated.Bytes(), ErrUnsupportedType},
+		{"truncated image", nil, small[:len(small)/2], ErrUnsupportedType},
+		{"corrupt header", nil, append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 32)...), ErrUnsupportedType},
+		{"too many bytes", map[string]string{"IMAGE_MAX_BYTES": "16"}, small, ErrTooLarge},
+		{"too many pixels", map[string]string{"IMAGE_MAX_PIXELS": "99"}, small, ErrTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			if _, err := Avatars(tt.data, []int{32}); !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails. Controllers use the package-level Send, which goes through Default.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Default is the mailer used by Send. Init replaces it based on MAIL_DRIVER.
+var Default Mailer = &LogMailer{}
+
+// Init selects the mailer from the environment.
+// MAIL_DRIVER=smtp uses SMTP_HOST/SMTP_PORT/SMTP_USERNAME/SMTP_PASSWORD/MAIL_FROM,
+// anything else writes messages to MAIL_LOG_FILE (or the server log when unset).
+func Init() {
+	switch os.Getenv("MAIL_DRIVER") {
+	case "smtp":
+		m := &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+		if m.Host == "" || m.From == "" {
+			log.Fatal("❌ MAIL_DRIVER=smtp requires SMTP_HOST and MAIL_FROM")
+		}
+		if m.Port == "" {
+			m.Port = "587"
+		}
+		Default = m
+		log.Printf("✅ Sending email through SMTP server %s:%s", m.Host, m.Port)
+	default:
+		Default = &LogMailer{Path: os.Getenv("MAIL_LOG_FILE")}
+		log.Println("⚠️ MAIL_DRIVER not set to smtp, emails will only be logged")
+	}
+}
+
+// Send delivers msg with the Default mailer
+func Send(ctx context.Context, msg Message) error {
+	return Default.Send(ctx, msg)
+}
+
+// SMTPMailer sends email through an SMTP server using PLAIN auth when credentials are set
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	// Strip line breaks from headers so user input can't inject extra ones
+	headerSafe := strings.NewReplacer("\r", "", "\n", "")
+	body := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		headerSafe.Replace(m.From), headerSafe.Replace(msg.To), headerSafe.Replace(msg.Subject), msg.Body)
+
+	return smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{msg.To}, []byte(body))
+}
+
+// LogMailer appends every message to a file, or to the server log when Path is empty.
+// It's meant for development and tests, where the file can be read back to grab links.
+type LogMailer struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	if m.Path == "" {
+		log.Printf("📧 Email to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n---\n", time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+	return err
+}
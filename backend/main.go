@@ -5,7 +5,12 @@ import (
 	"os"
 
 	"backend/config"
+	"backend/controllers"
+	"backend/mailer"
+	"backend/middleware"
 	"backend/routes"
+	"backend/storage"
+	"backend/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -47,9 +52,9 @@ func main() {
 		port = "8080"
 	}
 
-	// Check if JWT_SECRET is set
-	if os.Getenv("JWT_SECRET") == "" {
-		log.Println("⚠️ JWT_SECRET not set in environment variables, using default (insecure for production)")
+	// Load JWT signing keys; production refuses to start without real ones
+	if err := utils.LoadJWTKeys(); err != nil {
+		log.Fatal("❌ Failed to load JWT keys:", err)
 	}
 
 	app := fiber.New(fiber.Config{
@@ -83,13 +88,27 @@ func main() {
 	// Add Logger middleware
 	app.Use(logger.New())
 
-	// Serve static files
-	app.Static("/uploads", "./uploads")
+	// Select where uploads are stored (local disk or S3)
+	storage.Init()
+
+	// Local uploads are always served by this server, so files uploaded before a
+	// switch to S3 keep working; S3 serves its own
+	uploads := storage.LocalFromEnv()
+	app.Static(uploads.BaseURL, uploads.Dir)
+
+	// Load role → permission policy
+	middleware.LoadRolePermissions()
+
+	// Select email delivery (SMTP or log)
+	mailer.Init()
 
 	// Connect to database
 	config.ConnectDB()
 	log.Println("✅ Connected to database")
 
+	// Remove soft-deleted accounts once their retention window has passed
+	controllers.StartUserPurge()
+
 	// Setup routes
 	routes.SetupRoutes(app)
 
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"backend/config"
+	"backend/models"
+	"backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// accessTokenTouchInterval limits how often a token's lastUsedAt is written
+const accessTokenTouchInterval = time.Minute
+
+// authenticateAccessToken resolves a personal access token to request claims.
+// The owner's current role is used, so demoting a user also narrows their tokens.
+func authenticateAccessToken(c *fiber.Ctx, token string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var pat models.PersonalAccessToken
+	err := config.AccessTokenCollectionRef.FindOne(ctx, bson.M{"tokenHash": utils.HashToken(token)}).Decode(&pat)
+	if err != nil && err != mongo.ErrNoDocuments {
+		fmt.Println("Error looking up access token:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to verify token",
+		})
+	}
+	now := time.Now()
+	if err == mongo.ErrNoDocuments || pat.RevokedAt != nil || now.After(pat.ExpiresAt) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Unauthorized - Invalid, expired or revoked token",
+		})
+	}
+
+	var owner models.User
+	err = config.UserCollectionRef.FindOne(ctx, models.UserIDFilter(pat.UserID)).Decode(&owner)
+	if err != nil || owner.IsPendingVerification() || owner.IsDisabled() {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Unauthorized - Token owner is not active",
+		})
+	}
+
+	if pat.LastUsedAt == nil || now.Sub(*pat.LastUsedAt) > accessTokenTouchInterval {
+		_, err = config.AccessTokenCollectionRef.UpdateOne(ctx,
+			bson.M{"_id": pat.ID},
+			bson.M{"$set": bson.M{"lastUsedAt": now, "lastUsedIp": c.IP()}},
+		)
+		if err != nil {
+			fmt.Println("Error updating access token last used:", err)
+		}
+	}
+
+	c.Locals("user", jwt.MapClaims{
+		"id":     pat.UserID,
+		"email":  owner.Email,
+		"nama":   owner.Nama,
+		"role":   owner.Role,
+		"wid":    owner.Workspace(),
+		"pat":    pat.ID.Hex(),
+		"scopes": pat.Scopes,
+	})
+	fmt.Println("User authenticated with access token:", pat.ID.Hex())
+	return c.Next()
+}
+
+// IsAccessTokenRequest reports whether the request was authenticated with a personal access token
+func IsAccessTokenRequest(c *fiber.Ctx) bool {
+	claims, ok := c.Locals("user").(jwt.MapClaims)
+	if !ok {
+		return false
+	}
+	_, ok = claims["pat"]
+	return ok
+}
+
+// tokenScopes returns the scopes of a personal access token request, or nil for a login session
+func tokenScopes(c *fiber.Ctx) []string {
+	claims, ok := c.Locals("user").(jwt.MapClaims)
+	if !ok {
+		return nil
+	}
+	scopes, _ := claims["scopes"].([]string)
+	return scopes
+}
+
+// DenyAccessTokens keeps personal access tokens away from account security endpoints
+// (sessions, 2FA, token management), which need an interactive login.
+// It must run after Protected.
+func DenyAccessTokens() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if IsAccessTokenRequest(c) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Forbidden - Not available to personal access tokens",
+			})
+		}
+		return c.Next()
+	}
+}
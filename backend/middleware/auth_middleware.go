@@ -1,23 +1,31 @@
 package middleware
 
 import (
+	"backend/config"
+	"backend/models"
+	"backend/presence"
 	"backend/utils"
+	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// sessionTouchInterval limits how often a session's lastSeenAt is written
+const sessionTouchInterval = time.Minute
+
 // Protected middleware untuk routes yang memerlukan autentikasi
 func Protected() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Get authorization header
 		authHeader := c.Get("Authorization")
 
-		// Debug authorization header
-		fmt.Println("Auth header received:", authHeader)
-
 		// Cek apakah header authorization ada dan formatnya benar
 		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
@@ -28,15 +36,13 @@ func Protected() fiber.Handler {
 		// Extract token
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
-		// Parse dan validasi token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Validasi signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return utils.GetJWTSecret(), nil
-		})
+		// Personal access tokens are opaque and looked up in the database
+		if utils.IsPersonalAccessToken(tokenString) {
+			return authenticateAccessToken(c, tokenString)
+		}
 
+		// Parse dan validasi token
+		claims, err := utils.ParseJWT(tokenString)
 		if err != nil {
 			fmt.Println("Token validation error:", err)
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
@@ -44,16 +50,127 @@ func Protected() fiber.Handler {
 			})
 		}
 
-		// Validasi token dan extract claims
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			// Set user info in context untuk route handlers
-			c.Locals("user", claims)
-			fmt.Println("User authenticated with ID:", claims["id"])
-			return c.Next()
+		// Tokens without a jti predate revocation support and can't be revoked, so refuse them
+		jti, ok := claims["jti"].(string)
+		if !ok || jti == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Unauthorized - Invalid token claims",
+			})
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		revoked, err := config.RevokedTokenCollectionRef.CountDocuments(ctx, bson.M{"_id": jti})
+		if err != nil {
+			fmt.Println("Error checking token revocation:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to verify token",
+			})
+		}
+		if revoked > 0 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Unauthorized - Token has been revoked",
+			})
+		}
+
+		// Tokens bound to a login session die with the session
+		if sid, ok := claims["sid"].(string); ok && sid != "" {
+			active, err := touchSession(ctx, sid)
+			if err != nil {
+				fmt.Println("Error checking session:", err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to verify session",
+				})
+			}
+			if !active {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error": "Unauthorized - Session has ended",
+				})
+			}
+		}
+
+		// Deactivated and deleted accounts lose access at once, whatever tokens they still hold
+		if userID, ok := claims["id"].(string); ok {
+			disabled, err := isAccountDisabled(ctx, userID)
+			if err != nil {
+				fmt.Println("Error checking account status:", err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to verify account",
+				})
+			}
+			if disabled {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error": "Unauthorized - Account is deactivated",
+					"code":  "account_disabled",
+				})
+			}
+		}
+
+		// Set user info in context untuk route handlers
+		c.Locals("user", claims)
+		fmt.Println("User authenticated with ID:", claims["id"])
+
+		// Everything done while impersonating someone is audited
+		if IsImpersonating(c) {
+			return auditImpersonatedRequest(c)
+		}
+
+		// Request activity keeps the user's presence up to date
+		if userID, ok := claims["id"].(string); ok {
+			if err := presence.Touch(ctx, userID); err != nil {
+				fmt.Println("Error updating presence:", err)
+			}
 		}
+		return c.Next()
+	}
+}
 
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Unauthorized - Invalid token claims",
-		})
+// touchSession reports whether the session is still active and bumps its lastSeenAt
+func touchSession(ctx context.Context, sessionID string) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return false, nil
+	}
+
+	var session struct {
+		LastSeenAt time.Time  `bson:"lastSeenAt"`
+		ExpiresAt  time.Time  `bson:"expiresAt"`
+		RevokedAt  *time.Time `bson:"revokedAt"`
+	}
+	err = config.SessionCollectionRef.FindOne(ctx, bson.M{"_id": objectID}).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) {
+		return false, nil
+	}
+
+	if now.Sub(session.LastSeenAt) > sessionTouchInterval {
+		_, err = config.SessionCollectionRef.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{"$set": bson.M{"lastSeenAt": now}})
+		if err != nil {
+			fmt.Println("Error updating session last seen:", err)
+		}
+	}
+	return true, nil
+}
+
+// isAccountDisabled reports whether the user has been deactivated or deleted.
+// Users that no longer exist at all count as disabled too.
+func isAccountDisabled(ctx context.Context, userID string) (bool, error) {
+	var user models.User
+	err := config.UserCollectionRef.FindOne(ctx, models.UserIDFilter(userID),
+		options.FindOne().SetProjection(bson.M{"accountStatus": 1})).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
 	}
+	return user.IsDisabled(), nil
 }
@@ -0,0 +1,241 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Permissions checked by RequirePermission and by handlers that enforce ownership.
+// The ":self"/":own" variants only cover the caller's own profile or meetings,
+// the ":any" variants cover everybody else's. Team leads manage their own teams
+// without a permission; teams:manage:any covers every team.
+const (
+	PermUsersRead        = "users:read"
+	PermUsersCreate      = "users:create"
+	PermUsersUpdateSelf  = "users:update:self"
+	PermUsersUpdateAny   = "users:update:any"
+	PermUsersManageRoles = "users:manage_roles"
+	PermUsersDelete      = "users:delete"
+	PermUsersDeactivate  = "users:deactivate"
+	PermUsersUnlock      = "users:unlock"
+	PermUsersInvite      = "users:invite"
+	PermUsersImpersonate = "users:impersonate"
+
+	PermMeetingsRead      = "meetings:read"
+	PermMeetingsCreate    = "meetings:create"
+	PermMeetingsUpdateOwn = "meetings:update:own"
+	PermMeetingsUpdateAny = "meetings:update:any"
+	PermMeetingsDeleteOwn = "meetings:delete:own"
+	PermMeetingsDeleteAny = "meetings:delete:any"
+
+	PermTeamsCreate    = "teams:create"
+	PermTeamsManageAny = "teams:manage:any"
+
+	PermUploadsCreate = "uploads:create"
+
+	PermSettingsManage = "settings:manage"
+
+	// Place new accounts in another workspace than the caller's. This reaches across
+	// workspaces, so "*" doesn't include it: the policy file has to grant it by name.
+	PermWorkspacesManage = "workspaces:manage"
+)
+
+// knownPermissions lists every permission, in the order they are documented above
+var knownPermissions = []string{
+	PermUsersRead, PermUsersCreate, PermUsersUpdateSelf, PermUsersUpdateAny,
+	PermUsersManageRoles, PermUsersDelete, PermUsersDeactivate, PermUsersUnlock, PermUsersInvite,
+	PermUsersImpersonate,
+	PermMeetingsRead, PermMeetingsCreate, PermMeetingsUpdateOwn, PermMeetingsUpdateAny,
+	PermMeetingsDeleteOwn, PermMeetingsDeleteAny,
+	PermTeamsCreate, PermTeamsManageAny,
+	PermUploadsCreate,
+	PermSettingsManage,
+	PermWorkspacesManage,
+}
+
+// IsKnownPermission reports whether the permission exists
+func IsKnownPermission(permission string) bool {
+	for _, p := range knownPermissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleAdmin is granted every permission within its workspace by the default policy
+const RoleAdmin = "Admin"
+
+// DefaultRole is assigned to new accounts and used for roles missing from the policy
+const DefaultRole = "Team Member"
+
+// allPermissions is the wildcard a role can be granted in the policy file
+const allPermissions = "*"
+
+// isWildcardPermission reports whether "*" grants the permission. Workspace admins
+// get "*", so it stops at the boundary of their workspace.
+func isWildcardPermission(permission string) bool {
+	return permission != PermWorkspacesManage
+}
+
+var memberPermissions = []string{
+	PermUsersRead,
+	PermUsersUpdateSelf,
+	PermMeetingsRead,
+	PermMeetingsCreate,
+	PermMeetingsUpdateOwn,
+	PermMeetingsDeleteOwn,
+	PermUploadsCreate,
+}
+
+// rolePermissions maps a role name to the permissions it grants.
+// It can be replaced at startup with LoadRolePermissions.
+var rolePermissions = map[string][]string{
+	RoleAdmin:     {allPermissions},
+	"Team Leader": append([]string{PermMeetingsUpdateAny, PermMeetingsDeleteAny, PermTeamsCreate}, memberPermissions...),
+	DefaultRole:   memberPermissions,
+}
+
+// LoadRolePermissions replaces the default role→permission map with the JSON
+// file in RBAC_POLICY_FILE, e.g. {"Admin": ["*"], "Team Member": ["users:read"]}
+func LoadRolePermissions() {
+	path := os.Getenv("RBAC_POLICY_FILE")
+	if path == "" {
+		log.Println("⚠️ RBAC_POLICY_FILE not set, using default role permissions")
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal("❌ Failed to read RBAC policy file:", err)
+	}
+
+	var policy map[string][]string
+	if err := json.Unmarshal(data, &policy); err != nil {
+		log.Fatal("❌ Failed to parse RBAC policy file:", err)
+	}
+	if _, ok := policy[DefaultRole]; !ok {
+		log.Fatalf("❌ RBAC policy must define the %q role", DefaultRole)
+	}
+
+	rolePermissions = policy
+	log.Printf("✅ Loaded RBAC policy for %d roles from %s", len(policy), path)
+}
+
+// IsKnownRole reports whether the role is defined in the policy
+func IsKnownRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission reports whether the role grants the permission.
+// Roles that aren't in the policy get the permissions of DefaultRole.
+func HasPermission(role, permission string) bool {
+	permissions, ok := rolePermissions[role]
+	if !ok {
+		permissions = rolePermissions[DefaultRole]
+	}
+
+	for _, p := range permissions {
+		if p == permission || (p == allPermissions && isWildcardPermission(permission)) {
+			return true
+		}
+	}
+	return false
+}
+
+// CurrentUserID returns the user ID carried by the authenticated request
+func CurrentUserID(c *fiber.Ctx) string {
+	claims, ok := c.Locals("user").(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	id, _ := claims["id"].(string)
+	return id
+}
+
+// CurrentWorkspaceID returns the workspace carried by the authenticated request.
+// Tokens from before workspaces existed belong to the default workspace.
+func CurrentWorkspaceID(c *fiber.Ctx) string {
+	claims, ok := c.Locals("user").(jwt.MapClaims)
+	if !ok {
+		return models.DefaultWorkspaceID
+	}
+	wid, _ := claims["wid"].(string)
+	return models.WorkspaceOrDefault(wid)
+}
+
+// CurrentRole returns the role carried by the authenticated request
+func CurrentRole(c *fiber.Ctx) string {
+	claims, ok := c.Locals("user").(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	role, _ := claims["role"].(string)
+	if role == "" {
+		role = DefaultRole
+	}
+	return role
+}
+
+// Can reports whether the authenticated caller has the permission.
+// Unauthenticated requests have no permissions at all, and personal access
+// tokens are further limited to their scopes.
+func Can(c *fiber.Ctx, permission string) bool {
+	if _, ok := c.Locals("user").(jwt.MapClaims); !ok {
+		return false
+	}
+	if !HasPermission(CurrentRole(c), permission) {
+		return false
+	}
+	if !IsAccessTokenRequest(c) {
+		return true
+	}
+	for _, scope := range tokenScopes(c) {
+		if scope == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RequirePermission only lets the request through when the caller has every listed permission.
+// It must run after Protected.
+func RequirePermission(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		for _, permission := range permissions {
+			if !Can(c, permission) {
+				fmt.Printf("Permission %s denied for role %s\n", permission, CurrentRole(c))
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error": "Forbidden - Missing permission " + permission,
+				})
+			}
+		}
+		return c.Next()
+	}
+}
+
+// RequireRole only lets the request through when the caller has one of the listed roles.
+// It must run after Protected.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := c.Locals("user").(jwt.MapClaims); ok {
+			role := CurrentRole(c)
+			for _, r := range roles {
+				if r == role {
+					return c.Next()
+				}
+			}
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Forbidden - Insufficient role",
+		})
+	}
+}
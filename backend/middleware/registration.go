@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"backend/config"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireOpenRegistration blocks self sign-up routes when OPEN_REGISTRATION is off
+func RequireOpenRegistration() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !config.OpenRegistration() {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Registrasi ditutup, gunakan undangan dari administrator",
+				"code":  "registration_closed",
+			})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audit actions
+const (
+	AuditImpersonationStart   = "impersonation.start"
+	AuditImpersonatedRequest  = "impersonation.request"
+	AuditImpersonationBlocked = "impersonation.blocked"
+	AuditUsersImport          = "users.import"
+	AuditUserDeactivate       = "user.deactivate"
+	AuditUserDelete           = "user.delete"
+	AuditUserRestore          = "user.restore"
+	AuditUserPurge            = "user.purge"
+)
+
+// AuditLog is an append-only record of a sensitive action.
+// ActorID is who did it; SubjectID is the user it was done as or to.
+type AuditLog struct {
+	ID        primitive.ObjectID     `json:"id" bson:"_id,omitempty"`
+	Action    string                 `json:"action" bson:"action"`
+	ActorID   string                 `json:"actorId" bson:"actorId"`
+	SubjectID string                 `json:"subjectId,omitempty" bson:"subjectId,omitempty"`
+	Method    string                 `json:"method,omitempty" bson:"method,omitempty"`
+	Path      string                 `json:"path,omitempty" bson:"path,omitempty"`
+	Status    int                    `json:"status,omitempty" bson:"status,omitempty"`
+	IP        string                 `json:"ip,omitempty" bson:"ip,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty" bson:"details,omitempty"`
+	CreatedAt time.Time              `json:"createdAt" bson:"createdAt"`
+}
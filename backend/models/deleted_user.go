@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// DeletedUser is what remains of a purged account: enough to keep showing its
+// name in meetings it created or joined. The ID is the _id the user had.
+type DeletedUser struct {
+	ID          interface{} `json:"id" bson:"_id"`
+	Nama        string      `json:"nama" bson:"nama"`
+	WorkspaceID string      `json:"workspaceId,omitempty" bson:"workspaceId,omitempty"`
+	DeletedAt   *time.Time  `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+	PurgedAt    time.Time   `json:"purgedAt" bson:"purgedAt"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Invitation states
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationRevoked  = "revoked"
+)
+
+// Invitation lets an administrator onboard someone with a given role.
+// The invitee receives a signed link, sets their own password when accepting and
+// joins the workspace (and team, if any) of the invitation.
+type Invitation struct {
+	ID          primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	Email       string              `json:"email" bson:"email"`
+	Nama        string              `json:"nama,omitempty" bson:"nama,omitempty"` // suggested name, the invitee may change it
+	Role        string              `json:"role" bson:"role"`
+	WorkspaceID string              `json:"workspaceId,omitempty" bson:"workspaceId,omitempty"`
+	Bio         string              `json:"bio,omitempty" bson:"bio,omitempty"`
+	TeamID      *primitive.ObjectID `json:"teamId,omitempty" bson:"teamId,omitempty"`
+	Status      string              `json:"status" bson:"status"`
+	InvitedBy   string              `json:"invitedBy" bson:"invitedBy"`
+	CreatedAt   time.Time           `json:"createdAt" bson:"createdAt"`
+	ExpiresAt   time.Time           `json:"expiresAt" bson:"expiresAt"`
+	AcceptedAt  *time.Time          `json:"acceptedAt,omitempty" bson:"acceptedAt,omitempty"`
+	UserID      string              `json:"userId,omitempty" bson:"userId,omitempty"`
+}
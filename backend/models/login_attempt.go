@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// LoginAttempt counts recent failed logins for one key, either "ip:<address>" or "account:<email>".
+// The document expires on its own once no failure happened for a whole attempt window.
+type LoginAttempt struct {
+	Key           string     `json:"key" bson:"_id"`
+	Failures      int        `json:"failures" bson:"failures"`
+	LastFailureAt time.Time  `json:"lastFailureAt" bson:"lastFailureAt"`
+	LockedUntil   *time.Time `json:"lockedUntil,omitempty" bson:"lockedUntil,omitempty"`
+	ExpiresAt     time.Time  `json:"expiresAt" bson:"expiresAt"`
+}
@@ -17,17 +17,21 @@ type Meeting struct {
 	CreatedAt    time.Time            `json:"createdAt" bson:"createdAt"`
 	AllMembers   bool                 `json:"allMembers" bson:"allMembers"`
 	Participants []primitive.ObjectID `json:"participants" bson:"participants"`
+	TeamID       *primitive.ObjectID  `json:"teamId,omitempty" bson:"teamId,omitempty"` // invites every current member of the team
+	WorkspaceID  string               `json:"workspaceId,omitempty" bson:"workspaceId,omitempty"`
 }
 
 type MeetingResponse struct {
-	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Title        string             `json:"title" bson:"title"`
-	Description  string             `json:"description" bson:"description"`
-	Date         string             `json:"date" bson:"date"`
-	Time         string             `json:"time" bson:"time"`
-	Duration     int                `json:"duration" bson:"duration"`
-	CreatedBy    User               `json:"createdBy" bson:"createdBy"`
-	CreatedAt    time.Time          `json:"createdAt" bson:"createdAt"`
-	AllMembers   bool               `json:"allMembers" bson:"allMembers"`
-	Participants []User             `json:"participants" bson:"participants"`
+	ID           primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	Title        string              `json:"title" bson:"title"`
+	Description  string              `json:"description" bson:"description"`
+	Date         string              `json:"date" bson:"date"`
+	Time         string              `json:"time" bson:"time"`
+	Duration     int                 `json:"duration" bson:"duration"`
+	CreatedBy    User                `json:"createdBy" bson:"createdBy"`
+	CreatedAt    time.Time           `json:"createdAt" bson:"createdAt"`
+	AllMembers   bool                `json:"allMembers" bson:"allMembers"`
+	Participants []User              `json:"participants" bson:"participants"`
+	TeamID       *primitive.ObjectID `json:"teamId,omitempty" bson:"teamId,omitempty"`
+	WorkspaceID  string              `json:"workspaceId,omitempty" bson:"workspaceId,omitempty"`
 }
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// OIDCState remembers an SSO login between the redirect to the IdP and the callback.
+// It is deleted when the callback consumes it.
+type OIDCState struct {
+	State        string    `bson:"_id"`
+	Nonce        string    `bson:"nonce"`
+	CodeVerifier string    `bson:"codeVerifier"`
+	CreatedAt    time.Time `bson:"createdAt"`
+	ExpiresAt    time.Time `bson:"expiresAt"`
+}
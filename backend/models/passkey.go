@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Passkey is a WebAuthn credential registered by a user.
+// ID is the base64url encoded credential ID.
+type Passkey struct {
+	ID              string     `json:"id" bson:"id"`
+	Name            string     `json:"name" bson:"name"`
+	PublicKey       []byte     `json:"-" bson:"publicKey"`
+	AttestationType string     `json:"-" bson:"attestationType,omitempty"`
+	Transports      []string   `json:"transports,omitempty" bson:"transports,omitempty"`
+	AAGUID          []byte     `json:"-" bson:"aaguid,omitempty"`
+	SignCount       uint32     `json:"-" bson:"signCount"`
+	CloneWarning    bool       `json:"cloneWarning,omitempty" bson:"cloneWarning,omitempty"`
+	UserVerified    bool       `json:"-" bson:"userVerified"`
+	BackupEligible  bool       `json:"backupEligible" bson:"backupEligible"`
+	BackupState     bool       `json:"backupState" bson:"backupState"`
+	CreatedAt       time.Time  `json:"createdAt" bson:"createdAt"`
+	LastUsedAt      *time.Time `json:"lastUsedAt,omitempty" bson:"lastUsedAt,omitempty"`
+}
+
+// Kinds of WebAuthn ceremonies
+const (
+	WebAuthnRegistration = "registration"
+	WebAuthnLogin        = "login"
+)
+
+// WebAuthnSession keeps the challenge of a WebAuthn ceremony between its begin and finish calls.
+// Data is the JSON encoded session data of the WebAuthn library.
+type WebAuthnSession struct {
+	ID        string    `bson:"_id"`
+	Kind      string    `bson:"kind"`
+	UserID    string    `bson:"userId,omitempty"`
+	Data      string    `bson:"data"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Statuses a user can set on themselves
+const (
+	CustomStatusDoNotDisturb = "dnd"
+	CustomStatusBusy         = "busy"
+	CustomStatusInMeeting    = "in_meeting"
+	CustomStatusOutOfOffice  = "out_of_office"
+)
+
+// IsCustomStatus reports whether s is a status users can set
+func IsCustomStatus(s string) bool {
+	switch s {
+	case CustomStatusDoNotDisturb, CustomStatusBusy, CustomStatusInMeeting, CustomStatusOutOfOffice:
+		return true
+	}
+	return false
+}
+
+// CustomStatus is a status set by the user, like "Do not disturb", with an optional expiry
+type CustomStatus struct {
+	Status    string     `json:"status" bson:"status"`
+	Message   string     `json:"message,omitempty" bson:"message,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+}
+
+// Expired reports whether the status has run out
+func (s CustomStatus) Expired(now time.Time) bool {
+	return s.ExpiresAt != nil && !now.Before(*s.ExpiresAt)
+}
+
+// Presence is a user's derived presence and custom status
+type Presence struct {
+	UserID       string        `json:"userId"`
+	Status       string        `json:"status"`
+	LastActive   time.Time     `json:"lastActive,omitempty"`
+	CustomStatus *CustomStatus `json:"customStatus,omitempty"`
+}
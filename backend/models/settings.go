@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// SecuritySettingsID is the _id of the single security settings document
+const SecuritySettingsID = "security"
+
+// SecuritySettings holds security options that admins can change at runtime
+type SecuritySettings struct {
+	ID                    string    `json:"-" bson:"_id"`
+	RequireTwoFactorRoles []string  `json:"requireTwoFactorRoles" bson:"requireTwoFactorRoles"`
+	UpdatedAt             time.Time `json:"updatedAt,omitempty" bson:"updatedAt,omitempty"`
+	UpdatedBy             string    `json:"updatedBy,omitempty" bson:"updatedBy,omitempty"`
+}
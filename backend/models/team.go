@@ -0,0 +1,31 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Team is a named group of users. Leads are members who can manage the team.
+type Team struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name        string             `json:"name" bson:"name"`
+	Description string             `json:"description,omitempty" bson:"description,omitempty"`
+	Members     []string           `json:"members" bson:"members"`
+	Leads       []string           `json:"leads" bson:"leads"`
+	WorkspaceID string             `json:"workspaceId,omitempty" bson:"workspaceId,omitempty"`
+	CreatedBy   string             `json:"createdBy" bson:"createdBy"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// HasMember reports whether the user belongs to the team
+func (t Team) HasMember(userID string) bool {
+	return slices.Contains(t.Members, userID)
+}
+
+// HasLead reports whether the user leads the team
+func (t Team) HasLead(userID string) bool {
+	return slices.Contains(t.Leads, userID)
+}
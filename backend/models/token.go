@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken is a long-lived, single-use token exchanged for a new access token.
+// Only the SHA-256 hash of the token is stored.
+type RefreshToken struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID    string             `json:"userId" bson:"userId"`
+	SessionID string             `json:"sessionId,omitempty" bson:"sessionId,omitempty"`
+	TokenHash string             `json:"-" bson:"tokenHash"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time          `json:"expiresAt" bson:"expiresAt"`
+	RevokedAt *time.Time         `json:"revokedAt,omitempty" bson:"revokedAt,omitempty"`
+	// RevokedReason tells rotated tokens from ones ended on purpose; only a rotated one coming back is reuse
+	RevokedReason string `json:"revokedReason,omitempty" bson:"revokedReason,omitempty"`
+}
+
+// Why a refresh token stopped working. Tokens revoked before reasons were recorded have none
+// and are treated as rotated.
+const (
+	RefreshTokenRotated = "rotated" // exchanged for a new pair at /auth/refresh
+	RefreshTokenRevoked = "revoked" // ended by a logout or a session revocation
+)
+
+// RevokedToken marks an access token (by its jti) as no longer valid.
+// Entries expire together with the token they revoke.
+type RevokedToken struct {
+	JTI       string    `json:"jti" bson:"_id"`
+	UserID    string    `json:"userId" bson:"userId"`
+	RevokedAt time.Time `json:"revokedAt" bson:"revokedAt"`
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+}
+
+// Purposes of one-time tokens
+const (
+	TokenPurposePasswordReset = "password_reset"
+	TokenPurposeMagicLogin    = "magic_login"
+)
+
+// OneTimeToken is a single-use, expiring token sent to a user by email.
+// Only the SHA-256 hash of the token is stored.
+type OneTimeToken struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID    string             `json:"userId" bson:"userId"`
+	Purpose   string             `json:"purpose" bson:"purpose"`
+	TokenHash string             `json:"-" bson:"tokenHash"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time          `json:"expiresAt" bson:"expiresAt"`
+	UsedAt    *time.Time         `json:"usedAt,omitempty" bson:"usedAt,omitempty"`
+}
@@ -2,18 +2,100 @@ package models
 
 import (
 	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type User struct {
 	ID           string    `json:"id,omitempty" bson:"_id,omitempty"`
 	Nama         string    `json:"nama" bson:"nama"`
 	Email        string    `json:"email" bson:"email"`
-	Password     string    `json:"password" bson:"password"`
+	Password     string    `json:"-" bson:"password"`
 	Role         string    `json:"role,omitempty" bson:"role,omitempty"`
 	Status       string    `json:"status,omitempty" bson:"status,omitempty"`
 	LastActive   time.Time `json:"lastActive,omitempty" bson:"lastActive,omitempty"`
 	Bio          string    `json:"bio,omitempty" bson:"bio,omitempty"`
 	ProfileImage string    `json:"profileImage,omitempty" bson:"profileImage,omitempty"`
+
+	// URLs of the square avatar sizes generated from the profile image, keyed by edge length ("64", "128", ...)
+	Avatars map[string]string `json:"avatars,omitempty" bson:"avatars,omitempty"`
+
+	// Workspace the user belongs to; users only see people and meetings of their own workspace
+	WorkspaceID string `json:"workspaceId,omitempty" bson:"workspaceId,omitempty"`
+
+	// Status the user set themselves, shown next to their presence
+	CustomStatus *CustomStatus `json:"customStatus,omitempty" bson:"customStatus,omitempty"`
+
+	// Hashes of earlier passwords, newest first, so they can't be reused.
+	// MustChangePassword is set on temporary passwords handed out by an administrator.
+	PasswordHistory    []string   `json:"-" bson:"passwordHistory,omitempty"`
+	PasswordChangedAt  *time.Time `json:"-" bson:"passwordChangedAt,omitempty"`
+	MustChangePassword bool       `json:"-" bson:"mustChangePassword,omitempty"`
+
+	AccountStatus      string     `json:"accountStatus,omitempty" bson:"accountStatus,omitempty"`
+	EmailVerifiedAt    *time.Time `json:"emailVerifiedAt,omitempty" bson:"emailVerifiedAt,omitempty"`
+	VerificationSentAt *time.Time `json:"-" bson:"verificationSentAt,omitempty"`
+	// PendingEmail is an address the user asked to switch to; Email changes once the link sent there is used
+	PendingEmail string `json:"-" bson:"pendingEmail,omitempty"`
+
+	// When the account was switched off. Deleted accounts are purged after a retention window.
+	DeactivatedAt *time.Time `json:"deactivatedAt,omitempty" bson:"deactivatedAt,omitempty"`
+	DeletedAt     *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+
+	// TOTP two-factor authentication. Secrets and recovery code hashes never leave the server.
+	TwoFactorEnabled       bool     `json:"-" bson:"twoFactorEnabled,omitempty"`
+	TwoFactorSecret        string   `json:"-" bson:"twoFactorSecret,omitempty"`
+	TwoFactorPendingSecret string   `json:"-" bson:"twoFactorPendingSecret,omitempty"`
+	TwoFactorLastStep      int64    `json:"-" bson:"twoFactorLastStep,omitempty"`
+	RecoveryCodes          []string `json:"-" bson:"recoveryCodes,omitempty"`
+
+	// WebAuthn credentials. A user with passkeys may have no password at all.
+	Passkeys []Passkey `json:"-" bson:"passkeys,omitempty"`
+
+	// Link to an OpenID Connect identity, set on the first SSO login
+	OIDCIssuer  string `json:"-" bson:"oidcIssuer,omitempty"`
+	OIDCSubject string `json:"-" bson:"oidcSubject,omitempty"`
+}
+
+// Account states. Users created before email verification have no state and count as active.
+// Deactivated and deleted accounts can't sign in; both can be restored, until a deleted one is purged.
+const (
+	AccountStatusActive              = "active"
+	AccountStatusPendingVerification = "pending_verification"
+	AccountStatusDeactivated         = "deactivated"
+	AccountStatusDeleted             = "deleted"
+)
+
+// AccountState returns the account status, AccountStatusActive for accounts without one
+func (u User) AccountState() string {
+	if u.AccountStatus == "" {
+		return AccountStatusActive
+	}
+	return u.AccountStatus
+}
+
+// IsDisabled reports whether the account has been deactivated or deleted
+func (u User) IsDisabled() bool {
+	return u.AccountStatus == AccountStatusDeactivated || u.AccountStatus == AccountStatusDeleted
+}
+
+// IsPendingVerification reports whether the user still has to confirm their email
+func (u User) IsPendingVerification() bool {
+	return u.AccountStatus == AccountStatusPendingVerification
+}
+
+// Workspace returns the user's workspace, DefaultWorkspaceID for accounts from before workspaces
+func (u User) Workspace() string {
+	return WorkspaceOrDefault(u.WorkspaceID)
+}
+
+// ActiveCustomStatus returns the user's custom status unless it has expired
+func (u User) ActiveCustomStatus(now time.Time) *CustomStatus {
+	if u.CustomStatus == nil || u.CustomStatus.Expired(now) {
+		return nil
+	}
+	return u.CustomStatus
 }
 
 // UserResponse is a model without password for returning to clients
@@ -26,4 +108,19 @@ type UserResponse struct {
 	LastActive   time.Time `json:"lastActive,omitempty"`
 	Bio          string    `json:"bio,omitempty"`
 	ProfileImage string    `json:"profileImage,omitempty"`
+
+	Avatars map[string]string `json:"avatars,omitempty"`
+
+	CustomStatus  *CustomStatus `json:"customStatus,omitempty"`
+	AccountStatus string        `json:"accountStatus,omitempty"`
+
+	TwoFactorEnabled bool `json:"twoFactorEnabled,omitempty"`
+}
+
+// UserIDFilter matches a user whose _id is stored either as an ObjectID or as a plain string
+func UserIDFilter(userID string) bson.M {
+	if objectID, err := primitive.ObjectIDFromHex(userID); err == nil {
+		return bson.M{"_id": bson.M{"$in": []interface{}{objectID, userID}}}
+	}
+	return bson.M{"_id": userID}
 }
@@ -0,0 +1,13 @@
+package models
+
+// DefaultWorkspaceID is the workspace of every user, meeting and team created
+// before workspaces existed, and of self-registered accounts
+const DefaultWorkspaceID = "default"
+
+// WorkspaceOrDefault maps an empty workspace ID to DefaultWorkspaceID
+func WorkspaceOrDefault(workspaceID string) string {
+	if workspaceID == "" {
+		return DefaultWorkspaceID
+	}
+	return workspaceID
+}
@@ -0,0 +1,359 @@
+// Package oidc implements the relying-party side of OpenID Connect
+// (authorization code flow with PKCE) against a discovered identity provider.
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrDisabled is returned when no identity provider is configured
+var ErrDisabled = errors.New("oidc: single sign-on is not configured")
+
+// Config describes the identity provider and how its users map onto CoEmotion accounts
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// GroupsClaim is the ID token claim listing the user's IdP groups
+	GroupsClaim string
+	// RoleMapping maps IdP group names to CoEmotion roles
+	RoleMapping map[string]string
+	// PostLoginRedirect is the frontend URL that receives the tokens after login.
+	// When empty the callback answers with JSON instead.
+	PostLoginRedirect string
+}
+
+// ConfigFromEnv reads the OIDC_* variables. ok is false when OIDC_ISSUER is unset.
+//
+//	OIDC_ISSUER, OIDC_CLIENT_ID, OIDC_CLIENT_SECRET, OIDC_REDIRECT_URL,
+//	OIDC_SCOPES ("openid email profile"), OIDC_GROUPS_CLAIM ("groups"),
+//	OIDC_ROLE_MAPPING ("idp-admins=Admin,eng-leads=Team Leader"),
+//	OIDC_POST_LOGIN_REDIRECT ("http://localhost:5173/sso/callback")
+func ConfigFromEnv() (Config, bool) {
+	cfg := Config{
+		Issuer:            strings.TrimRight(os.Getenv("OIDC_ISSUER"), "/"),
+		ClientID:          os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret:      os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:       os.Getenv("OIDC_REDIRECT_URL"),
+		GroupsClaim:       os.Getenv("OIDC_GROUPS_CLAIM"),
+		PostLoginRedirect: os.Getenv("OIDC_POST_LOGIN_REDIRECT"),
+		RoleMapping:       map[string]string{},
+	}
+	if cfg.Issuer == "" {
+		return cfg, false
+	}
+
+	if cfg.RedirectURL == "" {
+		cfg.RedirectURL = "http://localhost:8080/auth/oidc/callback"
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+
+	scopes := os.Getenv("OIDC_SCOPES")
+	if scopes == "" {
+		scopes = "openid email profile"
+	}
+	cfg.Scopes = strings.Fields(scopes)
+
+	for _, pair := range strings.Split(os.Getenv("OIDC_ROLE_MAPPING"), ",") {
+		group, role, found := strings.Cut(pair, "=")
+		if found && strings.TrimSpace(group) != "" && strings.TrimSpace(role) != "" {
+			cfg.RoleMapping[strings.TrimSpace(group)] = strings.TrimSpace(role)
+		}
+	}
+
+	return cfg, true
+}
+
+// discoveryDocument is the subset of /.well-known/openid-configuration we use
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider talks to one identity provider. It is safe for concurrent use.
+type Provider struct {
+	Config     Config
+	HTTPClient *http.Client
+
+	discovery discoveryDocument
+
+	mu          sync.RWMutex
+	keys        map[string]interface{}
+	keysFetched time.Time
+}
+
+// NewProvider fetches the discovery document of cfg.Issuer
+func NewProvider(ctx context.Context, cfg Config, client *http.Client) (*Provider, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	p := &Provider{Config: cfg, HTTPClient: client}
+
+	if err := p.getJSON(ctx, cfg.Issuer+"/.well-known/openid-configuration", &p.discovery); err != nil {
+		return nil, fmt.Errorf("oidc: discovery failed: %w", err)
+	}
+	if strings.TrimRight(p.discovery.Issuer, "/") != cfg.Issuer {
+		return nil, fmt.Errorf("oidc: discovery issuer %q does not match %q", p.discovery.Issuer, cfg.Issuer)
+	}
+	if p.discovery.AuthorizationEndpoint == "" || p.discovery.TokenEndpoint == "" || p.discovery.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery document is missing endpoints")
+	}
+	return p, nil
+}
+
+var (
+	defaultProvider *Provider
+	defaultMu       sync.Mutex
+)
+
+// Default returns the provider configured through the environment.
+// Discovery happens on first use and is retried on later calls if the IdP was unreachable.
+func Default(ctx context.Context) (*Provider, error) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	if defaultProvider != nil {
+		return defaultProvider, nil
+	}
+
+	cfg, ok := ConfigFromEnv()
+	if !ok {
+		return nil, ErrDisabled
+	}
+
+	p, err := NewProvider(ctx, cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("✅ OIDC provider discovered: %s", cfg.Issuer)
+	defaultProvider = p
+	return p, nil
+}
+
+// NewPKCE returns a code verifier and its S256 code challenge (RFC 7636)
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// AuthCodeURL is where the browser is sent to log in at the IdP
+func (p *Provider) AuthCodeURL(state, nonce, codeChallenge string) string {
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.Config.ClientID)
+	params.Set("redirect_uri", p.Config.RedirectURL)
+	params.Set("scope", strings.Join(p.Config.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", codeChallenge)
+	params.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.discovery.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.discovery.AuthorizationEndpoint + sep + params.Encode()
+}
+
+// Exchange trades an authorization code for the raw ID token
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.Config.RedirectURL)
+	form.Set("client_id", p.Config.ClientID)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.Config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.Config.ClientID), url.QueryEscape(p.Config.ClientSecret))
+	}
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("oidc: invalid token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("oidc: token endpoint returned %d: %s %s", resp.StatusCode, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("oidc: token response has no id_token")
+	}
+	return body.IDToken, nil
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of an ID token
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(rawIDToken,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return p.verificationKey(ctx, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384"}),
+		jwt.WithIssuer(p.Config.Issuer),
+		jwt.WithAudience(p.Config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: invalid id_token: %w", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("oidc: invalid id_token claims")
+	}
+	if claims["nonce"] != nonce {
+		return nil, errors.New("oidc: id_token nonce mismatch")
+	}
+	return claims, nil
+}
+
+// RoleForGroups returns the mapped role of the first matching group, or "" when none match
+func (p *Provider) RoleForGroups(groups []string) string {
+	for _, group := range groups {
+		if role, ok := p.Config.RoleMapping[group]; ok {
+			return role
+		}
+	}
+	return ""
+}
+
+// MapsToRole reports whether some IdP group maps to the role, i.e. whether the IdP manages it
+func (p *Provider) MapsToRole(role string) bool {
+	for _, mapped := range p.Config.RoleMapping {
+		if mapped == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Groups reads the configured groups claim, which IdPs send either as a list or a single string
+func (p *Provider) Groups(claims jwt.MapClaims) []string {
+	switch v := claims[p.Config.GroupsClaim].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		groups := make([]string, 0, len(v))
+		for _, g := range v {
+			if s, ok := g.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+		return groups
+	default:
+		return nil
+	}
+}
+
+// verificationKey finds the IdP key for kid, refetching the JWKS once in case the IdP rotated keys
+func (p *Provider) verificationKey(ctx context.Context, kid string) (interface{}, error) {
+	p.mu.RLock()
+	key, ok := p.keys[kid]
+	fresh := time.Since(p.keysFetched) < time.Minute
+	p.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	if fresh && p.keys != nil {
+		return nil, fmt.Errorf("oidc: unknown signing key %q", kid)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.discovery.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("oidc: fetching JWKS failed: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			log.Printf("⚠️ Skipping IdP key %q: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.keysFetched = time.Now()
+	p.mu.Unlock()
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	// A single-key JWKS without kids is common with small IdPs
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("oidc: unknown signing key %q", kid)
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
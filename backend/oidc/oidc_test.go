@@ -0,0 +1,153 @@
+package oidc_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/oidc"
+	"backend/oidc/oidctest"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newProvider(t *testing.T, idp *oidctest.IdP) *oidc.Provider {
+	t.Helper()
+	provider, err := oidc.NewProvider(context.Background(), oidc.Config{
+		Issuer:       idp.Issuer(),
+		ClientID:     idp.ClientID,
+		ClientSecret: idp.ClientSecret,
+		RedirectURL:  "http://localhost:8080/auth/oidc/callback",
+		Scopes:       []string{"openid", "email", "profile"},
+		GroupsClaim:  "groups",
+		RoleMapping:  map[string]string{"idp-admins": "Admin", "eng-leads": "Team Leader"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	return provider
+}
+
+func TestLoginFlow(t *testing.T) {
+	idp := oidctest.New(t, "coemotion", "s3cret")
+	provider := newProvider(t, idp)
+	ctx := context.Background()
+
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state, err := idp.Authorize(provider.AuthCodeURL("state-1", "nonce-1", challenge), jwt.MapClaims{
+		"sub":            "user-1",
+		"email":          "ana@example.com",
+		"email_verified": true,
+		"groups":         []string{"eng-leads"},
+	})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if state != "state-1" {
+		t.Fatalf("state = %q, want state-1", state)
+	}
+
+	rawIDToken, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, "nonce-1")
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if claims["sub"] != "user-1" || claims["email"] != "ana@example.com" {
+		t.Fatalf("unexpected claims %v", claims)
+	}
+	if role := provider.RoleForGroups(provider.Groups(claims)); role != "Team Leader" {
+		t.Fatalf("role = %q, want Team Leader", role)
+	}
+
+	// Codes are single-use
+	if _, err := provider.Exchange(ctx, code, verifier); err == nil {
+		t.Fatal("a code was exchanged twice")
+	}
+}
+
+func TestExchangeRequiresTheCodeVerifier(t *testing.T) {
+	idp := oidctest.New(t, "coemotion", "s3cret")
+	provider := newProvider(t, idp)
+
+	_, challenge, _ := oidc.NewPKCE()
+	otherVerifier, _, _ := oidc.NewPKCE()
+	code, _, err := idp.Authorize(provider.AuthCodeURL("state", "nonce", challenge), jwt.MapClaims{"sub": "user-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Exchange(context.Background(), code, otherVerifier); err == nil {
+		t.Fatal("code exchanged with the wrong code_verifier")
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	idp := oidctest.New(t, "coemotion", "")
+	provider := newProvider(t, idp)
+	now := time.Now()
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   idp.Issuer(),
+			"aud":   "coemotion",
+			"sub":   "user-1",
+			"nonce": "nonce",
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Minute).Unix(),
+		}
+	}
+	cases := map[string]func(jwt.MapClaims){
+		"other audience": func(c jwt.MapClaims) { c["aud"] = "someone-else" },
+		"other issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+		"expired":        func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Hour).Unix() },
+		"no expiry":      func(c jwt.MapClaims) { delete(c, "exp") },
+		"wrong nonce":    func(c jwt.MapClaims) { c["nonce"] = "replayed" },
+	}
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			claims := valid()
+			mutate(claims)
+			raw, err := idp.SignIDToken(claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := provider.VerifyIDToken(context.Background(), raw, "nonce"); err == nil {
+				t.Fatal("token accepted")
+			}
+		})
+	}
+
+	t.Run("unsigned", func(t *testing.T) {
+		raw, err := jwt.NewWithClaims(jwt.SigningMethodNone, valid()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := provider.VerifyIDToken(context.Background(), raw, "nonce"); err == nil {
+			t.Fatal("unsigned token accepted")
+		}
+	})
+}
+
+func TestNewProviderChecksTheIssuer(t *testing.T) {
+	idp := oidctest.New(t, "coemotion", "")
+	_, err := oidc.NewProvider(context.Background(), oidc.Config{Issuer: idp.Issuer() + "/other"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "discovery") {
+		t.Fatalf("err = %v, want a discovery error", err)
+	}
+}
+
+func TestMapsToRole(t *testing.T) {
+	provider := &oidc.Provider{Config: oidc.Config{RoleMapping: map[string]string{"idp-admins": "Admin"}}}
+	if !provider.MapsToRole("Admin") {
+		t.Fatal("Admin is handed out by the mapping")
+	}
+	if provider.MapsToRole("Team Leader") {
+		t.Fatal("Team Leader is not in the mapping")
+	}
+}
@@ -0,0 +1,102 @@
+// Package presence derives whether users are online from their activity.
+//
+// Every authenticated request and every heartbeat bumps the user's lastActive.
+// A user is online while lastActive is within PRESENCE_ONLINE_TIMEOUT, away
+// until PRESENCE_AWAY_TIMEOUT and offline after that.
+package presence
+
+import (
+	"context"
+	"time"
+
+	"backend/config"
+	"backend/models"
+	"backend/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Presence states
+const (
+	StateOnline  = "online"
+	StateAway    = "away"
+	StateOffline = "offline"
+)
+
+// OnlineTimeout is how long a user stays online after their last activity
+func OnlineTimeout() time.Duration {
+	return utils.DurationFromEnv("PRESENCE_ONLINE_TIMEOUT", 2*time.Minute)
+}
+
+// AwayTimeout is how long a user stays away before going offline
+func AwayTimeout() time.Duration {
+	return utils.DurationFromEnv("PRESENCE_AWAY_TIMEOUT", 15*time.Minute)
+}
+
+// touchInterval limits how often request activity is written, so busy clients
+// don't turn every request into a write
+func touchInterval() time.Duration {
+	return utils.DurationFromEnv("PRESENCE_TOUCH_INTERVAL", 30*time.Second)
+}
+
+// IsState reports whether s is one of the derived presence states
+func IsState(s string) bool {
+	return s == StateOnline || s == StateAway || s == StateOffline
+}
+
+// State derives the presence state from the last activity
+func State(lastActive, now time.Time) string {
+	if lastActive.IsZero() {
+		return StateOffline
+	}
+	idle := now.Sub(lastActive)
+	switch {
+	case idle <= OnlineTimeout():
+		return StateOnline
+	case idle <= AwayTimeout():
+		return StateAway
+	default:
+		return StateOffline
+	}
+}
+
+// StateFilter matches the users that are in the given state at now
+func StateFilter(state string, now time.Time) bson.M {
+	onlineSince := now.Add(-OnlineTimeout())
+	awaySince := now.Add(-AwayTimeout())
+
+	switch state {
+	case StateOnline:
+		return bson.M{"lastActive": bson.M{"$gte": onlineSince}}
+	case StateAway:
+		return bson.M{"lastActive": bson.M{"$gte": awaySince, "$lt": onlineSince}}
+	default:
+		return bson.M{"$or": []bson.M{
+			{"lastActive": bson.M{"$lt": awaySince}},
+			{"lastActive": nil},
+		}}
+	}
+}
+
+// Touch records request activity. The write is skipped when lastActive is recent enough.
+func Touch(ctx context.Context, userID string) error {
+	now := time.Now()
+	filter := bson.M{
+		"$and": []bson.M{
+			models.UserIDFilter(userID),
+			{"$or": []bson.M{
+				{"lastActive": bson.M{"$lt": now.Add(-touchInterval())}},
+				{"lastActive": nil},
+			}},
+		},
+	}
+	_, err := config.UserCollectionRef.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"lastActive": now}})
+	return err
+}
+
+// Heartbeat records that the user's client is open and returns the new lastActive
+func Heartbeat(ctx context.Context, userID string) (time.Time, error) {
+	now := time.Now()
+	_, err := config.UserCollectionRef.UpdateOne(ctx, models.UserIDFilter(userID), bson.M{"$set": bson.M{"lastActive": now}})
+	return now, err
+}
@@ -7,6 +7,7 @@ import (
 	"backend/middleware"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
 	fiberSwagger "github.com/swaggo/fiber-swagger"
 )
 
@@ -17,43 +18,142 @@ func SetupRoutes(app *fiber.App) {
 	// Swagger documentation route
 	app.Get("/swagger/*", fiberSwagger.WrapHandler)
 
+	// Public keys for verifying our JWTs
+	app.Get("/.well-known/jwks.json", controllers.GetJWKS)
+
 	// Auth routes - di kedua lokasi untuk kompatibilitas
 	// 1. Tanpa prefix /auth untuk frontend lama
 	app.Post("/login", controllers.Login)
-	app.Post("/register", controllers.Register)
+	app.Post("/register", middleware.RequireOpenRegistration(), controllers.Register)
 
 	// 2. Dengan prefix /auth untuk frontend baru
 	auth := app.Group("/auth")
 	auth.Post("/login", controllers.Login)
-	auth.Post("/register", controllers.Register)
+	auth.Post("/register", middleware.RequireOpenRegistration(), controllers.Register)
+	auth.Post("/login/2fa", controllers.LoginTwoFactor)
+	auth.Post("/2fa/enroll", controllers.EnrollTwoFactor)
+	auth.Post("/2fa/enroll/confirm", controllers.ConfirmTwoFactorEnrollment)
+
+	// Single sign-on through the OpenID Connect provider in OIDC_ISSUER
+	auth.Get("/oidc/login", controllers.OIDCLogin)
+	auth.Get("/oidc/callback", controllers.OIDCCallback)
+
+	auth.Post("/refresh", controllers.RefreshToken)
+	auth.Post("/logout", middleware.Protected(), middleware.DenyAccessTokens(), controllers.Logout)
+
+	// Password reset - limited per IP so the endpoint can't be used to spam inboxes
+	auth.Post("/forgot-password", limiter.New(limiter.Config{
+		Max:        5,
+		Expiration: 15 * time.Minute,
+	}), controllers.ForgotPassword)
+	auth.Post("/reset-password", controllers.ResetPassword)
+	auth.Get("/password-policy", controllers.GetPasswordPolicy)
+
+	// Passwordless login by email link
+	auth.Post("/magic-link", limiter.New(limiter.Config{
+		Max:        5,
+		Expiration: 15 * time.Minute,
+	}), controllers.RequestMagicLink)
+	auth.Post("/magic-link/login", controllers.MagicLinkLogin)
+
+	// Passkey login
+	auth.Post("/passkeys/login/begin", controllers.BeginPasskeyLogin)
+	auth.Post("/passkeys/login/finish", controllers.FinishPasskeyLogin)
+
+	// Invitations - the way in when OPEN_REGISTRATION is off
+	auth.Get("/invitations", controllers.GetInvitationByToken)
+	auth.Post("/invitations/accept", controllers.AcceptInvitation)
+
+	// Email verification
+	auth.Post("/verify-email", controllers.VerifyEmail)
+	auth.Post("/resend-verification", limiter.New(limiter.Config{
+		Max:        5,
+		Expiration: 15 * time.Minute,
+	}), controllers.ResendVerification)
 
 	// TAMBAHKAN: Non-protected User endpoint
-	app.Get("/users", controllers.GetUsers)
-	app.Get("/users/:id", controllers.GetUserById)
-	app.Post("/users", controllers.CreateUser)
+	// Listing users needs a token: people are only visible inside their workspace
+	app.Get("/users", middleware.Protected(), middleware.RequirePermission(middleware.PermUsersRead), controllers.GetUsers)
+	app.Get("/users/:id", middleware.Protected(), middleware.RequirePermission(middleware.PermUsersRead), controllers.GetUserById)
+	app.Post("/users", middleware.RequireOpenRegistration(), controllers.CreateUser)
 
 	// Protected Api routes
 	api := app.Group("/api")
 	api.Use(middleware.Protected())
 
 	// Protected User endpoints dalam group /api
-	api.Get("/users", controllers.GetUsers)
-	api.Get("/team-members", controllers.GetTeamMembers)
-	api.Get("/users/:id", controllers.GetUserById)
+	api.Get("/users", middleware.RequirePermission(middleware.PermUsersRead), controllers.GetUsers)
+	api.Post("/users", middleware.RequirePermission(middleware.PermUsersCreate), controllers.CreateUser)
+	api.Get("/team-members", middleware.RequirePermission(middleware.PermUsersRead), controllers.GetTeamMembers)
+	api.Get("/users/:id", middleware.RequirePermission(middleware.PermUsersRead), controllers.GetUserById)
+	// Ownership (self vs. any) is checked inside the handler
 	api.Put("/users/:id", controllers.UpdateUser)
-	api.Delete("/users/:id", controllers.DeleteUser)
+	api.Delete("/users/:id", middleware.DenyImpersonation(), middleware.RequirePermission(middleware.PermUsersDelete), controllers.DeleteUser)
 
 	// Upload profile image
-	api.Post("/upload-profile-image", controllers.UploadProfileImage)
+	api.Post("/upload-profile-image", middleware.RequirePermission(middleware.PermUploadsCreate), controllers.UploadProfileImage)
 
 	// Meeting routes - using the existing protected API group
-	api.Post("/meetings", controllers.CreateMeeting)
-	api.Get("/meetings", controllers.GetMeetings)
-	api.Get("/meetings/today", controllers.GetTodayMeetings)
-	api.Get("/meetings/upcoming", controllers.GetUpcomingMeetings)
-	api.Get("/meetings/:id", controllers.GetMeetingById)
+	api.Post("/meetings", middleware.RequirePermission(middleware.PermMeetingsCreate), controllers.CreateMeeting)
+	api.Get("/meetings", middleware.RequirePermission(middleware.PermMeetingsRead), controllers.GetMeetings)
+	api.Get("/meetings/today", middleware.RequirePermission(middleware.PermMeetingsRead), controllers.GetTodayMeetings)
+	api.Get("/meetings/upcoming", middleware.RequirePermission(middleware.PermMeetingsRead), controllers.GetUpcomingMeetings)
+	api.Get("/meetings/:id", middleware.RequirePermission(middleware.PermMeetingsRead), controllers.GetMeetingById)
+	// Creator vs. other users is checked inside the handlers
 	api.Put("/meetings/:id", controllers.UpdateMeeting)
-	api.Delete("/meetings/:id", controllers.DeleteMeeting)
+	api.Delete("/meetings/:id", middleware.DenyImpersonation(), controllers.DeleteMeeting)
+
+	// Teams. Leads and membership are checked inside the handlers
+	api.Get("/teams", controllers.GetTeams)
+	api.Post("/teams", middleware.RequirePermission(middleware.PermTeamsCreate), controllers.CreateTeam)
+	api.Get("/teams/:id", controllers.GetTeam)
+	api.Put("/teams/:id", controllers.UpdateTeam)
+	api.Delete("/teams/:id", middleware.DenyImpersonation(), controllers.DeleteTeam)
+	api.Post("/teams/:id/members", controllers.AddTeamMember)
+	api.Delete("/teams/:id/members/:userId", controllers.RemoveTeamMember)
+
+	// Presence of the current user
+	api.Post("/presence/heartbeat", middleware.DenyAccessTokens(), controllers.Heartbeat)
+	api.Put("/presence/status", middleware.DenyAccessTokens(), middleware.DenyImpersonation(), controllers.SetCustomStatus)
+	api.Delete("/presence/status", middleware.DenyAccessTokens(), middleware.DenyImpersonation(), controllers.ClearCustomStatus)
+
+	// Two-factor authentication for the current user
+	api.Post("/2fa/setup", middleware.DenyAccessTokens(), middleware.DenyImpersonation(), controllers.SetupTwoFactor)
+	api.Post("/2fa/confirm", middleware.DenyAccessTokens(), middleware.DenyImpersonation(), controllers.ConfirmTwoFactor)
+	api.Post("/2fa/disable", middleware.DenyAccessTokens(), middleware.DenyImpersonation(), controllers.DisableTwoFactor)
+	api.Post("/2fa/recovery-codes", middleware.DenyAccessTokens(), middleware.DenyImpersonation(), controllers.RegenerateRecoveryCodes)
+
+	// Login sessions of the current user
+	api.Get("/sessions", middleware.DenyAccessTokens(), middleware.DenyImpersonation(), controllers.GetSessions)
+	api.Delete("/sessions", middleware.DenyAccessTokens(), middleware.DenyImpersonation(), controllers.RevokeAllSessions)
+	api.Delete("/sessions/:id", middleware.DenyAccessTokens(), middleware.DenyImpersonation(), controllers.RevokeSession)
+
+	// Passkeys of the current user
+	api.Get("/passkeys", middleware.DenyAccessTokens(), middleware.DenyImpersonation(), controllers.GetPasskeys)
+	api.Post("/passkeys/register/begin", middleware.DenyAccessTokens(), middleware.DenyImpersonation(), controllers.BeginPasskeyRegistration)
+	api.Post("/passkeys/register/finish", middleware.DenyAccessTokens(), middleware.DenyImpersonation(), controllers.FinishPasskeyRegistration)
+	api.Post("/passkeys/passwordless", middleware.DenyAccessTokens(), middleware.DenyImpersonation(), controllers.RemovePassword)
+	api.Delete("/passkeys/:id", middleware.DenyAccessTokens(), middleware.DenyImpersonation(), controllers.DeletePasskey)
+
+	// Personal access tokens of the current user
+	api.Get("/tokens", middleware.DenyAccessTokens(), middleware.DenyImpersonation(), controllers.GetAccessTokens)
+	api.Post("/tokens", middleware.DenyAccessTokens(), middleware.DenyImpersonation(), controllers.CreateAccessToken)
+	api.Delete("/tokens/:id", middleware.DenyAccessTokens(), middleware.DenyImpersonation(), controllers.RevokeAccessToken)
+
+	// Invitations
+	api.Get("/invitations", middleware.RequirePermission(middleware.PermUsersInvite), controllers.GetInvitations)
+	api.Post("/invitations", middleware.DenyImpersonation(), middleware.RequirePermission(middleware.PermUsersInvite), controllers.CreateInvitation)
+	api.Delete("/invitations/:id", middleware.DenyImpersonation(), middleware.RequirePermission(middleware.PermUsersInvite), controllers.RevokeInvitation)
+
+	// Admin settings and tools - never available to impersonation tokens
+	admin := api.Group("/admin", middleware.DenyImpersonation())
+	admin.Get("/security-settings", middleware.RequirePermission(middleware.PermSettingsManage), controllers.GetSecuritySettings)
+	admin.Put("/security-settings", middleware.RequirePermission(middleware.PermSettingsManage), controllers.UpdateSecuritySettings)
+	admin.Post("/users/import", middleware.RequirePermission(middleware.PermUsersCreate, middleware.PermUsersInvite), controllers.ImportUsers)
+	admin.Post("/users/:id/deactivate", middleware.RequirePermission(middleware.PermUsersDeactivate), controllers.DeactivateUser)
+	admin.Post("/users/:id/restore", middleware.RequirePermission(middleware.PermUsersDeactivate), controllers.RestoreUser)
+	admin.Post("/users/:id/unlock", middleware.RequirePermission(middleware.PermUsersUnlock), controllers.UnlockUser)
+	admin.Post("/impersonate/:id", middleware.DenyAccessTokens(), middleware.RequirePermission(middleware.PermUsersImpersonate), controllers.ImpersonateUser)
 
 	// Health check
 	app.Get("/health", HealthCheck)
@@ -0,0 +1,86 @@
+// Package storage keeps uploaded files where every API instance can reach them.
+//
+// Files are addressed by key ("64ab..._256.jpg", "attachments/report.pdf") and
+// exposed through a public URL. Controllers store the URL and use the
+// package-level functions, which go through Default.
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path"
+	"strings"
+)
+
+// Storage stores files under keys and tells where they can be downloaded
+type Storage interface {
+	// Put stores data under key, replacing what was there, and returns its public URL
+	Put(ctx context.Context, key string, data []byte, contentType string) (string, error)
+	// Delete removes the file under key; a missing file is not an error
+	Delete(ctx context.Context, key string) error
+	// KeyFromURL reverses Put's URL. ok is false for URLs of files stored elsewhere.
+	KeyFromURL(url string) (key string, ok bool)
+}
+
+// Default is the storage used by the package functions. Init replaces it based on STORAGE_DRIVER.
+var Default Storage = NewLocal("./uploads", "/uploads")
+
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Init selects the storage from the environment.
+// STORAGE_DRIVER=s3 uses S3_ENDPOINT/S3_REGION/S3_BUCKET/S3_ACCESS_KEY_ID/S3_SECRET_ACCESS_KEY
+// (and optionally S3_PUBLIC_URL, S3_PATH_STYLE); the objects must be publicly readable,
+// see S3. Anything else writes to UPLOAD_DIR (default ./uploads), served by this server
+// under /uploads.
+func Init() {
+	switch os.Getenv("STORAGE_DRIVER") {
+	case "s3":
+		s, err := NewS3FromEnv()
+		if err != nil {
+			log.Fatal("❌ STORAGE_DRIVER=s3: ", err)
+		}
+		Default = s
+		log.Printf("✅ Storing uploads in bucket %s at %s", s.Bucket, s.Endpoint)
+	default:
+		local := LocalFromEnv()
+		Default = local
+		log.Println("⚠️ STORAGE_DRIVER not set to s3, uploads are stored on local disk in", local.Dir)
+	}
+}
+
+// LocalFromEnv is the local storage in UPLOAD_DIR (default ./uploads) under /uploads.
+// It is served even when Default is S3, because files uploaded before the switch
+// are still referenced by their /uploads URLs.
+func LocalFromEnv() *Local {
+	dir := os.Getenv("UPLOAD_DIR")
+	if dir == "" {
+		dir = "./uploads"
+	}
+	return NewLocal(dir, "/uploads")
+}
+
+// Put stores data with the Default storage
+func Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	return Default.Put(ctx, key, data, contentType)
+}
+
+// Delete removes a file from the Default storage
+func Delete(ctx context.Context, key string) error {
+	return Default.Delete(ctx, key)
+}
+
+// KeyFromURL maps a URL from the Default storage back to its key
+func KeyFromURL(url string) (string, bool) {
+	return Default.KeyFromURL(url)
+}
+
+// checkKey accepts relative slash-separated keys that stay inside the storage
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") || path.Clean(key) != key ||
+		key == ".." || strings.HasPrefix(key, "../") {
+		return ErrInvalidKey
+	}
+	return nil
+}
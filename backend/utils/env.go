@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DurationFromEnv reads a time.Duration (e.g. "15m", "720h") from the environment
+func DurationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("⚠️ Invalid %s %q, using default: %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// IntFromEnv reads a non-negative integer from the environment
+func IntFromEnv(key string, fallback int) int {
+	return IntRangeFromEnv(key, fallback, 0, math.MaxInt)
+}
+
+// IntRangeFromEnv reads an integer between min and max (inclusive) from the environment
+func IntRangeFromEnv(key string, fallback, min, max int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		log.Printf("⚠️ Invalid %s %q (allowed %d-%d), using default: %d", key, value, min, max, fallback)
+		return fallback
+	}
+	return n
+}
+
+// BoolFromEnv reads a boolean ("true", "false", "1", "0") from the environment
+func BoolFromEnv(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("⚠️ Invalid %s %q, using default: %t", key, value, fallback)
+		return fallback
+	}
+	return b
+}
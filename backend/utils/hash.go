@@ -1,20 +1,156 @@
 package utils
 
 import (
-    "golang.org/x/crypto/bcrypt"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashes are self-describing strings, so the algorithm and its parameters
+// can change without breaking existing hashes:
+//
+//	$argon2id$v=19$m=65536,t=2,p=2$<salt>$<hash>   (PHC string format)
+//	$2a$12$...                                      (bcrypt)
+//
+// New hashes use PASSWORD_HASH_ALGORITHM ("argon2id" or "bcrypt") with the
+// ARGON2_* or BCRYPT_COST parameters. Login rehashes anything older.
+const (
+	HashAlgorithmArgon2id = "argon2id"
+	HashAlgorithmBcrypt   = "bcrypt"
 )
 
+// Argon2Params are the argon2id cost parameters
+type Argon2Params struct {
+	MemoryKiB   uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// PasswordHashAlgorithm is the algorithm new password hashes are made with
+func PasswordHashAlgorithm() string {
+	if strings.EqualFold(os.Getenv("PASSWORD_HASH_ALGORITHM"), HashAlgorithmBcrypt) {
+		return HashAlgorithmBcrypt
+	}
+	return HashAlgorithmArgon2id
+}
+
+// Argon2ParamsFromEnv reads the argon2id parameters; the defaults follow the OWASP recommendation.
+// Out-of-range values fall back to the defaults: argon2 panics on zero iterations or parallelism.
+func Argon2ParamsFromEnv() Argon2Params {
+	return Argon2Params{
+		MemoryKiB:   uint32(IntRangeFromEnv("ARGON2_MEMORY_KIB", 64*1024, 8, 4*1024*1024)),
+		Iterations:  uint32(IntRangeFromEnv("ARGON2_ITERATIONS", 2, 1, 1000)),
+		Parallelism: uint8(IntRangeFromEnv("ARGON2_PARALLELISM", 2, 1, 255)),
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// BcryptCost is the cost of new bcrypt hashes
+func BcryptCost() int {
+	return IntRangeFromEnv("BCRYPT_COST", 12, bcrypt.MinCost, bcrypt.MaxCost)
+}
+
+// HashPassword hashes a password with the configured algorithm
 func HashPassword(password string) (string, error) {
-    bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-    return string(bytes), err
+	if PasswordHashAlgorithm() == HashAlgorithmBcrypt {
+		bytes, err := bcrypt.GenerateFromPassword([]byte(password), BcryptCost())
+		return string(bytes), err
+	}
+	return hashArgon2id(password, Argon2ParamsFromEnv())
 }
 
+// CheckPasswordHash reports whether the password matches a hash in any supported format
 func CheckPasswordHash(password, hash string) bool {
-    err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-    return err == nil
+	if strings.HasPrefix(hash, "$argon2id$") {
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.MemoryKiB, params.Parallelism, params.KeyLength)
+		return subtle.ConstantTimeCompare(key, other) == 1
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	return err == nil
 }
 
+// ComparePasswords is CheckPasswordHash with the arguments the other way round
 func ComparePasswords(hashedPassword, plainPassword string) bool {
-    err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(plainPassword))
-    return err == nil
-}
\ No newline at end of file
+	return CheckPasswordHash(plainPassword, hashedPassword)
+}
+
+// NeedsRehash reports whether a hash was made with another algorithm or weaker
+// parameters than the current configuration
+func NeedsRehash(hash string) bool {
+	if PasswordHashAlgorithm() == HashAlgorithmBcrypt {
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost < BcryptCost()
+	}
+
+	params, _, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	want := Argon2ParamsFromEnv()
+	return params.MemoryKiB < want.MemoryKiB ||
+		params.Iterations < want.Iterations ||
+		params.Parallelism != want.Parallelism ||
+		uint32(len(key)) < want.KeyLength
+}
+
+func hashArgon2id(password string, params Argon2Params) (string, error) {
+	salt := make([]byte, params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.MemoryKiB, params.Parallelism, params.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.MemoryKiB, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func decodeArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, errors.New("not an argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errors.New("unsupported argon2 version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.MemoryKiB, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, err
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	// argon2 panics on zero iterations or parallelism, and an empty key would match anything
+	if params.Iterations < 1 || params.Parallelism < 1 || len(salt) == 0 || len(key) == 0 {
+		return params, nil, nil, errors.New("invalid argon2id parameters")
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
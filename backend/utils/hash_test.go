@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"testing"
+)
+
+// The benchmarks use the environment's PASSWORD_HASH_ALGORITHM / ARGON2_* / BCRYPT_COST,
+// so parameters can be compared on the hardware they will run on:
+//
+//	go test ./utils -run '^$' -bench Password -benchmem
+//	ARGON2_MEMORY_KIB=19456 go test ./utils -run '^$' -bench Password
+//
+// cmd/hashbench checks the same operations against a latency budget.
+
+const benchmarkPassword = "benchmark-password"
+
+func BenchmarkHashPassword(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := HashPassword(benchmarkPassword); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkHashPasswordParallel is the cost under concurrent logins, where argon2id
+// memory and CPU contend
+func BenchmarkHashPasswordParallel(b *testing.B) {
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := HashPassword(benchmarkPassword); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkCheckPasswordHash(b *testing.B) {
+	hash, err := HashPassword(benchmarkPassword)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !CheckPasswordHash(benchmarkPassword, hash) {
+			b.Fatal("password does not match its hash")
+		}
+	}
+}
+
+func TestHashPasswordRoundTrip(t *testing.T) {
+	for _, algorithm := range []string{HashAlgorithmArgon2id, HashAlgorithmBcrypt} {
+		t.Run(algorithm, func(t *testing.T) {
+			t.Setenv("PASSWORD_HASH_ALGORITHM", algorithm)
+			t.Setenv("BCRYPT_COST", "4")
+			t.Setenv("ARGON2_MEMORY_KIB", "1024")
+
+			hash, err := HashPassword(benchmarkPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !CheckPasswordHash(benchmarkPassword, hash) {
+				t.Fatal("password does not match its hash")
+			}
+			if CheckPasswordHash("wrong-password", hash) {
+				t.Fatal("wrong password matches")
+			}
+			if NeedsRehash(hash) {
+				t.Fatal("fresh hash needs a rehash")
+			}
+		})
+	}
+}
+
+func TestArgon2ParamsFromEnvRejectsOutOfRange(t *testing.T) {
+	defaults := Argon2Params{MemoryKiB: 64 * 1024, Iterations: 2, Parallelism: 2, SaltLength: 16, KeyLength: 32}
+	tests := []struct {
+		key, value string
+		want       Argon2Params
+	}{
+		{"ARGON2_ITERATIONS", "0", defaults},
+		{"ARGON2_ITERATIONS", "-1", defaults},
+		{"ARGON2_ITERATIONS", "3", Argon2Params{MemoryKiB: 64 * 1024, Iterations: 3, Parallelism: 2, SaltLength: 16, KeyLength: 32}},
+		{"ARGON2_PARALLELISM", "0", defaults},
+		{"ARGON2_PARALLELISM", "256", defaults},
+		{"ARGON2_PARALLELISM", "255", Argon2Params{MemoryKiB: 64 * 1024, Iterations: 2, Parallelism: 255, SaltLength: 16, KeyLength: 32}},
+		{"ARGON2_MEMORY_KIB", "0", defaults},
+		{"ARGON2_MEMORY_KIB", "many", defaults},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+			if got := Argon2ParamsFromEnv(); got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Stored hashes come from the database, so malformed ones must fail instead of panicking
+func TestCheckPasswordHashRejectsMalformedArgon2id(t *testing.T) {
+	const salt, key = "c2FsdHNhbHRzYWx0c2FsdA", "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+	tests := map[string]string{
+		"zero iterations":   "$argon2id$v=19$m=1024,t=0,p=1$" + salt + "$" + key,
+		"zero parallelism":  "$argon2id$v=19$m=1024,t=1,p=0$" + salt + "$" + key,
+		"parallelism > 255": "$argon2id$v=19$m=1024,t=1,p=256$" + salt + "$" + key,
+		"empty key":         "$argon2id$v=19$m=1024,t=1,p=1$" + salt + "$",
+		"empty salt":        "$argon2id$v=19$m=1024,t=1,p=1$$" + key,
+		"other version":     "$argon2id$v=16$m=1024,t=1,p=1$" + salt + "$" + key,
+		"missing part":      "$argon2id$v=19$m=1024,t=1,p=1$" + salt,
+	}
+	for name, hash := range tests {
+		t.Run(name, func(t *testing.T) {
+			if CheckPasswordHash(benchmarkPassword, hash) {
+				t.Fatal("malformed hash matches")
+			}
+			if !NeedsRehash(hash) {
+				t.Fatal("malformed hash does not need a rehash")
+			}
+		})
+	}
+}
@@ -2,53 +2,118 @@ package utils
 
 import (
 	"errors"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// Generate a JWT token for a user
-func GenerateJWT(userID, email, nama string) (string, error) {
-	secret := GetJWTSecret()
+// AccessClaims describes the bearer of an access token
+type AccessClaims struct {
+	UserID    string
+	Email     string
+	Nama      string
+	Role      string
+	SessionID string
 
+	// WorkspaceID goes in the "wid" claim and scopes every query of the request
+	WorkspaceID string
+
+	// ImpersonatorID is set when an admin acts as this user; it goes in the "act" claim (RFC 8693)
+	ImpersonatorID string
+	// TTL overrides AccessTokenTTL when set
+	TTL time.Duration
+}
+
+// Generate a short-lived access token for a user.
+// Every token carries a unique "jti" so it can be revoked before it expires,
+// and the "sid" of the login session it belongs to.
+func GenerateJWT(access AccessClaims) (string, error) {
+	jti, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	ttl := access.TTL
+	if ttl <= 0 {
+		ttl = AccessTokenTTL()
+	}
+
+	now := time.Now()
 	claims := jwt.MapClaims{
-		"id":    userID,
-		"email": email,
-		"nama":  nama,
-		"exp":   time.Now().Add(time.Hour * 72).Unix(),
+		"id":    access.UserID,
+		"email": access.Email,
+		"nama":  access.Nama,
+		"role":  access.Role,
+		"jti":   jti,
+		"iss":   JWTIssuer(),
+		"iat":   now.Unix(),
+		"exp":   now.Add(ttl).Unix(),
+	}
+	if access.SessionID != "" {
+		claims["sid"] = access.SessionID
+	}
+	if access.WorkspaceID != "" {
+		claims["wid"] = access.WorkspaceID
+	}
+	if access.ImpersonatorID != "" {
+		claims["act"] = map[string]interface{}{"sub": access.ImpersonatorID}
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(secret)
+	return signJWT(claims)
 }
 
 // Parse and validate a JWT token
 func ParseJWT(tokenString string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Validate the signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return GetJWTSecret(), nil
-	})
-
+	token, err := jwt.Parse(tokenString, verificationKey, jwtParserOptions()...)
 	if err != nil {
 		return nil, err
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+		// Purpose tokens (email links, etc.) are signed with the same key but are never access tokens
+		if _, ok := claims["purpose"]; ok {
+			return nil, errors.New("not an access token")
+		}
 		return claims, nil
 	}
 
 	return nil, errors.New("invalid token")
 }
 
-// Get the JWT secret
-func GetJWTSecret() []byte {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "default_jwt_secret_key_change_in_production"
+// GeneratePurposeToken signs a short-lived token that is only valid for one purpose,
+// such as an email verification link. ParseJWT refuses these tokens.
+func GeneratePurposeToken(purpose string, claims jwt.MapClaims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	tokenClaims := jwt.MapClaims{}
+	for k, v := range claims {
+		tokenClaims[k] = v
+	}
+	tokenClaims["purpose"] = purpose
+	tokenClaims["iss"] = JWTIssuer()
+	tokenClaims["iat"] = now.Unix()
+	tokenClaims["exp"] = now.Add(ttl).Unix()
+
+	return signJWT(tokenClaims)
+}
+
+// ParsePurposeToken validates a token created by GeneratePurposeToken for the given purpose
+func ParsePurposeToken(tokenString, purpose string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, verificationKey, jwtParserOptions()...)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
 	}
-	return []byte(secret)
+	if claims["purpose"] != purpose {
+		return nil, errors.New("token purpose mismatch")
+	}
+	return claims, nil
+}
+
+// AccessTokenTTL is the lifetime of access tokens; clients renew them through /auth/refresh
+func AccessTokenTTL() time.Duration {
+	return DurationFromEnv("ACCESS_TOKEN_TTL", 15*time.Minute)
 }
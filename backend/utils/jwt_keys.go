@@ -0,0 +1,237 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwtKey is one key of the key set. Retired keys only have a public half and
+// are kept so tokens they signed stay valid until they expire.
+type jwtKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+var (
+	jwtKeys      = map[string]*jwtKey{}
+	activeJWTKey *jwtKey
+)
+
+// IsProduction reports whether the server runs with APP_ENV=production
+func IsProduction() bool {
+	return strings.EqualFold(os.Getenv("APP_ENV"), "production")
+}
+
+// LoadJWTKeys loads the signing keys from JWT_KEYS_DIR. Every "<kid>.pem" file in it
+// is a key: private keys (RSA or Ed25519, PKCS#8 or PKCS#1) can sign, public keys
+// only verify. JWT_ACTIVE_KID picks the key new tokens are signed with.
+//
+// Rotation: add the new private key, switch JWT_ACTIVE_KID, and replace the old
+// private key with its public key until the last token it signed has expired.
+//
+// Without JWT_KEYS_DIR a throwaway Ed25519 key is generated, except in production
+// where that is an error.
+func LoadJWTKeys() error {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		if IsProduction() {
+			return errors.New("JWT_KEYS_DIR must be set in production")
+		}
+		return useEphemeralJWTKey()
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	keys := map[string]*jwtKey{}
+	for _, path := range paths {
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := readJWTKey(path, kid)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		keys[kid] = key
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no .pem keys found in %s", dir)
+	}
+
+	activeID := os.Getenv("JWT_ACTIVE_KID")
+	if activeID == "" {
+		var signers []string
+		for kid, key := range keys {
+			if key.Private != nil {
+				signers = append(signers, kid)
+			}
+		}
+		if len(signers) != 1 {
+			return errors.New("JWT_ACTIVE_KID must be set when JWT_KEYS_DIR does not hold exactly one private key")
+		}
+		activeID = signers[0]
+	}
+
+	active, ok := keys[activeID]
+	if !ok || active.Private == nil {
+		return fmt.Errorf("no private key for JWT_ACTIVE_KID %q", activeID)
+	}
+
+	jwtKeys = keys
+	activeJWTKey = active
+	log.Printf("✅ Loaded %d JWT keys, signing with %q (%s)", len(keys), active.ID, active.Method.Alg())
+	return nil
+}
+
+// useEphemeralJWTKey signs with a key that only lives as long as the process
+func useEphemeralJWTKey() error {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	kid, err := GenerateRandomToken(8)
+	if err != nil {
+		return err
+	}
+
+	key := &jwtKey{ID: "dev-" + kid, Method: jwt.SigningMethodEdDSA, Private: private, Public: public}
+	jwtKeys = map[string]*jwtKey{key.ID: key}
+	activeJWTKey = key
+	log.Println("⚠️ JWT_KEYS_DIR not set, using a temporary signing key (tokens won't survive a restart)")
+	return nil
+}
+
+// readJWTKey parses a PEM encoded private or public key
+func readJWTKey(path, kid string) (*jwtKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("not a PEM file")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &jwtKey{ID: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T (use RSA or Ed25519)", parsed)
+	}
+
+	if rsaKey, ok := key.Public.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < 2048 {
+		return nil, errors.New("RSA keys must be at least 2048 bits")
+	}
+	return key, nil
+}
+
+// signJWT signs claims with the active key and stamps its kid in the header
+func signJWT(claims jwt.MapClaims) (string, error) {
+	if activeJWTKey == nil {
+		return "", errors.New("JWT keys not loaded")
+	}
+	token := jwt.NewWithClaims(activeJWTKey.Method, claims)
+	token.Header["kid"] = activeJWTKey.ID
+	return token.SignedString(activeJWTKey.Private)
+}
+
+// verificationKey is the jwt.Keyfunc for tokens signed by any key of the set
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := jwtKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.Public, nil
+}
+
+// jwtParserOptions restricts parsing to the algorithms the key set can produce.
+// It is built per call: JWT_ISSUER is only known once main has loaded .env.
+func jwtParserOptions() []jwt.ParserOption {
+	return []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuer(JWTIssuer()),
+	}
+}
+
+// JWTIssuer is the "iss" of every token we sign
+func JWTIssuer() string {
+	issuer := os.Getenv("JWT_ISSUER")
+	if issuer == "" {
+		issuer = "coemotion"
+	}
+	return issuer
+}
+
+// JWKS returns the public keys of the key set as a JSON Web Key Set (RFC 7517),
+// so other services can verify our tokens
+func JWKS() map[string]interface{} {
+	kids := make([]string, 0, len(jwtKeys))
+	for kid := range jwtKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	keys := make([]map[string]string, 0, len(kids))
+	for _, kid := range kids {
+		key := jwtKeys[kid]
+		jwk := map[string]string{"kid": key.ID, "use": "sig", "alg": key.Method.Alg()}
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(public)
+		}
+		keys = append(keys, jwk)
+	}
+	return map[string]interface{}{"keys": keys}
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// JWT_ISSUER comes from .env, which main loads after package initialization
+func TestParseUsesTheCurrentIssuer(t *testing.T) {
+	if err := LoadJWTKeys(); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("JWT_ISSUER", "https://coemotion.example.com")
+
+	token, err := GenerateJWT(AccessClaims{UserID: "user-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ParseJWT(token)
+	if err != nil {
+		t.Fatalf("ParseJWT: %v", err)
+	}
+	if claims["iss"] != "https://coemotion.example.com" {
+		t.Fatalf("iss = %v", claims["iss"])
+	}
+
+	purpose, err := GeneratePurposeToken("password_reset", nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParsePurposeToken(purpose, "password_reset"); err != nil {
+		t.Fatalf("ParsePurposeToken: %v", err)
+	}
+
+	// Tokens of another issuer are still refused
+	t.Setenv("JWT_ISSUER", "https://other.example.com")
+	if _, err := ParseJWT(token); err == nil {
+		t.Fatal("token of another issuer accepted")
+	}
+}
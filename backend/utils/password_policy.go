@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// PasswordPolicy describes what a new password must look like
+type PasswordPolicy struct {
+	MinLength     int  `json:"minLength"`
+	MaxLength     int  `json:"maxLength"`
+	RequireUpper  bool `json:"requireUpper"`
+	RequireLower  bool `json:"requireLower"`
+	RequireDigit  bool `json:"requireDigit"`
+	RequireSymbol bool `json:"requireSymbol"`
+	// HistorySize is how many previous passwords can't be reused
+	HistorySize int `json:"historySize"`
+}
+
+// PasswordViolation is one rule a password breaks, with a stable code for clients
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Violation codes
+const (
+	PasswordTooShort      = "too_short"
+	PasswordTooLong       = "too_long"
+	PasswordMissingUpper  = "missing_uppercase"
+	PasswordMissingLower  = "missing_lowercase"
+	PasswordMissingDigit  = "missing_digit"
+	PasswordMissingSymbol = "missing_symbol"
+	PasswordPersonalInfo  = "contains_personal_info"
+	PasswordReused        = "reused"
+	PasswordBreached      = "breached"
+)
+
+// PasswordPolicyFromEnv reads the policy from PASSWORD_* variables
+func PasswordPolicyFromEnv() PasswordPolicy {
+	// bcrypt only looks at the first 72 bytes
+	maxLength := 128
+	if PasswordHashAlgorithm() == HashAlgorithmBcrypt {
+		maxLength = 72
+	}
+
+	return PasswordPolicy{
+		MinLength:     IntFromEnv("PASSWORD_MIN_LENGTH", 10),
+		MaxLength:     IntFromEnv("PASSWORD_MAX_LENGTH", maxLength),
+		RequireUpper:  BoolFromEnv("PASSWORD_REQUIRE_UPPER", true),
+		RequireLower:  BoolFromEnv("PASSWORD_REQUIRE_LOWER", true),
+		RequireDigit:  BoolFromEnv("PASSWORD_REQUIRE_DIGIT", true),
+		RequireSymbol: BoolFromEnv("PASSWORD_REQUIRE_SYMBOL", false),
+		HistorySize:   IntFromEnv("PASSWORD_HISTORY", 5),
+	}
+}
+
+// Check returns every rule the password breaks. personalInfo holds the user's
+// name, email and the like, none of which may appear in the password.
+// Reuse and breach screening need storage and are done by the caller.
+func (p PasswordPolicy) Check(password string, personalInfo ...string) []PasswordViolation {
+	violations := []PasswordViolation{}
+
+	length := len([]rune(password))
+	if length < p.MinLength {
+		violations = append(violations, PasswordViolation{PasswordTooShort, fmt.Sprintf("Password minimal %d karakter", p.MinLength)})
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		violations = append(violations, PasswordViolation{PasswordTooLong, fmt.Sprintf("Password maksimal %d byte", p.MaxLength)})
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		violations = append(violations, PasswordViolation{PasswordMissingUpper, "Password harus mengandung huruf besar"})
+	}
+	if p.RequireLower && !hasLower {
+		violations = append(violations, PasswordViolation{PasswordMissingLower, "Password harus mengandung huruf kecil"})
+	}
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, PasswordViolation{PasswordMissingDigit, "Password harus mengandung angka"})
+	}
+	if p.RequireSymbol && !hasSymbol {
+		violations = append(violations, PasswordViolation{PasswordMissingSymbol, "Password harus mengandung simbol"})
+	}
+
+	if containsPersonalInfo(password, personalInfo) {
+		violations = append(violations, PasswordViolation{PasswordPersonalInfo, "Password tidak boleh mengandung nama atau email"})
+	}
+
+	return violations
+}
+
+// Character classes of generated passwords. Look-alikes (0/O, 1/l/I) are left out
+// because temporary passwords get read off a screen and typed in.
+const (
+	passwordUpper  = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	passwordLower  = "abcdefghijkmnopqrstuvwxyz"
+	passwordDigit  = "23456789"
+	passwordSymbol = "!#$%&*+-=?@"
+)
+
+// GeneratePassword returns a random password that satisfies the policy,
+// for accounts that are handed a temporary password
+func (p PasswordPolicy) GeneratePassword() (string, error) {
+	length := p.MinLength
+	if length < 16 {
+		length = 16
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		length = p.MaxLength
+	}
+
+	// One character of every class, the rest from all of them
+	all := passwordUpper + passwordLower + passwordDigit + passwordSymbol
+	classes := []string{passwordUpper, passwordLower, passwordDigit, passwordSymbol}
+	password := make([]byte, 0, length)
+	for _, class := range classes {
+		c, err := randomChar(class)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+	for len(password) < length {
+		c, err := randomChar(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+
+	// Shuffle so the guaranteed characters aren't always up front
+	for i := len(password) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		j := n.Int64()
+		password[i], password[j] = password[j], password[i]
+	}
+	return string(password), nil
+}
+
+func randomChar(alphabet string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+	if err != nil {
+		return 0, err
+	}
+	return alphabet[n.Int64()], nil
+}
+
+// containsPersonalInfo looks for any word of at least 3 letters from the personal info
+// (name parts, the email's local part) inside the password, ignoring case
+func containsPersonalInfo(password string, personalInfo []string) bool {
+	lower := strings.ToLower(password)
+	for _, info := range personalInfo {
+		info = strings.ToLower(info)
+		if at := strings.Index(info, "@"); at >= 0 {
+			info = info[:at]
+		}
+		words := strings.FieldsFunc(info, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range words {
+			if len([]rune(word)) >= 3 && strings.Contains(lower, word) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsBreachedPassword screens a password against a local copy of a k-anonymity
+// breached password corpus (the Have I Been Pwned range format): BREACHED_PASSWORDS_DIR
+// holds one file per 5 character SHA-1 prefix, e.g. "5BAA6.txt", whose lines are
+// "<remaining 35 hex characters>:<count>". Screening is off when the variable is unset.
+func IsBreachedPassword(password string) (bool, error) {
+	dir := os.Getenv("BREACHED_PASSWORDS_DIR")
+	if dir == "" {
+		return false, nil
+	}
+
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := digest[:5], digest[5:]
+
+	file, err := os.Open(filepath.Join(dir, prefix+".txt"))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		hash, count, _ := strings.Cut(line, ":")
+		if strings.EqualFold(hash, suffix) && strings.TrimSpace(count) != "0" {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
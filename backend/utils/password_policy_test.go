@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func violationCodes(violations []PasswordViolation) []string {
+	codes := []string{}
+	for _, v := range violations {
+		codes = append(codes, v.Code)
+	}
+	return codes
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	strict := PasswordPolicy{MinLength: 10, MaxLength: 72, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+	tests := []struct {
+		name         string
+		policy       PasswordPolicy
+		password     string
+		personalInfo []string
+		want         []string
+	}{
+		{"valid", strict, "Correct-Horse-42", nil, []string{}},
+		{"too short", strict, "Ab1-", nil, []string{PasswordTooShort}},
+		{"length counts characters, not bytes", PasswordPolicy{MinLength: 4}, "ąęść", nil, []string{}},
+		{"too long", strict, "Aa1-" + strings.Repeat("x", 69), nil, []string{PasswordTooLong}},
+		{"max length counts bytes", PasswordPolicy{MaxLength: 4}, "ąęść", nil, []string{PasswordTooLong}},
+		{"no max length", PasswordPolicy{}, strings.Repeat("x", 1000), nil, []string{}},
+		{"missing upper", strict, "correct-horse-42", nil, []string{PasswordMissingUpper}},
+		{"missing lower", strict, "CORRECT-HORSE-42", nil, []string{PasswordMissingLower}},
+		{"missing digit", strict, "Correct-Horse-xx", nil, []string{PasswordMissingDigit}},
+		{"missing symbol", strict, "CorrectHorse42x", nil, []string{PasswordMissingSymbol}},
+		{"space is a symbol", strict, "Correct Horse 42", nil, []string{}},
+		{"classes not required", PasswordPolicy{MinLength: 3}, "aaa", nil, []string{}},
+		{
+			"every rule", strict, "", nil,
+			[]string{PasswordTooShort, PasswordMissingUpper, PasswordMissingLower, PasswordMissingDigit, PasswordMissingSymbol},
+		},
+		{"name", strict, "Budi-Santoso-42", []string{"Budi Santoso"}, []string{PasswordPersonalInfo}},
+		{"name ignores case", strict, "xBUDIx-Horse-42", []string{"budi"}, []string{PasswordPersonalInfo}},
+		{"email local part", strict, "Correct-Ana42-Horse", []string{"ana42@example.com"}, []string{PasswordPersonalInfo}},
+		{"email domain is allowed", strict, "Example-Horse-42", []string{"ana@example.com"}, []string{}},
+		{"short words are allowed", strict, "Correct-Al-Horse-42", []string{"Al Bo"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := violationCodes(tt.policy.Check(tt.password, tt.personalInfo...))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Check(%q) = %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyFromEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want PasswordPolicy
+	}{
+		{
+			"defaults", map[string]string{"PASSWORD_HASH_ALGORITHM": HashAlgorithmArgon2id},
+			PasswordPolicy{MinLength: 10, MaxLength: 128, RequireUpper: true, RequireLower: true, RequireDigit: true, HistorySize: 5},
+		},
+		{
+			"bcrypt caps the length", map[string]string{"PASSWORD_HASH_ALGORITHM": HashAlgorithmBcrypt},
+			PasswordPolicy{MinLength: 10, MaxLength: 72, RequireUpper: true, RequireLower: true, RequireDigit: true, HistorySize: 5},
+		},
+		{
+			"overrides", map[string]string{
+				"PASSWORD_HASH_ALGORITHM": HashAlgorithmArgon2id,
+				"PASSWORD_MIN_LENGTH":     "12",
+				"PASSWORD_REQUIRE_UPPER":  "false",
+				"PASSWORD_REQUIRE_SYMBOL": "true",
+				"PASSWORD_HISTORY":        "0",
+			},
+			PasswordPolicy{MinLength: 12, MaxLength: 128, RequireLower: true, RequireDigit: true, RequireSymbol: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"PASSWORD_MIN_LENGTH", "PASSWORD_MAX_LENGTH", "PASSWORD_REQUIRE_UPPER",
+				"PASSWORD_REQUIRE_LOWER", "PASSWORD_REQUIRE_DIGIT", "PASSWORD_REQUIRE_SYMBOL", "PASSWORD_HISTORY"} {
+				t.Setenv(key, "")
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			if got := PasswordPolicyFromEnv(); got != tt.want {
+				t.Fatalf("PasswordPolicyFromEnv() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeneratePasswordSatisfiesPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     PasswordPolicy
+		wantLength int
+	}{
+		{"at least 16", PasswordPolicy{MinLength: 10, MaxLength: 72, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}, 16},
+		{"min length", PasswordPolicy{MinLength: 24, RequireUpper: true, RequireDigit: true}, 24},
+		{"max length", PasswordPolicy{MinLength: 8, MaxLength: 12, RequireSymbol: true}, 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			password, err := tt.policy.GeneratePassword()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(password) != tt.wantLength {
+				t.Fatalf("length %d, want %d", len(password), tt.wantLength)
+			}
+			if violations := tt.policy.Check(password); len(violations) > 0 {
+				t.Fatalf("%q breaks %v", password, violationCodes(violations))
+			}
+		})
+	}
+}
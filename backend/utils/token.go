@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// GenerateRandomToken returns a URL-safe random string carrying n bytes of entropy
+func GenerateRandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the SHA-256 hex digest of an opaque token.
+// Opaque tokens are only ever stored hashed, so a database dump can't be replayed.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateRefreshToken returns a new refresh token and the hash to persist for it
+func GenerateRefreshToken() (token string, hash string, err error) {
+	token, err = GenerateRandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	return token, HashToken(token), nil
+}
+
+// RefreshTokenTTL is how long a refresh token stays valid if it's never rotated
+func RefreshTokenTTL() time.Duration {
+	return DurationFromEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+}
+
+// PersonalAccessTokenPrefix marks personal access tokens so they can be told apart
+// from JWTs (and spotted by secret scanners)
+const PersonalAccessTokenPrefix = "coe_pat_"
+
+// GeneratePersonalAccessToken returns a new personal access token and the hash to persist for it
+func GeneratePersonalAccessToken() (token string, hash string, err error) {
+	random, err := GenerateRandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	token = PersonalAccessTokenPrefix + random
+	return token, HashToken(token), nil
+}
+
+// IsPersonalAccessToken reports whether a bearer token is a personal access token
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
@@ -10,6 +10,10 @@ import Meetings from './components/Meetings';
 import MeetingDetail from './components/MeetingDetail'; // Import the MeetingDetail component
 import ProtectedRoute from './components/ProtectedRoute';
 import ErrorBoundary from './components/ErrorBoundary';
+import MagicLogin from './components/MagicLogin';
+import ResetPassword from './components/ResetPassword';
+import VerifyEmail from './components/VerifyEmail';
+import AcceptInvitation from './components/AcceptInvitation';
 import './App.css';
 
 
@@ -115,6 +119,11 @@ function App() {
           <Routes>
             <Route path="/login" element={<Login />} />
             <Route path="/register" element={<Register />} />
+            {/* Targets of the links the backend sends by email */}
+            <Route path="/magic-login" element={<MagicLogin />} />
+            <Route path="/reset-password" element={<ResetPassword />} />
+            <Route path="/verify-email" element={<VerifyEmail />} />
+            <Route path="/accept-invitation" element={<AcceptInvitation />} />
             <Route
               path="/dashboard"
               element={
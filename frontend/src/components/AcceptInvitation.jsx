@@ -0,0 +1,138 @@
+import { useState, useEffect } from 'react';
+import { useNavigate, useSearchParams, Link } from 'react-router-dom';
+import { getInvitation, acceptInvitation } from '../services/authService';
+import '../styles/Auth.css';
+
+// Landing page of the invitation link: the invitee picks a name and password
+function AcceptInvitation() {
+    const [searchParams] = useSearchParams();
+    const token = searchParams.get('token') || '';
+    const [invitation, setInvitation] = useState(null);
+    const [nama, setNama] = useState('');
+    const [password, setPassword] = useState('');
+    const [confirmPassword, setConfirmPassword] = useState('');
+    const [error, setError] = useState('');
+    const [violations, setViolations] = useState([]);
+    const [isLoading, setIsLoading] = useState(true);
+
+    const navigate = useNavigate();
+
+    useEffect(() => {
+        if (!token) {
+            setError('This invitation link is incomplete.');
+            setIsLoading(false);
+            return;
+        }
+
+        getInvitation(token)
+            .then((data) => {
+                setInvitation(data);
+                // Imported invitations suggest the name from the CSV
+                setNama(data.nama || '');
+            })
+            .catch((err) => {
+                console.error('Invitation error:', err);
+                setError(err.message);
+            })
+            .finally(() => setIsLoading(false));
+    }, [token]);
+
+    const handleSubmit = async (e) => {
+        e.preventDefault();
+        setError('');
+        setViolations([]);
+
+        if (password !== confirmPassword) {
+            setError('Passwords do not match');
+            return;
+        }
+
+        setIsLoading(true);
+        try {
+            await acceptInvitation({ token, nama, password });
+            navigate('/login', { state: { message: 'Account created, please log in' } });
+        } catch (err) {
+            console.error('Accept invitation error:', err);
+            setError(err.message);
+            setViolations(err.violations);
+        } finally {
+            setIsLoading(false);
+        }
+    };
+
+    return (
+        <div className="auth-container">
+            <div className="auth-card">
+                <h2>Accept Invitation</h2>
+                {error && (
+                    <div className="error-message">
+                        {error}
+                        {violations.length > 0 && (
+                            <ul>
+                                {violations.map((v) => <li key={v.code}>{v.message}</li>)}
+                            </ul>
+                        )}
+                    </div>
+                )}
+
+                {!invitation && isLoading && <p>Loading invitation...</p>}
+
+                {invitation && (
+                    <form onSubmit={handleSubmit}>
+                        <p>
+                            You have been invited as <strong>{invitation.role}</strong> with {invitation.email}.
+                        </p>
+
+                        <div className="form-group">
+                            <label htmlFor="nama">Full Name</label>
+                            <input
+                                type="text"
+                                id="nama"
+                                value={nama}
+                                onChange={(e) => setNama(e.target.value)}
+                                placeholder="Full Name"
+                                required
+                            />
+                        </div>
+
+                        <div className="form-group">
+                            <label htmlFor="password">Password</label>
+                            <input
+                                type="password"
+                                id="password"
+                                autoComplete="new-password"
+                                value={password}
+                                onChange={(e) => setPassword(e.target.value)}
+                                placeholder="Password"
+                                required
+                            />
+                        </div>
+
+                        <div className="form-group">
+                            <label htmlFor="confirmPassword">Confirm Password</label>
+                            <input
+                                type="password"
+                                id="confirmPassword"
+                                autoComplete="new-password"
+                                value={confirmPassword}
+                                onChange={(e) => setConfirmPassword(e.target.value)}
+                                placeholder="Confirm Password"
+                                required
+                            />
+                        </div>
+
+                        <button type="submit" className="auth-button" disabled={isLoading}>
+                            {isLoading ? 'Loading...' : 'Create Account'}
+                        </button>
+                    </form>
+                )}
+
+                <p className="auth-redirect">
+                    <Link to="/login">Back to login</Link>
+                </p>
+            </div>
+        </div>
+    );
+}
+
+export default AcceptInvitation;
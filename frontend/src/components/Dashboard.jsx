@@ -8,7 +8,7 @@ import '../styles/Dashboard.css';
 
 function Dashboard() {
     const { user } = useContext(AuthContext);
-    const [teamMembers, setTeamMembers] = useState([]);
+    const [teamSize, setTeamSize] = useState(0);
     const [loading, setLoading] = useState(true);
     const [error, setError] = useState(null);
     const [upcomingMeetings, setUpcomingMeetings] = useState([]);
@@ -24,14 +24,14 @@ function Dashboard() {
         const fetchTeamMembers = async () => {
             try {
                 setLoading(true);
-                const response = await getUsers();
-                // Ensure response is an array
-                setTeamMembers(Array.isArray(response) ? response : []);
+                // Only the head count is shown, so one small page carries the total
+                const page = await getUsers({ limit: 1 });
+                setTeamSize(page.total);
                 setError(null);
             } catch (err) {
                 console.error('Error fetching team members:', err);
                 setError('Failed to load team members data');
-                setTeamMembers([]); // Set empty array on error
+                setTeamSize(0);
             } finally {
                 setLoading(false);
             }
@@ -100,7 +100,6 @@ function Dashboard() {
     const onlineUsers = 0; // In a real app, you would track this with user status
 
     // Ensure arrays are always valid
-    const safeTeamMembers = Array.isArray(teamMembers) ? teamMembers : [];
     const safeUpcomingMeetings = Array.isArray(upcomingMeetings) ? upcomingMeetings : [];
 
     // Add this useEffect to handle date selection filtering
@@ -186,7 +185,7 @@ function Dashboard() {
                             </div>
                             <div className="team-stats">
                                 <div className="stat-item">
-                                    <div className="stat-number">{loading ? '...' : safeTeamMembers.length}</div>
+                                    <div className="stat-number">{loading ? '...' : teamSize}</div>
                                     <div className="stat-label">Total Members</div>
                                 </div>
                                 <div className="stat-item">
@@ -0,0 +1,109 @@
+import { useState, useContext, useEffect, useRef } from 'react';
+import { useNavigate, useSearchParams, Link } from 'react-router-dom';
+import { magicLinkLogin, loginTwoFactor } from '../services/authService';
+import { AuthContext } from '../context/AuthContext';
+import '../styles/Auth.css';
+
+// Landing page of the login link sent by /auth/magic-link
+function MagicLogin() {
+    const [searchParams] = useSearchParams();
+    const [status, setStatus] = useState('loading'); // loading | twoFactor | error
+    const [error, setError] = useState('');
+    const [challengeToken, setChallengeToken] = useState('');
+    const [code, setCode] = useState('');
+    const [isLoading, setIsLoading] = useState(false);
+
+    const navigate = useNavigate();
+    const { loginUser } = useContext(AuthContext);
+    // Links are single-use, so the token must not be sent twice (StrictMode runs effects twice)
+    const started = useRef(false);
+
+    const finish = (data) => {
+        if (data.twoFactorRequired) {
+            setChallengeToken(data.challengeToken);
+            setStatus('twoFactor');
+            return;
+        }
+        if (data.twoFactorSetupRequired) {
+            setError('Your role requires two-factor authentication. Log in with your password to set it up.');
+            setStatus('error');
+            return;
+        }
+        loginUser(data.user);
+        navigate('/dashboard', { replace: true });
+    };
+
+    useEffect(() => {
+        if (started.current) return;
+        started.current = true;
+
+        const token = searchParams.get('token');
+        if (!token) {
+            setError('This login link is incomplete.');
+            setStatus('error');
+            return;
+        }
+
+        magicLinkLogin(token)
+            .then(finish)
+            .catch((err) => {
+                console.error('Magic link login error:', err);
+                setError(err.message);
+                setStatus('error');
+            });
+        // eslint-disable-next-line react-hooks/exhaustive-deps
+    }, []);
+
+    const handleTwoFactor = async (e) => {
+        e.preventDefault();
+        setIsLoading(true);
+        setError('');
+        try {
+            finish(await loginTwoFactor(challengeToken, code.trim()));
+        } catch (err) {
+            setError(err.message);
+        } finally {
+            setIsLoading(false);
+        }
+    };
+
+    return (
+        <div className="auth-container">
+            <div className="auth-card">
+                <h2>Login</h2>
+                {error && <div className="error-message">{error}</div>}
+
+                {status === 'loading' && <p>Logging you in...</p>}
+
+                {status === 'twoFactor' && (
+                    <form onSubmit={handleTwoFactor}>
+                        <div className="form-group">
+                            <label htmlFor="code">Verification code</label>
+                            <input
+                                type="text"
+                                id="code"
+                                inputMode="numeric"
+                                autoComplete="one-time-code"
+                                value={code}
+                                onChange={(e) => setCode(e.target.value)}
+                                placeholder="Code from your authenticator app"
+                                required
+                            />
+                        </div>
+                        <button type="submit" className="auth-button" disabled={isLoading}>
+                            {isLoading ? 'Loading...' : 'Verify'}
+                        </button>
+                    </form>
+                )}
+
+                {status === 'error' && (
+                    <p className="auth-redirect">
+                        <Link to="/login">Back to login</Link>
+                    </p>
+                )}
+            </div>
+        </div>
+    );
+}
+
+export default MagicLogin;
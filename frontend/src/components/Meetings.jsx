@@ -14,6 +14,8 @@ function Meetings() {
     const [showNewMeetingModal, setShowNewMeetingModal] = useState(false);
     const [meetings, setMeetings] = useState([]);
     const [teamMembers, setTeamMembers] = useState([]);
+    const [memberSearch, setMemberSearch] = useState('');
+    const [membersCursor, setMembersCursor] = useState('');
     const [isDateSelected, setIsDateSelected] = useState(false);
     const [searchTerm, setSearchTerm] = useState('');
     const [showSearchResults, setShowSearchResults] = useState(false);
@@ -72,18 +74,36 @@ function Meetings() {
         fetchMeetings();
     }, []);
 
-    // Load team members
+    // Load the first page of team members for the participant picker, searching on the server
     useEffect(() => {
+        let cancelled = false;
         const fetchTeamMembers = async () => {
             try {
-                const users = await getUsers();
-                setTeamMembers(users);
+                const page = await getUsers({ q: memberSearch.trim() });
+                if (cancelled) return;
+                setTeamMembers(page.users);
+                setMembersCursor(page.nextCursor);
             } catch (error) {
                 console.error('Error fetching team members:', error);
             }
         };
-        fetchTeamMembers();
-    }, []);
+        // Wait until the user stops typing
+        const timer = setTimeout(fetchTeamMembers, 300);
+        return () => {
+            cancelled = true;
+            clearTimeout(timer);
+        };
+    }, [memberSearch]);
+
+    const loadMoreMembers = async () => {
+        try {
+            const page = await getUsers({ q: memberSearch.trim(), cursor: membersCursor });
+            setTeamMembers(prev => [...prev, ...page.users]);
+            setMembersCursor(page.nextCursor);
+        } catch (error) {
+            console.error('Error loading more team members:', error);
+        }
+    };
 
     // Auto-update countdown every minute
     useEffect(() => {
@@ -831,6 +851,16 @@ function Meetings() {
                                             </label>
                                         </div>
 
+                                        {!newMeetingData.allMembers && (
+                                            <input
+                                                type="text"
+                                                className="members-search"
+                                                placeholder="Search members..."
+                                                value={memberSearch}
+                                                onChange={(e) => setMemberSearch(e.target.value)}
+                                            />
+                                        )}
+
                                         {!newMeetingData.allMembers && (
                                             <div className="members-list">
                                                 {teamMembers.map(member => (
@@ -861,6 +891,15 @@ function Meetings() {
                                                         </div>
                                                     </div>
                                                 ))}
+                                                {membersCursor && (
+                                                    <button
+                                                        type="button"
+                                                        className="members-load-more"
+                                                        onClick={loadMoreMembers}
+                                                    >
+                                                        Load more
+                                                    </button>
+                                                )}
                                             </div>
                                         )}
                                     </div>
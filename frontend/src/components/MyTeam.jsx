@@ -14,6 +14,10 @@ function MyTeam() {
     const [showManageModal, setShowManageModal] = useState(false);
     const [selectedMember, setSelectedMember] = useState(null);
     const [newRole, setNewRole] = useState('');
+    const [debouncedSearch, setDebouncedSearch] = useState('');
+    const [nextCursor, setNextCursor] = useState('');
+    const [total, setTotal] = useState(0);
+    const [loadingMore, setLoadingMore] = useState(false);
 
     const API_URL = 'http://localhost:8080';
 
@@ -23,52 +27,78 @@ function MyTeam() {
         return name.charAt(0).toUpperCase();
     };
 
-    // Fetch users from database
+    // Format member image URLs
+    const formatMembers = (members) => members.map(member => {
+        // Ensure profileImage has full URL if exists
+        if (member.profileImage && !member.profileImage.startsWith('http')) {
+            // Ensure no duplicate slashes
+            const baseUrl = API_URL.endsWith('/') ? API_URL.slice(0, -1) : API_URL;
+            const imagePath = member.profileImage.startsWith('/') ? member.profileImage : '/' + member.profileImage;
+
+            member.profileImage = `${baseUrl}${imagePath}`;
+        }
+        return member;
+    });
+
+    // Search and role filter run on the server, one page at a time
+    const fetchPage = (cursor) => getUsers({
+        q: debouncedSearch.trim(),
+        role: selectedRole === 'All Roles' ? '' : selectedRole,
+        cursor,
+    });
+
+    // Wait until the user stops typing before searching
     useEffect(() => {
+        const timer = setTimeout(() => setDebouncedSearch(searchTerm), 300);
+        return () => clearTimeout(timer);
+    }, [searchTerm]);
+
+    // Fetch the first page whenever the filters change
+    useEffect(() => {
+        let cancelled = false;
         const fetchTeamMembers = async () => {
             try {
                 setLoading(true);
                 console.log("Fetching team members...");
-                const response = await getUsers();
-                console.log("Raw response:", response);
-
-                // Format response to handle image URLs
-                const formattedMembers = response.map(member => {
-                    console.log("Processing member:", member.nama, "Profile image:", member.profileImage);
-
-                    // Ensure profileImage has full URL if exists
-                    if (member.profileImage) {
-                        if (!member.profileImage.startsWith('http')) {
-                            // Ensure no duplicate slashes
-                            const baseUrl = API_URL.endsWith('/') ? API_URL.slice(0, -1) : API_URL;
-                            const imagePath = member.profileImage.startsWith('/') ? member.profileImage : '/' + member.profileImage;
-
-                            member.profileImage = `${baseUrl}${imagePath}`;
-                        }
-                        console.log("Formatted image URL:", member.profileImage);
-                    } else {
-                        console.log("No profile image for this member");
-                    }
-
-                    return member;
-                });
-
-                console.log("Formatted members:", formattedMembers);
-                setTeamMembers(formattedMembers);
+                const page = await fetchPage('');
+                if (cancelled) return;
+
+                setTeamMembers(formatMembers(page.users));
+                setNextCursor(page.nextCursor);
+                setTotal(page.total);
                 setError(null);
             } catch (err) {
+                if (cancelled) return;
                 console.error('Error fetching team members:', err);
                 setError('Failed to load team members. Please try again later.');
             } finally {
-                setLoading(false);
+                if (!cancelled) setLoading(false);
             }
         };
 
         fetchTeamMembers();
-    }, []);
+        return () => { cancelled = true; };
+        // eslint-disable-next-line react-hooks/exhaustive-deps
+    }, [debouncedSearch, selectedRole]);
 
-    // Get unique roles from team members
-    const roles = ['All Roles', ...new Set(teamMembers.map(member => member.role || 'Team Member').filter(Boolean))];
+    const loadMoreMembers = async () => {
+        if (!nextCursor) return;
+        try {
+            setLoadingMore(true);
+            const page = await fetchPage(nextCursor);
+            setTeamMembers(prevMembers => [...prevMembers, ...formatMembers(page.users)]);
+            setNextCursor(page.nextCursor);
+            setTotal(page.total);
+        } catch (err) {
+            console.error('Error loading more team members:', err);
+            alert('Failed to load more team members. Please try again.');
+        } finally {
+            setLoadingMore(false);
+        }
+    };
+
+    // Only a page of members is loaded, so the role filter offers every known role
+    const roles = ['All Roles', 'Admin', 'Team Leader', 'Team Member', 'Developer', 'Designer', 'Product Manager', 'QA Engineer'];
 
     // Get role icon based on role
     const getRoleIcon = (role) => {
@@ -83,17 +113,6 @@ function MyTeam() {
         return roleIcons[role] || 'fa-user';
     };
 
-    // Filter the team members based on search and filters
-    const filteredMembers = teamMembers.filter(member => {
-        const matchesSearch =
-            member.nama?.toLowerCase().includes(searchTerm.toLowerCase()) ||
-            member.email?.toLowerCase().includes(searchTerm.toLowerCase());
-
-        const matchesRole = selectedRole === 'All Roles' || member.role === selectedRole;
-
-        return matchesSearch && matchesRole;
-    });
-
     const openManageMember = (member) => {
         setSelectedMember(member);
         setNewRole(member.role || 'Team Member');
@@ -137,6 +156,7 @@ function MyTeam() {
                 setTeamMembers(prevMembers =>
                     prevMembers.filter(member => member.id !== selectedMember.id)
                 );
+                setTotal(prevTotal => Math.max(prevTotal - 1, 0));
 
                 // Close the modal
                 closeManageMember();
@@ -223,8 +243,8 @@ function MyTeam() {
                         </div>
                     ) : (
                         <div className="team-members-grid">
-                            {filteredMembers.length > 0 ? (
-                                filteredMembers.map(member => (
+                            {teamMembers.length > 0 ? (
+                                teamMembers.map(member => (
                                     <div
                                         key={member.id || member._id}
                                         className="member-card"
@@ -283,6 +303,14 @@ function MyTeam() {
                         </div>
                     )}
 
+                    {!loading && !error && nextCursor && (
+                        <div className="load-more">
+                            <button className="btn-load-more" onClick={loadMoreMembers} disabled={loadingMore}>
+                                {loadingMore ? 'Loading...' : 'Load more'}
+                            </button>
+                        </div>
+                    )}
+
                     <div className="team-stats">
                         <div className="stats-card">
                             <div className="stats-icon">
@@ -290,7 +318,7 @@ function MyTeam() {
                             </div>
                             <div className="stats-info">
                                 <h3>Team Size</h3>
-                                <div className="stats-value">{teamMembers.length}</div>
+                                <div className="stats-value">{total}</div>
                             </div>
                         </div>
                     </div>
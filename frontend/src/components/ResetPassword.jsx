@@ -0,0 +1,110 @@
+import { useState } from 'react';
+import { useNavigate, useSearchParams, Link } from 'react-router-dom';
+import { resetPassword } from '../services/authService';
+import '../styles/Auth.css';
+
+// Landing page of the password reset link sent by /auth/forgot-password
+function ResetPassword() {
+    const [searchParams] = useSearchParams();
+    const token = searchParams.get('token') || '';
+    const [password, setPassword] = useState('');
+    const [confirmPassword, setConfirmPassword] = useState('');
+    const [error, setError] = useState('');
+    const [violations, setViolations] = useState([]);
+    const [isLoading, setIsLoading] = useState(false);
+
+    const navigate = useNavigate();
+
+    const handleSubmit = async (e) => {
+        e.preventDefault();
+        setError('');
+        setViolations([]);
+
+        if (password !== confirmPassword) {
+            setError('Passwords do not match');
+            return;
+        }
+
+        setIsLoading(true);
+        try {
+            await resetPassword(token, password);
+            navigate('/login', { state: { message: 'Password has been reset, please log in' } });
+        } catch (err) {
+            console.error('Reset password error:', err);
+            setError(err.message);
+            setViolations(err.violations);
+        } finally {
+            setIsLoading(false);
+        }
+    };
+
+    if (!token) {
+        return (
+            <div className="auth-container">
+                <div className="auth-card">
+                    <h2>Reset Password</h2>
+                    <div className="error-message">This reset link is incomplete.</div>
+                    <p className="auth-redirect">
+                        <Link to="/login">Back to login</Link>
+                    </p>
+                </div>
+            </div>
+        );
+    }
+
+    return (
+        <div className="auth-container">
+            <div className="auth-card">
+                <h2>Reset Password</h2>
+                {error && (
+                    <div className="error-message">
+                        {error}
+                        {violations.length > 0 && (
+                            <ul>
+                                {violations.map((v) => <li key={v.code}>{v.message}</li>)}
+                            </ul>
+                        )}
+                    </div>
+                )}
+
+                <form onSubmit={handleSubmit}>
+                    <div className="form-group">
+                        <label htmlFor="password">New Password</label>
+                        <input
+                            type="password"
+                            id="password"
+                            autoComplete="new-password"
+                            value={password}
+                            onChange={(e) => setPassword(e.target.value)}
+                            placeholder="New Password"
+                            required
+                        />
+                    </div>
+
+                    <div className="form-group">
+                        <label htmlFor="confirmPassword">Confirm Password</label>
+                        <input
+                            type="password"
+                            id="confirmPassword"
+                            autoComplete="new-password"
+                            value={confirmPassword}
+                            onChange={(e) => setConfirmPassword(e.target.value)}
+                            placeholder="Confirm Password"
+                            required
+                        />
+                    </div>
+
+                    <button type="submit" className="auth-button" disabled={isLoading}>
+                        {isLoading ? 'Loading...' : 'Reset Password'}
+                    </button>
+                </form>
+
+                <p className="auth-redirect">
+                    <Link to="/login">Back to login</Link>
+                </p>
+            </div>
+        </div>
+    );
+}
+
+export default ResetPassword;
@@ -111,13 +111,13 @@ function Settings() {
                 }
             }
 
-            // Update user data - Make sure role is included even if unchanged
+            // Update user data - Make sure role is included even if unchanged.
+            // The picture is only ever set by the upload above.
             const updatedData = {
                 nama: fullName,
                 email,
                 role: role, // Include role explicitly
-                bio,
-                profileImage: imageUrl
+                bio
             };
 
             console.log("Updating user with data:", updatedData);
@@ -130,11 +130,22 @@ function Settings() {
                 // This is important to refresh the UI with the new role
                 const updatedUserData = {
                     ...user,
-                    ...updatedData
+                    ...updatedData,
+                    profileImage: imageUrl
                 };
+                // A new email only counts once the link sent to it has been used
+                if (result?.pendingEmail) {
+                    updatedUserData.email = user.email;
+                    setEmail(user.email);
+                }
                 loginUser(updatedUserData);
 
-                setMessage({ text: 'Profile updated successfully', type: 'success' });
+                setMessage({
+                    text: result?.pendingEmail
+                        ? `Profile updated. Check ${result.pendingEmail} for a link to confirm your new email.`
+                        : 'Profile updated successfully',
+                    type: 'success'
+                });
 
                 // Clear any file selection
                 setImageFile(null);
@@ -0,0 +1,55 @@
+import { useState, useEffect, useRef } from 'react';
+import { useSearchParams, Link } from 'react-router-dom';
+import { verifyEmail } from '../services/authService';
+import '../styles/Auth.css';
+
+// Landing page of the verification link sent after registration or an email change
+function VerifyEmail() {
+    const [searchParams] = useSearchParams();
+    const [status, setStatus] = useState('loading'); // loading | done | error
+    const [message, setMessage] = useState('');
+    const started = useRef(false);
+
+    useEffect(() => {
+        if (started.current) return;
+        started.current = true;
+
+        const token = searchParams.get('token');
+        if (!token) {
+            setMessage('This verification link is incomplete.');
+            setStatus('error');
+            return;
+        }
+
+        verifyEmail(token)
+            .then((data) => {
+                setMessage(data.message || 'Email verified');
+                setStatus('done');
+            })
+            .catch((err) => {
+                console.error('Email verification error:', err);
+                setMessage(err.message);
+                setStatus('error');
+            });
+        // eslint-disable-next-line react-hooks/exhaustive-deps
+    }, []);
+
+    return (
+        <div className="auth-container">
+            <div className="auth-card">
+                <h2>Email Verification</h2>
+                {status === 'loading' && <p>Verifying your email...</p>}
+                {status === 'done' && <div className="success-message">{message}</div>}
+                {status === 'error' && <div className="error-message">{message}</div>}
+
+                {status !== 'loading' && (
+                    <p className="auth-redirect">
+                        <Link to="/login">Go to login</Link>
+                    </p>
+                )}
+            </div>
+        </div>
+    );
+}
+
+export default VerifyEmail;
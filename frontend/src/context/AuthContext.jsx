@@ -1,5 +1,5 @@
 import { createContext, useState, useEffect } from 'react';
-import { getCurrentUser } from '../services/authService';
+import { getCurrentUser, logout } from '../services/authService';
 
 // Add base API URL
 const API_URL = 'http://localhost:8080';
@@ -53,6 +53,7 @@ export const AuthProvider = ({ children }) => {
                 console.error('Auth initialization error:', error);
                 localStorage.removeItem('user');
                 localStorage.removeItem('token');
+                localStorage.removeItem('refreshToken');
             } finally {
                 setLoading(false);
             }
@@ -70,8 +71,8 @@ export const AuthProvider = ({ children }) => {
     };
 
     const logoutUser = () => {
-        localStorage.removeItem('user');
-        localStorage.removeItem('token');
+        // Revokes the session on the server and clears the stored tokens
+        logout();
         setUser(null);
     };
 
@@ -1,4 +1,5 @@
 import axios from 'axios';
+import { withAuth } from './authService';
 
 // Change URL according to your backend configuration
 const baseURL = import.meta.env.VITE_API_URL || 'http://localhost:8080';
@@ -12,18 +13,7 @@ const api = axios.create({
     timeout: 5000
 });
 
-// Intercept requests to add authentication token if available
-api.interceptors.request.use(
-    (config) => {
-        const token = localStorage.getItem('token');
-        if (token) {
-            config.headers['Authorization'] = `Bearer ${token}`;
-        }
-        return config;
-    },
-    (error) => {
-        return Promise.reject(error);
-    }
-);
+// Add the authentication token to requests, refreshing it when it has expired
+withAuth(api);
 
 export default api;
\ No newline at end of file
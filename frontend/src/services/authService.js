@@ -10,14 +10,81 @@ const api = axios.create({
     },
 });
 
-// Add token to requests if it exists
-api.interceptors.request.use((config) => {
-    const token = localStorage.getItem('token');
-    if (token) {
-        config.headers.Authorization = `Bearer ${token}`;
+// Access tokens only live a few minutes; the refresh token gets a new pair from /auth/refresh.
+// Every request made while one refresh is running waits for that same refresh.
+let refreshing = null;
+
+export const saveTokens = (data) => {
+    if (data.token) {
+        localStorage.setItem('token', data.token);
     }
-    return config;
-});
+    if (data.refreshToken) {
+        localStorage.setItem('refreshToken', data.refreshToken);
+    }
+};
+
+const clearSession = () => {
+    localStorage.removeItem('token');
+    localStorage.removeItem('refreshToken');
+    localStorage.removeItem('user');
+};
+
+export const refreshAccessToken = () => {
+    if (!refreshing) {
+        const refreshToken = localStorage.getItem('refreshToken');
+        // Plain axios, so a failing refresh doesn't go through the interceptor again
+        refreshing = (refreshToken
+            ? axios.post(`${API_URL}/auth/refresh`, { refreshToken }).then((response) => {
+                saveTokens(response.data);
+                return response.data.token;
+            })
+            : Promise.reject(new Error('No refresh token'))
+        ).finally(() => {
+            refreshing = null;
+        });
+    }
+    return refreshing;
+};
+
+// withAuth adds the access token to every request of an axios instance and, when the
+// server answers 401, refreshes the token once and retries. When the refresh fails
+// the session is over and the user goes back to the login page.
+export const withAuth = (instance) => {
+    instance.interceptors.request.use((config) => {
+        const token = localStorage.getItem('token');
+        if (token) {
+            config.headers.Authorization = `Bearer ${token}`;
+        }
+        return config;
+    });
+
+    instance.interceptors.response.use(
+        response => response,
+        async (error) => {
+            const original = error.config;
+            const isAuthCall = original?.url?.includes('/auth/') || original?.url?.endsWith('/login');
+            if (error.response?.status !== 401 || !original || original._retried || isAuthCall) {
+                return Promise.reject(error);
+            }
+
+            original._retried = true;
+            try {
+                const token = await refreshAccessToken();
+                original.headers.Authorization = `Bearer ${token}`;
+                return instance(original);
+            } catch (refreshError) {
+                console.error('Session expired:', refreshError);
+                clearSession();
+                window.location.assign('/login');
+                return Promise.reject(error);
+            }
+        }
+    );
+
+    return instance;
+};
+
+withAuth(api);
 
 // Add response interceptor to handle errors globally
 api.interceptors.response.use(
@@ -79,9 +146,9 @@ export const login = async (credentials) => {
         // Ubah URL menjadi /login (tanpa /auth prefix)
         const response = await api.post('/login', credentials);
         
-        // Save token and user data to local storage
+        // Save tokens and user data to local storage
         if (response.data.token) {
-            localStorage.setItem('token', response.data.token);
+            saveTokens(response.data);
             localStorage.setItem('user', JSON.stringify(response.data.user));
         }
         
@@ -92,9 +159,96 @@ export const login = async (credentials) => {
     }
 };
 
-export const logout = () => {
-    localStorage.removeItem('token');
-    localStorage.removeItem('user');
+// Ends the session on the server too, so the refresh token can't be used anymore
+export const logout = async () => {
+    const refreshToken = localStorage.getItem('refreshToken');
+    try {
+        if (localStorage.getItem('token')) {
+            await api.post('/auth/logout', { refreshToken });
+        }
+    } catch (error) {
+        console.error('Logout error:', error);
+    } finally {
+        clearSession();
+    }
+};
+
+// Turns an API error into an Error with the server's message; password policy
+// violations come along in error.violations
+const apiError = (error, fallback) => {
+    const data = error.response?.data || {};
+    const apiErr = new Error(data.error || data.message || error.message || fallback);
+    apiErr.status = error.response?.status;
+    apiErr.violations = Array.isArray(data.violations) ? data.violations : [];
+    return apiErr;
+};
+
+// Stores the session of a finished login. Logins that still need a second factor
+// have no token yet and are returned as they are.
+const finishLogin = (data) => {
+    if (data.token) {
+        saveTokens(data);
+        localStorage.setItem('user', JSON.stringify(data.user));
+    }
+    return data;
+};
+
+// Links mailed by the server: /magic-login, /reset-password, /verify-email, /accept-invitation
+
+export const magicLinkLogin = async (token) => {
+    try {
+        const response = await api.post('/auth/magic-link/login', { token });
+        return finishLogin(response.data);
+    } catch (error) {
+        throw apiError(error, 'Login link is invalid or has expired');
+    }
+};
+
+// Second step of a login for accounts with 2FA: a code from the authenticator app or a recovery code
+export const loginTwoFactor = async (challengeToken, code) => {
+    try {
+        const response = await api.post('/auth/login/2fa', { challengeToken, code });
+        return finishLogin(response.data);
+    } catch (error) {
+        throw apiError(error, 'Verification failed');
+    }
+};
+
+export const resetPassword = async (token, newPassword) => {
+    try {
+        const response = await api.post('/auth/reset-password', { token, newPassword });
+        return response.data;
+    } catch (error) {
+        throw apiError(error, 'Failed to reset password');
+    }
+};
+
+// Confirms a new account's email, or an email change requested in the settings
+export const verifyEmail = async (token) => {
+    try {
+        const response = await api.post('/auth/verify-email', { token });
+        return response.data;
+    } catch (error) {
+        throw apiError(error, 'Verification link is invalid or has expired');
+    }
+};
+
+export const getInvitation = async (token) => {
+    try {
+        const response = await api.get('/auth/invitations', { params: { token } });
+        return response.data;
+    } catch (error) {
+        throw apiError(error, 'Invitation is invalid or has expired');
+    }
+};
+
+export const acceptInvitation = async ({ token, nama, password }) => {
+    try {
+        const response = await api.post('/auth/invitations/accept', { token, nama, password });
+        return response.data;
+    } catch (error) {
+        throw apiError(error, 'Failed to accept invitation');
+    }
 };
 
 export const getCurrentUser = () => {
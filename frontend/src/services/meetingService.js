@@ -1,4 +1,5 @@
 import axios from 'axios';
+import { withAuth } from './authService';
 import { API_URL } from '../config';
 
 // Create axios instance
@@ -9,14 +10,8 @@ const api = axios.create({
     },
 });
 
-// Add token to requests
-api.interceptors.request.use((config) => {
-    const token = localStorage.getItem('token');
-    if (token) {
-        config.headers.Authorization = `Bearer ${token}`;
-    }
-    return config;
-});
+// Add token to requests, refreshing it when it has expired
+withAuth(api);
 
 // Create meeting
 export const createMeeting = async (meetingData) => {
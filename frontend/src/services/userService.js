@@ -1,4 +1,5 @@
 import axios from 'axios';
+import { withAuth } from './authService';
 
 const API_URL = 'http://localhost:8080';
 
@@ -10,45 +11,42 @@ const api = axios.create({
     },
 });
 
-// Add token to requests if it exists
-api.interceptors.request.use((config) => {
-    const token = localStorage.getItem('token');
-    if (token) {
-        config.headers.Authorization = `Bearer ${token}`;
-    }
-    return config;
-});
+// Add token to requests, refreshing it when it has expired
+withAuth(api);
 
-// Get all users
-// Get all users
-// This function should be already implemented, but ensure it's working correctly
-export const getUsers = async () => {
+// Get one page of users
+// The listing is cursor based: pass the returned nextCursor back as cursor for the next page,
+// it is empty on the last one. params may contain limit, cursor, sort, order, role, status,
+// accountStatus and q (search on name and email).
+export const getUsers = async (params = {}) => {
     try {
         console.log('Fetching users from:', API_URL + '/users');
-        const response = await api.get('/users');
+        // Leave out empty filters so they don't narrow the result
+        const query = Object.fromEntries(
+            Object.entries(params).filter(([, value]) => value !== undefined && value !== null && value !== '')
+        );
+        const response = await api.get('/users', { params: query });
         console.log('API response:', response);
 
-        // Ensure we have valid data
-        const data = response.data || [];
+        const page = response.data || {};
+        const data = Array.isArray(page.data) ? page.data : [];
 
         // Add baseURL to profileImage if exists
-        const users = Array.isArray(data) ? data.map(user => {
+        const users = data.map(user => {
             if (user && user.profileImage && !user.profileImage.startsWith('http')) {
                 user.profileImage = API_URL + user.profileImage;
             }
             return user;
-        }) : [];
+        });
 
-        return users;
+        return { users, nextCursor: page.nextCursor || '', total: page.total ?? users.length };
     } catch (error) {
         console.error('Error fetching users:', error);
         if (error.response) {
             console.error('Response status:', error.response.status);
             console.error('Response data:', error.response.data);
         }
-
-        // Return an empty array to avoid errors
-        return [];
+        throw error;
     }
 };
 
@@ -108,16 +106,10 @@ export const uploadProfileImage = async (formData) => {
             throw new Error('No authentication token found');
         }
 
-        const uploadInstance = axios.create({
-            baseURL: API_URL,
-            headers: {
-                'Content-Type': 'multipart/form-data',
-                'Authorization': `Bearer ${token}`
-            }
-        });
-
         console.log('Uploading to:', API_URL + '/api/upload-profile-image');
-        const response = await uploadInstance.post('/api/upload-profile-image', formData);
+        const response = await api.post('/api/upload-profile-image', formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+        });
 
         console.log('Upload response:', response.data);
 
@@ -139,13 +131,7 @@ export const uploadProfileImage = async (formData) => {
 // Delete user
 export const deleteUser = async (userId) => {
     try {
-        const token = localStorage.getItem('token');
-
-        const response = await api.delete(`/api/users/${userId}`, {
-            headers: {
-                'Authorization': `Bearer ${token}`
-            }
-        });
+        const response = await api.delete(`/api/users/${userId}`);
 
         return response.data;
     } catch (error) {